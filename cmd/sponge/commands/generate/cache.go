@@ -153,22 +153,6 @@ func (g *stringCacheGenerator) addFields(r replacer.Replacer) []replacer.Field {
 	var fields []replacer.Field
 	fields = append(fields, deleteFieldsMark(r, cacheFile, startMark, endMark)...)
 
-	// match the case where the value type is a pointer
-	if g.valueType[0] == '*' {
-		fields = append(fields, []replacer.Field{
-			{
-				Old:             "var valueNameExample valueTypeExample",
-				New:             fmt.Sprintf("%s := &%s{}", g.valueName, g.valueType[1:]),
-				IsCaseSensitive: false,
-			},
-			{
-				Old:             "&valueNameExample",
-				New:             g.valueName,
-				IsCaseSensitive: false,
-			},
-		}...)
-	}
-
 	fields = append(fields, []replacer.Field{
 		{
 			Old: "github.com/go-dev-frame/sponge/internal/model",
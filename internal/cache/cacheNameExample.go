@@ -35,25 +35,22 @@ type CacheNameExampleCache interface {
 }
 
 type cacheNameExampleCache struct {
-	cache cache.Cache
+	cache *cache.Typed[valueTypeExample]
 }
 
-// NewCacheNameExampleCache create a new cache
-func NewCacheNameExampleCache(cacheType *database.CacheType) CacheNameExampleCache {
-	newObject := func() interface{} {
-		return ""
-	}
+// NewCacheNameExampleCache create a new cache, opts enable the metrics and tracing of the cache, e.g. cache.WithMetrics(), cache.WithTracing()
+func NewCacheNameExampleCache(cacheType *database.CacheType, opts ...cache.InstrumentOption) CacheNameExampleCache {
 	cachePrefix := ""
 	jsonEncoding := encoding.JSONEncoding{}
 
 	cType := strings.ToLower(cacheType.CType)
 	switch cType {
 	case "redis":
-		c := cache.NewRedisCache(cacheType.Rdb, cachePrefix, jsonEncoding, newObject)
-		return &cacheNameExampleCache{cache: c}
+		c := cache.NewTypedRedisCache[valueTypeExample](cacheType.Rdb, cachePrefix, jsonEncoding)
+		return &cacheNameExampleCache{cache: c.Instrument(opts...)}
 	case "memory":
-		c := cache.NewMemoryCache(cachePrefix, jsonEncoding, newObject)
-		return &cacheNameExampleCache{cache: c}
+		c := cache.NewTypedMemoryCache[valueTypeExample](cachePrefix, jsonEncoding)
+		return &cacheNameExampleCache{cache: c.Instrument(opts...)}
 	}
 
 	panic(fmt.Sprintf("unsupported cache type='%s'", cacheType.CType))
@@ -67,18 +64,13 @@ func (c *cacheNameExampleCache) getCacheKey(keyNameExample keyTypeExample) strin
 // Set cache
 func (c *cacheNameExampleCache) Set(ctx context.Context, keyNameExample keyTypeExample, valueNameExample valueTypeExample, duration time.Duration) error {
 	cacheKey := c.getCacheKey(keyNameExample)
-	return c.cache.Set(ctx, cacheKey, &valueNameExample, duration)
+	return c.cache.Set(ctx, cacheKey, valueNameExample, duration)
 }
 
 // Get cache
 func (c *cacheNameExampleCache) Get(ctx context.Context, keyNameExample keyTypeExample) (valueTypeExample, error) {
-	var valueNameExample valueTypeExample
 	cacheKey := c.getCacheKey(keyNameExample)
-	err := c.cache.Get(ctx, cacheKey, &valueNameExample)
-	if err != nil {
-		return valueNameExample, err
-	}
-	return valueNameExample, nil
+	return c.cache.Get(ctx, cacheKey)
 }
 
 // Del delete cache
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-dev-frame/sponge/pkg/cache"
 	"github.com/go-dev-frame/sponge/pkg/encoding"
+	"github.com/go-dev-frame/sponge/pkg/logger"
 	"github.com/go-dev-frame/sponge/pkg/utils"
 
 	"github.com/go-dev-frame/sponge/internal/database"
@@ -32,30 +33,30 @@ type UserExampleCache interface {
 	Del(ctx context.Context, id uint64) error
 	SetPlaceholder(ctx context.Context, id uint64) error
 	IsPlaceholderErr(err error) bool
+	NewLoader(load func(ctx context.Context, id uint64) (*model.UserExample, error)) *UserExampleLoader
 }
 
+// UserExampleLoader read-through loader, get from cache, load from database if missed
+type UserExampleLoader = cache.Loader[uint64, *model.UserExample]
+
 // userExampleCache define a cache struct
 type userExampleCache struct {
-	cache cache.Cache
+	cache *cache.Typed[*model.UserExample]
 }
 
-// NewUserExampleCache new a cache
-func NewUserExampleCache(cacheType *database.CacheType) UserExampleCache {
+// NewUserExampleCache new a cache, opts enable the metrics and tracing of the cache, e.g. cache.WithMetrics(), cache.WithTracing()
+func NewUserExampleCache(cacheType *database.CacheType, opts ...cache.InstrumentOption) UserExampleCache {
 	jsonEncoding := encoding.JSONEncoding{}
 	cachePrefix := ""
 
 	cType := strings.ToLower(cacheType.CType)
 	switch cType {
 	case "redis":
-		c := cache.NewRedisCache(cacheType.Rdb, cachePrefix, jsonEncoding, func() interface{} {
-			return &model.UserExample{}
-		})
-		return &userExampleCache{cache: c}
+		c := cache.NewTypedRedisCache[*model.UserExample](cacheType.Rdb, cachePrefix, jsonEncoding)
+		return &userExampleCache{cache: c.Instrument(opts...)}
 	case "memory":
-		c := cache.NewMemoryCache(cachePrefix, jsonEncoding, func() interface{} {
-			return &model.UserExample{}
-		})
-		return &userExampleCache{cache: c}
+		c := cache.NewTypedMemoryCache[*model.UserExample](cachePrefix, jsonEncoding)
+		return &userExampleCache{cache: c.Instrument(opts...)}
 	}
 
 	return nil // no cache
@@ -81,18 +82,13 @@ func (c *userExampleCache) Set(ctx context.Context, id uint64, data *model.UserE
 
 // Get cache value
 func (c *userExampleCache) Get(ctx context.Context, id uint64) (*model.UserExample, error) {
-	var data *model.UserExample
 	cacheKey := c.GetUserExampleCacheKey(id)
-	err := c.cache.Get(ctx, cacheKey, &data)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return c.cache.Get(ctx, cacheKey)
 }
 
 // MultiSet multiple set cache
 func (c *userExampleCache) MultiSet(ctx context.Context, data []*model.UserExample, duration time.Duration) error {
-	valMap := make(map[string]interface{})
+	valMap := make(map[string]*model.UserExample)
 	for _, v := range data {
 		cacheKey := c.GetUserExampleCacheKey(v.ID)
 		valMap[cacheKey] = v
@@ -114,8 +110,7 @@ func (c *userExampleCache) MultiGet(ctx context.Context, ids []uint64) (map[uint
 		keys = append(keys, cacheKey)
 	}
 
-	itemMap := make(map[string]*model.UserExample)
-	err := c.cache.MultiGet(ctx, keys, itemMap)
+	itemMap, err := c.cache.MultiGet(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -151,3 +146,15 @@ func (c *userExampleCache) SetPlaceholder(ctx context.Context, id uint64) error
 func (c *userExampleCache) IsPlaceholderErr(err error) bool {
 	return errors.Is(err, cache.ErrPlaceholder)
 }
+
+// NewLoader new a read-through loader, the concurrent misses of the same id are merged into one load,
+// a placeholder is cached if the record is not found, and the hot key is refreshed before it expires.
+func (c *userExampleCache) NewLoader(load func(ctx context.Context, id uint64) (*model.UserExample, error)) *UserExampleLoader {
+	return cache.NewLoader(c.cache.Cache(), c.GetUserExampleCacheKey, load,
+		cache.WithLoaderExpiration(UserExampleExpireTime),
+		cache.WithNotFoundError(database.ErrRecordNotFound),
+		cache.WithLoaderErrorHandler(func(ctx context.Context, key string, err error) {
+			logger.Warn("cache error", logger.Err(err), logger.String("key", key))
+		}),
+	)
+}
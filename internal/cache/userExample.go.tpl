@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-dev-frame/sponge/pkg/cache"
 	"github.com/go-dev-frame/sponge/pkg/encoding"
+	"github.com/go-dev-frame/sponge/pkg/logger"
 	"github.com/go-dev-frame/sponge/pkg/utils"
 
 	"github.com/go-dev-frame/sponge/internal/database"
@@ -32,30 +33,30 @@ type {{.TableNameCamel}}Cache interface {
 	Del(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) error
 	SetPlaceholder(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) error
 	IsPlaceholderErr(err error) bool
+	NewLoader(load func(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) (*model.{{.TableNameCamel}}, error)) *{{.TableNameCamel}}Loader
 }
 
+// {{.TableNameCamel}}Loader read-through loader, get from cache, load from database if missed
+type {{.TableNameCamel}}Loader = cache.Loader[{{.GoType}}, *model.{{.TableNameCamel}}]
+
 // {{.TableNameCamelFCL}}Cache define a cache struct
 type {{.TableNameCamelFCL}}Cache struct {
-	cache cache.Cache
+	cache *cache.Typed[*model.{{.TableNameCamel}}]
 }
 
-// New{{.TableNameCamel}}Cache new a cache
-func New{{.TableNameCamel}}Cache(cacheType *database.CacheType) {{.TableNameCamel}}Cache {
+// New{{.TableNameCamel}}Cache new a cache, opts enable the metrics and tracing of the cache, e.g. cache.WithMetrics(), cache.WithTracing()
+func New{{.TableNameCamel}}Cache(cacheType *database.CacheType, opts ...cache.InstrumentOption) {{.TableNameCamel}}Cache {
 	jsonEncoding := encoding.JSONEncoding{}
 	cachePrefix := ""
 
 	cType := strings.ToLower(cacheType.CType)
 	switch cType {
 	case "redis":
-		c := cache.NewRedisCache(cacheType.Rdb, cachePrefix, jsonEncoding, func() interface{} {
-			return &model.{{.TableNameCamel}}{}
-		})
-		return &{{.TableNameCamelFCL}}Cache{cache: c}
+		c := cache.NewTypedRedisCache[*model.{{.TableNameCamel}}](cacheType.Rdb, cachePrefix, jsonEncoding)
+		return &{{.TableNameCamelFCL}}Cache{cache: c.Instrument(opts...)}
 	case "memory":
-		c := cache.NewMemoryCache(cachePrefix, jsonEncoding, func() interface{} {
-			return &model.{{.TableNameCamel}}{}
-		})
-		return &{{.TableNameCamelFCL}}Cache{cache: c}
+		c := cache.NewTypedMemoryCache[*model.{{.TableNameCamel}}](cachePrefix, jsonEncoding)
+		return &{{.TableNameCamelFCL}}Cache{cache: c.Instrument(opts...)}
 	}
 
 	return nil // no cache
@@ -81,18 +82,13 @@ func (c *{{.TableNameCamelFCL}}Cache) Set(ctx context.Context, {{.ColumnNameCame
 
 // Get cache value
 func (c *{{.TableNameCamelFCL}}Cache) Get(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) (*model.{{.TableNameCamel}}, error) {
-	var data *model.{{.TableNameCamel}}
 	cacheKey := c.Get{{.TableNameCamel}}CacheKey({{.ColumnNameCamelFCL}})
-	err := c.cache.Get(ctx, cacheKey, &data)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return c.cache.Get(ctx, cacheKey)
 }
 
 // MultiSet multiple set cache
 func (c *{{.TableNameCamelFCL}}Cache) MultiSet(ctx context.Context, data []*model.{{.TableNameCamel}}, duration time.Duration) error {
-	valMap := make(map[string]interface{})
+	valMap := make(map[string]*model.{{.TableNameCamel}})
 	for _, v := range data {
 		cacheKey := c.Get{{.TableNameCamel}}CacheKey(v.{{.ColumnNameCamel}})
 		valMap[cacheKey] = v
@@ -114,8 +110,7 @@ func (c *{{.TableNameCamelFCL}}Cache) MultiGet(ctx context.Context, {{.ColumnNam
 		keys = append(keys, cacheKey)
 	}
 
-	itemMap := make(map[string]*model.{{.TableNameCamel}})
-	err := c.cache.MultiGet(ctx, keys, itemMap)
+	itemMap, err := c.cache.MultiGet(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -151,3 +146,15 @@ func (c *{{.TableNameCamelFCL}}Cache) SetPlaceholder(ctx context.Context, {{.Col
 func (c *{{.TableNameCamelFCL}}Cache) IsPlaceholderErr(err error) bool {
 	return errors.Is(err, cache.ErrPlaceholder)
 }
+
+// NewLoader new a read-through loader, the concurrent misses of the same {{.ColumnNameCamelFCL}} are merged into one load,
+// a placeholder is cached if the record is not found, and the hot key is refreshed before it expires.
+func (c *{{.TableNameCamelFCL}}Cache) NewLoader(load func(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) (*model.{{.TableNameCamel}}, error)) *{{.TableNameCamel}}Loader {
+	return cache.NewLoader(c.cache.Cache(), c.Get{{.TableNameCamel}}CacheKey, load,
+		cache.WithLoaderExpiration({{.TableNameCamel}}ExpireTime),
+		cache.WithNotFoundError(database.ErrRecordNotFound),
+		cache.WithLoaderErrorHandler(func(ctx context.Context, key string, err error) {
+			logger.Warn("cache error", logger.Err(err), logger.String("key", key))
+		}),
+	)
+}
@@ -1,11 +1,13 @@
 package cache
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/go-dev-frame/sponge/pkg/cache"
 	"github.com/go-dev-frame/sponge/pkg/gotest"
 	"github.com/go-dev-frame/sponge/pkg/utils"
 
@@ -144,4 +146,30 @@ func TestNewUserExampleCache(t *testing.T) {
 		CType: "redis",
 	})
 	assert.NotNil(t, c)
+	c = NewUserExampleCache(&database.CacheType{
+		CType: "memory",
+	}, cache.WithMetrics(), cache.WithTracing())
+	assert.NotNil(t, c)
+}
+
+func Test_userExampleCache_NewLoader(t *testing.T) {
+	c := newUserExampleCache()
+	defer c.Close()
+
+	record := c.TestDataSlice[0].(*model.UserExample)
+	loader := c.ICache.(UserExampleCache).NewLoader(func(ctx context.Context, id uint64) (*model.UserExample, error) {
+		if id == record.ID {
+			return record, nil
+		}
+		return nil, database.ErrRecordNotFound
+	})
+
+	got, err := loader.Get(c.Ctx, record.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, record.ID, got.ID)
+
+	_, err = loader.Get(c.Ctx, 100)
+	assert.ErrorIs(t, err, database.ErrRecordNotFound)
+	_, err = c.ICache.(UserExampleCache).Get(c.Ctx, 100)
+	assert.True(t, c.ICache.(UserExampleCache).IsPlaceholderErr(err))
 }
@@ -4,15 +4,11 @@ import (
 	"context"
 	"errors"
 
-	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 
-	"github.com/go-dev-frame/sponge/pkg/logger"
 	"github.com/go-dev-frame/sponge/pkg/sgorm/query"
-	"github.com/go-dev-frame/sponge/pkg/utils"
 
 	"github.com/go-dev-frame/sponge/internal/cache"
-	"github.com/go-dev-frame/sponge/internal/database"
 	"github.com/go-dev-frame/sponge/internal/model"
 )
 
@@ -32,9 +28,9 @@ type UserExampleDao interface {
 }
 
 type userExampleDao struct {
-	db    *gorm.DB
-	cache cache.UserExampleCache // if nil, the cache is not used.
-	sfg   *singleflight.Group    // if cache is nil, the sfg is not used.
+	db     *gorm.DB
+	cache  cache.UserExampleCache   // if nil, the cache is not used.
+	loader *cache.UserExampleLoader // if cache is nil, the loader is not used.
 }
 
 // NewUserExampleDao creating the dao interface
@@ -42,11 +38,12 @@ func NewUserExampleDao(db *gorm.DB, xCache cache.UserExampleCache) UserExampleDa
 	if xCache == nil {
 		return &userExampleDao{db: db}
 	}
-	return &userExampleDao{
+	d := &userExampleDao{
 		db:    db,
 		cache: xCache,
-		sfg:   new(singleflight.Group),
 	}
+	d.loader = xCache.NewLoader(d.loadByID)
+	return d
 }
 
 func (d *userExampleDao) deleteCache(ctx context.Context, id uint64) error {
@@ -130,49 +127,19 @@ func (d *userExampleDao) GetByID(ctx context.Context, id uint64) (*model.UserExa
 		return record, err
 	}
 
-	// get from cache
-	record, err := d.cache.Get(ctx, id)
-	if err == nil {
-		return record, nil
-	}
-
-	// get from database
-	if errors.Is(err, database.ErrCacheNotFound) {
-		// for the same id, prevent high concurrent simultaneous access to database
-		val, err, _ := d.sfg.Do(utils.Uint64ToStr(id), func() (interface{}, error) { //nolint
-			table := &model.UserExample{}
-			err = d.db.WithContext(ctx).Where("id = ?", id).First(table).Error
-			if err != nil {
-				if errors.Is(err, database.ErrRecordNotFound) {
-					// set placeholder cache to prevent cache penetration, default expiration time 10 minutes
-					if err = d.cache.SetPlaceholder(ctx, id); err != nil {
-						logger.Warn("cache.SetPlaceholder error", logger.Err(err), logger.Any("id", id))
-					}
-					return nil, database.ErrRecordNotFound
-				}
-				return nil, err
-			}
-			// set cache
-			if err = d.cache.Set(ctx, id, table, cache.UserExampleExpireTime); err != nil {
-				logger.Warn("cache.Set error", logger.Err(err), logger.Any("id", id))
-			}
-			return table, nil
-		})
-		if err != nil {
-			return nil, err
-		}
-		table, ok := val.(*model.UserExample)
-		if !ok {
-			return nil, database.ErrRecordNotFound
-		}
-		return table, nil
-	}
+	// get from cache, load from database if missed, the concurrent misses of the same id are
+	// merged into one query, and a placeholder is cached if the record is not found
+	return d.loader.Get(ctx, id)
+}
 
-	if d.cache.IsPlaceholderErr(err) {
-		return nil, database.ErrRecordNotFound
+// loadByID load a userExample from database by id, used by the cache loader
+func (d *userExampleDao) loadByID(ctx context.Context, id uint64) (*model.UserExample, error) {
+	record := &model.UserExample{}
+	err := d.db.WithContext(ctx).Where("id = ?", id).First(record).Error
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, err
+	return record, nil
 }
 
 // GetByColumns get a paginated list of userExamples by custom conditions.
@@ -4,15 +4,11 @@ import (
 	"context"
 	"errors"
 
-	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 
-	"github.com/go-dev-frame/sponge/pkg/logger"
 	"github.com/go-dev-frame/sponge/pkg/sgorm/query"
-	"github.com/go-dev-frame/sponge/pkg/utils"
 
 	"github.com/go-dev-frame/sponge/internal/cache"
-	"github.com/go-dev-frame/sponge/internal/database"
 	"github.com/go-dev-frame/sponge/internal/model"
 )
 
@@ -32,9 +28,9 @@ type {{.TableNameCamel}}Dao interface {
 }
 
 type {{.TableNameCamelFCL}}Dao struct {
-	db    *gorm.DB
-	cache cache.{{.TableNameCamel}}Cache // if nil, the cache is not used.
-	sfg   *singleflight.Group    // if cache is nil, the sfg is not used.
+	db     *gorm.DB
+	cache  cache.{{.TableNameCamel}}Cache   // if nil, the cache is not used.
+	loader *cache.{{.TableNameCamel}}Loader // if cache is nil, the loader is not used.
 }
 
 // New{{.TableNameCamel}}Dao creating the dao interface
@@ -42,11 +38,12 @@ func New{{.TableNameCamel}}Dao(db *gorm.DB, xCache cache.{{.TableNameCamel}}Cach
 	if xCache == nil {
 		return &{{.TableNameCamelFCL}}Dao{db: db}
 	}
-	return &{{.TableNameCamelFCL}}Dao{
+	d := &{{.TableNameCamelFCL}}Dao{
 		db:    db,
 		cache: xCache,
-		sfg:   new(singleflight.Group),
 	}
+	d.loader = xCache.NewLoader(d.loadBy{{.ColumnNameCamel}})
+	return d
 }
 
 func (d *{{.TableNameCamelFCL}}Dao) deleteCache(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) error {
@@ -134,51 +131,19 @@ func (d *{{.TableNameCamelFCL}}Dao) GetBy{{.ColumnNameCamel}}(ctx context.Contex
 		return record, err
 	}
 
-	// get from cache
-	record, err := d.cache.Get(ctx, {{.ColumnNameCamelFCL}})
-	if err == nil {
-		return record, nil
-	}
-
-	// get from database
-	if errors.Is(err, database.ErrCacheNotFound) {
-		// for the same {{.ColumnNameCamelFCL}}, prevent high concurrent simultaneous access to database
-		{{if .IsStringType}}val, err, _ := d.sfg.Do({{.ColumnNameCamelFCL}}, func() (interface{}, error) {
-{{else}}		val, err, _ := d.sfg.Do(utils.{{.GoTypeFCU}}ToStr({{.ColumnNameCamelFCL}}), func() (interface{}, error) {
-{{end}}
-			table := &model.{{.TableNameCamel}}{}
-			err = d.db.WithContext(ctx).Where("{{.ColumnName}} = ?", {{.ColumnNameCamelFCL}}).First(table).Error
-			if err != nil {
-				// set placeholder cache to prevent cache penetration, default expiration time 10 minutes
-				if errors.Is(err, database.ErrRecordNotFound) {
-					if err = d.cache.SetPlaceholder(ctx, {{.ColumnNameCamelFCL}}); err != nil {
-						logger.Warn("cache.SetPlaceholder error", logger.Err(err), logger.Any("{{.ColumnNameCamelFCL}}", {{.ColumnNameCamelFCL}}))
-					}
-					return nil, database.ErrRecordNotFound
-				}
-				return nil, err
-			}
-			// set cache
-			if err = d.cache.Set(ctx, {{.ColumnNameCamelFCL}}, table, cache.{{.TableNameCamel}}ExpireTime); err != nil {
-				logger.Warn("cache.Set error", logger.Err(err), logger.Any("{{.ColumnNameCamelFCL}}", {{.ColumnNameCamelFCL}}))
-			}
-			return table, nil
-		})
-		if err != nil {
-			return nil, err
-		}
-		table, ok := val.(*model.{{.TableNameCamel}})
-		if !ok {
-			return nil, database.ErrRecordNotFound
-		}
-		return table, nil
-	}
+	// get from cache, load from database if missed, the concurrent misses of the same {{.ColumnNameCamelFCL}} are
+	// merged into one query, and a placeholder is cached if the record is not found
+	return d.loader.Get(ctx, {{.ColumnNameCamelFCL}})
+}
 
-	if d.cache.IsPlaceholderErr(err) {
-		return nil, database.ErrRecordNotFound
+// loadBy{{.ColumnNameCamel}} load a {{.TableNameCamelFCL}} from database by {{.ColumnNameCamelFCL}}, used by the cache loader
+func (d *{{.TableNameCamelFCL}}Dao) loadBy{{.ColumnNameCamel}}(ctx context.Context, {{.ColumnNameCamelFCL}} {{.GoType}}) (*model.{{.TableNameCamel}}, error) {
+	record := &model.{{.TableNameCamel}}{}
+	err := d.db.WithContext(ctx).Where("{{.ColumnName}} = ?", {{.ColumnNameCamelFCL}}).First(record).Error
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, err
+	return record, nil
 }
 
 // GetByColumns get a paginated list of {{.TableNamePluralCamelFCL}} by custom conditions.
@@ -13,6 +13,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/go-dev-frame/sponge/pkg/app"
+	"github.com/go-dev-frame/sponge/pkg/cache"
 	"github.com/go-dev-frame/sponge/pkg/errcode"
 	"github.com/go-dev-frame/sponge/pkg/grpc/gtls"
 	"github.com/go-dev-frame/sponge/pkg/grpc/interceptor"
@@ -181,7 +182,11 @@ func (s *grpcServer) unaryServerOptions() grpc.ServerOption {
 
 	// metrics interceptor
 	if config.Get().App.EnableMetrics {
-		unaryServerInterceptors = append(unaryServerInterceptors, interceptor.UnaryServerMetrics())
+		unaryServerInterceptors = append(unaryServerInterceptors, interceptor.UnaryServerMetrics(
+			// the metrics of the cache requests, recorded by the caches created with cache.WithMetrics
+			metrics.WithCounterMetrics(cache.RequestCounter()),
+			metrics.WithHistogramMetrics(cache.RequestDuration()),
+		))
 		s.registerMetricsMuxAndMethodFunc = s.registerMetricsMuxAndMethod()
 	}
 
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-dev-frame/sponge/pkg/tracer"
+)
+
+// the results of the cache requests
+const (
+	resultHit         = "hit"
+	resultMiss        = "miss"
+	resultPlaceholder = "placeholder"
+	resultOK          = "ok"
+	resultError       = "error"
+)
+
+var (
+	requestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Total number of cache requests, partitioned by key prefix, operation and result (hit, miss, placeholder, ok, error).",
+	}, []string{"prefix", "operation", "result"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cache_request_duration_seconds",
+		Help:    "Duration of cache requests in seconds, partitioned by key prefix and operation.",
+		Buckets: []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"prefix", "operation"})
+	metricsRegisterOnce sync.Once
+)
+
+// InstrumentOption set the instrument options.
+type InstrumentOption func(*instrumentOptions)
+
+type instrumentOptions struct {
+	metrics   bool
+	tracing   bool
+	keyPrefix func(key string) string
+}
+
+func defaultInstrumentOptions() *instrumentOptions {
+	return &instrumentOptions{
+		keyPrefix: defaultKeyPrefix,
+	}
+}
+
+func (o *instrumentOptions) apply(opts ...InstrumentOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithMetrics enable prometheus metrics, the number of requests (cache_requests_total) and
+// the duration of requests (cache_request_duration_seconds) are recorded per key prefix.
+func WithMetrics() InstrumentOption {
+	return func(o *instrumentOptions) {
+		o.metrics = true
+	}
+}
+
+// WithTracing enable opentelemetry tracing, a span is created for each request via pkg/tracer.
+func WithTracing() InstrumentOption {
+	return func(o *instrumentOptions) {
+		o.tracing = true
+	}
+}
+
+// WithKeyPrefixFunc set the function returns the prefix of the key used as the metric label,
+// default is the part before the first colon, e.g. "user" of "user:1:profile". The number of
+// the prefixes should be bounded, don't return the part containing the ids of the key.
+func WithKeyPrefixFunc(fn func(key string) string) InstrumentOption {
+	return func(o *instrumentOptions) {
+		if fn != nil {
+			o.keyPrefix = fn
+		}
+	}
+}
+
+func defaultKeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return "unknown"
+}
+
+// RequestCounter returns the counter of the cache requests (cache_requests_total), it is registered
+// to the default prometheus registry by WithMetrics, register it to the other registry if the metrics
+// are served from it, e.g. metrics.WithCounterMetrics(cache.RequestCounter()) of pkg/grpc/metrics.
+func RequestCounter() *prometheus.CounterVec {
+	return requestCounter
+}
+
+// RequestDuration returns the histogram of the duration of the cache requests (cache_request_duration_seconds),
+// e.g. metrics.WithHistogramMetrics(cache.RequestDuration()) of pkg/grpc/metrics, see RequestCounter.
+func RequestDuration() *prometheus.HistogramVec {
+	return requestDuration
+}
+
+// ----------------------------------------------------------------------------
+
+// instrumentedCache records metrics and traces of the requests of the wrapped cache
+type instrumentedCache struct {
+	cache Cache
+	o     *instrumentOptions
+}
+
+// NewInstrumentedCache wraps the cache c to record metrics and traces of the requests, it is enabled
+// by WithMetrics or WithTracing, c is returned directly if neither is set. The results of the read
+// requests are hit, miss, placeholder (the placeholder of not found is hit) and error, the multiple
+// keys requests are labelled with the prefix of the first key.
+func NewInstrumentedCache(c Cache, opts ...InstrumentOption) Cache {
+	o := defaultInstrumentOptions()
+	o.apply(opts...)
+	if !o.metrics && !o.tracing {
+		return c
+	}
+	if o.metrics {
+		metricsRegisterOnce.Do(func() {
+			_ = prometheus.Register(requestCounter)
+			_ = prometheus.Register(requestDuration)
+		})
+	}
+	return &instrumentedCache{cache: c, o: o}
+}
+
+// cacheRequest is a cache request being instrumented
+type cacheRequest struct {
+	o         *instrumentOptions
+	prefix    string
+	operation string
+	start     time.Time
+	span      trace.Span
+}
+
+func (c *instrumentedCache) start(ctx context.Context, operation string, keys []string) (context.Context, *cacheRequest) {
+	r := &cacheRequest{o: c.o, operation: operation, start: time.Now(), prefix: "unknown"}
+	if len(keys) > 0 {
+		r.prefix = c.o.keyPrefix(keys[0])
+	}
+	if c.o.tracing {
+		tags := map[string]interface{}{"cache.operation": operation, "cache.prefix": r.prefix}
+		if len(keys) == 1 {
+			tags["cache.key"] = keys[0]
+		} else {
+			tags["cache.keys"] = len(keys)
+		}
+		ctx, r.span = tracer.NewSpan(ctx, "cache."+operation, tags)
+	}
+	return ctx, r
+}
+
+// end records the result of the request, the results of multiple keys are counted separately.
+func (r *cacheRequest) end(err error, results map[string]int) {
+	if r.o.metrics {
+		requestDuration.WithLabelValues(r.prefix, r.operation).Observe(time.Since(r.start).Seconds())
+		for result, n := range results {
+			if n > 0 {
+				requestCounter.WithLabelValues(r.prefix, r.operation, result).Add(float64(n))
+			}
+		}
+	}
+
+	if r.span != nil {
+		for result, n := range results {
+			r.span.SetAttributes(attribute.Int("cache."+result, n))
+		}
+		if err != nil && results[resultError] > 0 {
+			r.span.RecordError(err)
+			r.span.SetStatus(codes.Error, err.Error())
+		}
+		r.span.End()
+	}
+}
+
+// readResult returns the result of the read request
+func readResult(err error) string {
+	switch {
+	case err == nil:
+		return resultHit
+	case errors.Is(err, CacheNotFound):
+		return resultMiss
+	case errors.Is(err, ErrPlaceholder):
+		return resultPlaceholder
+	default:
+		return resultError
+	}
+}
+
+// writeResult returns the result of the write request
+func writeResult(err error) map[string]int {
+	if err != nil {
+		return map[string]int{resultError: 1}
+	}
+	return map[string]int{resultOK: 1}
+}
+
+// Set one value
+func (c *instrumentedCache) Set(ctx context.Context, key string, val interface{}, expiration time.Duration) error {
+	ctx, r := c.start(ctx, "Set", []string{key})
+	err := c.cache.Set(ctx, key, val, expiration)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// Get one value
+func (c *instrumentedCache) Get(ctx context.Context, key string, val interface{}) error {
+	ctx, r := c.start(ctx, "Get", []string{key})
+	err := c.cache.Get(ctx, key, val)
+	r.end(err, map[string]int{readResult(err): 1})
+	return err
+}
+
+// MultiSet set multiple values
+func (c *instrumentedCache) MultiSet(ctx context.Context, valMap map[string]interface{}, expiration time.Duration) error {
+	keys := make([]string, 0, len(valMap))
+	for key := range valMap {
+		keys = append(keys, key)
+	}
+	ctx, r := c.start(ctx, "MultiSet", keys)
+	err := c.cache.MultiSet(ctx, valMap, expiration)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// MultiGet get multiple values, the number of hits is the number of values added to the map,
+// the placeholders are counted as misses.
+func (c *instrumentedCache) MultiGet(ctx context.Context, keys []string, valueMap interface{}) error {
+	ctx, r := c.start(ctx, "MultiGet", keys)
+	before := mapLen(valueMap)
+	err := c.cache.MultiGet(ctx, keys, valueMap)
+	if err != nil {
+		r.end(err, map[string]int{resultError: 1})
+		return err
+	}
+	hits := mapLen(valueMap) - before
+	r.end(nil, map[string]int{resultHit: hits, resultMiss: len(keys) - hits})
+	return nil
+}
+
+func mapLen(m interface{}) int {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return 0
+	}
+	return v.Len()
+}
+
+// Del delete multiple values
+func (c *instrumentedCache) Del(ctx context.Context, keys ...string) error {
+	ctx, r := c.start(ctx, "Del", keys)
+	err := c.cache.Del(ctx, keys...)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// SetCacheWithNotFound set placeholder value of the key
+func (c *instrumentedCache) SetCacheWithNotFound(ctx context.Context, key string) error {
+	ctx, r := c.start(ctx, "SetCacheWithNotFound", []string{key})
+	err := c.cache.SetCacheWithNotFound(ctx, key)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// SetWithTags set one value with tags, returns ErrTagNotSupported if the wrapped cache does not implement TagCache
+func (c *instrumentedCache) SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error {
+	tc, ok := c.cache.(TagCache)
+	if !ok {
+		return ErrTagNotSupported
+	}
+	ctx, r := c.start(ctx, "SetWithTags", []string{key})
+	err := tc.SetWithTags(ctx, key, val, expiration, tags...)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// InvalidateTags delete the values of the tags, returns ErrTagNotSupported if the wrapped cache does not implement TagCache
+func (c *instrumentedCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	tc, ok := c.cache.(TagCache)
+	if !ok {
+		return ErrTagNotSupported
+	}
+	ctx, r := c.start(ctx, "InvalidateTags", tags)
+	err := tc.InvalidateTags(ctx, tags...)
+	r.end(err, writeResult(err))
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// Instrument returns a type-safe cache that records metrics and traces of the requests,
+// see NewInstrumentedCache.
+func (t *Typed[T]) Instrument(opts ...InstrumentOption) *Typed[T] {
+	return NewTyped[T](NewInstrumentedCache(t.cache, opts...), t.keyPrefix)
+}
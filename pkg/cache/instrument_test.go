@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+func TestNewInstrumentedCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	rc := NewRedisCache(client, "", encoding.JSONEncoding{}, func() interface{} { return &redisUser{} })
+
+	// disabled
+	assert.Equal(t, rc, NewInstrumentedCache(rc))
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx := context.Background()
+	requestCounter.Reset()
+	c := NewInstrumentedCache(rc, WithMetrics(), WithTracing(), WithKeyPrefixFunc(nil))
+	count := func(operation string, result string) float64 {
+		return testutil.ToFloat64(requestCounter.WithLabelValues("instrument", operation, result))
+	}
+
+	assert.NoError(t, c.Set(ctx, "instrument:user:1", &redisUser{ID: 1, Name: "foo"}, time.Hour))
+	assert.NoError(t, c.Get(ctx, "instrument:user:1", &redisUser{}))
+	assert.ErrorIs(t, c.Get(ctx, "instrument:user:2", &redisUser{}), CacheNotFound)
+	assert.NoError(t, c.SetCacheWithNotFound(ctx, "instrument:user:3"))
+	assert.ErrorIs(t, c.Get(ctx, "instrument:user:3", &redisUser{}), ErrPlaceholder)
+	assert.Equal(t, 1.0, count("Set", resultOK))
+	assert.Equal(t, 1.0, count("Get", resultHit))
+	assert.Equal(t, 1.0, count("Get", resultMiss))
+	assert.Equal(t, 1.0, count("Get", resultPlaceholder))
+
+	assert.NoError(t, c.MultiSet(ctx, map[string]interface{}{"instrument:user:4": &redisUser{ID: 4}}, time.Hour))
+	vals := make(map[string]*redisUser)
+	assert.NoError(t, c.MultiGet(ctx, []string{"instrument:user:1", "instrument:user:2", "instrument:user:4"}, vals))
+	assert.Equal(t, 2.0, count("MultiGet", resultHit))
+	assert.Equal(t, 1.0, count("MultiGet", resultMiss))
+	assert.NoError(t, c.Del(ctx, "instrument:user:1"))
+	assert.Equal(t, 1.0, count("Del", resultOK))
+
+	// tags
+	tc := c.(TagCache)
+	assert.NoError(t, tc.SetWithTags(ctx, "instrument:user:5", &redisUser{ID: 5}, time.Hour, "tenant:1"))
+	assert.NoError(t, tc.InvalidateTags(ctx, "tenant:1"))
+	assert.ErrorIs(t, NewInstrumentedCache(&noTagCache{rc}, WithMetrics()).(TagCache).InvalidateTags(ctx, "t"), ErrTagNotSupported)
+
+	// error
+	mr.Close()
+	assert.Error(t, c.Get(ctx, "instrument:user:1", &redisUser{}))
+	assert.Equal(t, 1.0, count("Get", resultError))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 11)
+	assert.Equal(t, "cache.Set", spans[0].Name)
+	assert.Equal(t, codes.Unset, spans[2].Status.Code) // miss is not an error
+	assert.Equal(t, codes.Error, spans[len(spans)-1].Status.Code)
+}
+
+func TestTypedInstrument(t *testing.T) {
+	ctx := context.Background()
+	requestCounter.Reset()
+	c := NewTypedMemoryCache[*redisUser]("", encoding.JSONEncoding{}).Instrument(WithMetrics())
+	assert.NoError(t, c.Set(ctx, "typed:user:1", &redisUser{ID: 1}, time.Hour))
+	_, err := c.Get(ctx, "typed:user:1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(RequestCounter().WithLabelValues("typed", "Get", resultHit)))
+	assert.NoError(t, c.InvalidateTags(ctx, "t"))
+	assert.Equal(t, "unknown", defaultKeyPrefix("token"))
+	assert.Equal(t, "user", defaultKeyPrefix("user:1:profile"))
+	assert.NotNil(t, RequestDuration())
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound default error of the data not found in the data source.
+var ErrNotFound = errors.New("cache: data not found")
+
+// max number of keys tracked for early refresh of each loader
+const maxTrackedKeys = 100000
+
+// LoaderOption set the loader options.
+type LoaderOption func(*loaderOptions)
+
+type loaderOptions struct {
+	expiration   time.Duration
+	jitter       float64
+	beta         float64
+	notFoundErr  error
+	errorHandler func(ctx context.Context, key string, err error)
+}
+
+func defaultLoaderOptions() *loaderOptions {
+	return &loaderOptions{
+		expiration:  DefaultExpireTime,
+		jitter:      0.1,
+		beta:        1.0,
+		notFoundErr: ErrNotFound,
+	}
+}
+
+func (o *loaderOptions) apply(opts ...LoaderOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithLoaderExpiration set the expiration of the loaded data, default is DefaultExpireTime.
+func WithLoaderExpiration(d time.Duration) LoaderOption {
+	return func(o *loaderOptions) {
+		if d > 0 {
+			o.expiration = d
+		}
+	}
+}
+
+// WithExpirationJitter set the ratio of random jitter added to the expiration, the keys loaded at the
+// same time don't expire at the same time, e.g. 0.1 means expiration + [0, 10%) * expiration, default is 0.1.
+func WithExpirationJitter(ratio float64) LoaderOption {
+	return func(o *loaderOptions) {
+		if ratio >= 0 {
+			o.jitter = ratio
+		}
+	}
+}
+
+// WithEarlyRefresh set the beta of early probabilistic refresh (XFetch), the hot key is refreshed in
+// background before it expires, the probability increases as the expiration approaches and the load
+// takes longer, the larger beta refreshes earlier, 0 means disable, default is 1.0.
+func WithEarlyRefresh(beta float64) LoaderOption {
+	return func(o *loaderOptions) {
+		if beta >= 0 {
+			o.beta = beta
+		}
+	}
+}
+
+// WithNotFoundError set the error returned by the load function when the data is not found,
+// a placeholder is cached to prevent cache penetration, and the error is returned when the
+// placeholder is hit, e.g. gorm.ErrRecordNotFound, default is ErrNotFound.
+func WithNotFoundError(err error) LoaderOption {
+	return func(o *loaderOptions) {
+		if err != nil {
+			o.notFoundErr = err
+		}
+	}
+}
+
+// WithLoaderErrorHandler set the function called when writing to cache fails, the error doesn't
+// affect the result of Get, e.g. print a warning log.
+func WithLoaderErrorHandler(fn func(ctx context.Context, key string, err error)) LoaderOption {
+	return func(o *loaderOptions) {
+		o.errorHandler = fn
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Loader is a read-through loader, it gets the data from cache, and loads it from the data source
+// when the cache is missed, the concurrent misses of the same key are merged into one load.
+type Loader[K comparable, V any] struct {
+	cache  Cache
+	keyFn  func(key K) string
+	loadFn func(ctx context.Context, key K) (V, error)
+	group  singleflight.Group
+	o      *loaderOptions
+
+	mu     sync.Mutex
+	tracks map[string]*loadTrack // cache key and load track mapping, used by early refresh
+}
+
+type loadTrack struct {
+	expireAt time.Time
+	delta    time.Duration // time taken to load the data
+}
+
+// NewLoader create a read-through loader, keyFn returns the cache key of the key, loadFn loads the data from
+// the data source, e.g. database, and returns the error set by WithNotFoundError if the data is not found.
+func NewLoader[K comparable, V any](c Cache, keyFn func(key K) string,
+	loadFn func(ctx context.Context, key K) (V, error), opts ...LoaderOption) *Loader[K, V] {
+	o := defaultLoaderOptions()
+	o.apply(opts...)
+	return &Loader[K, V]{
+		cache:  c,
+		keyFn:  keyFn,
+		loadFn: loadFn,
+		o:      o,
+		tracks: make(map[string]*loadTrack),
+	}
+}
+
+// Get the data from cache, load from the data source if the cache is missed, the not found error
+// is returned if the data is not found or the placeholder is hit.
+func (l *Loader[K, V]) Get(ctx context.Context, key K) (V, error) {
+	var val V
+	cacheKey := l.keyFn(key)
+	err := l.cache.Get(ctx, cacheKey, &val)
+	switch {
+	case err == nil:
+		if l.shouldRefresh(cacheKey) {
+			go l.load(context.WithoutCancel(ctx), key, cacheKey) //nolint
+		}
+		return val, nil
+	case errors.Is(err, ErrPlaceholder):
+		return val, l.o.notFoundErr
+	case errors.Is(err, CacheNotFound):
+		return l.load(ctx, key, cacheKey)
+	default:
+		return val, err
+	}
+}
+
+// load the data from the data source and write to cache, the concurrent loads of the same key are merged.
+func (l *Loader[K, V]) load(ctx context.Context, key K, cacheKey string) (V, error) {
+	v, err, _ := l.group.Do(cacheKey, func() (interface{}, error) {
+		start := time.Now()
+		val, err := l.loadFn(ctx, key)
+		if err != nil {
+			if errors.Is(err, l.o.notFoundErr) {
+				// set placeholder cache to prevent cache penetration
+				if setErr := l.cache.SetCacheWithNotFound(ctx, cacheKey); setErr != nil {
+					l.handleError(ctx, cacheKey, setErr)
+				}
+			}
+			return nil, err
+		}
+
+		expiration := l.expiration()
+		if setErr := l.cache.Set(ctx, cacheKey, val, expiration); setErr != nil {
+			l.handleError(ctx, cacheKey, setErr)
+		} else {
+			l.track(cacheKey, time.Since(start), expiration)
+		}
+		return val, nil
+	})
+
+	val, _ := v.(V)
+	return val, err
+}
+
+func (l *Loader[K, V]) handleError(ctx context.Context, cacheKey string, err error) {
+	if l.o.errorHandler != nil {
+		l.o.errorHandler(ctx, cacheKey, err)
+	}
+}
+
+// expiration returns the expiration with random jitter.
+func (l *Loader[K, V]) expiration() time.Duration {
+	if l.o.jitter <= 0 {
+		return l.o.expiration
+	}
+	return l.o.expiration + time.Duration(rand.Float64()*l.o.jitter*float64(l.o.expiration)) //nolint
+}
+
+// track records the expiration and load time of the key, the expired tracks are removed when it is full.
+func (l *Loader[K, V]) track(cacheKey string, delta time.Duration, expiration time.Duration) {
+	if l.o.beta <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.tracks) >= maxTrackedKeys {
+		now := time.Now()
+		for k, t := range l.tracks {
+			if now.After(t.expireAt) {
+				delete(l.tracks, k)
+			}
+		}
+		if len(l.tracks) >= maxTrackedKeys {
+			return
+		}
+	}
+	l.tracks[cacheKey] = &loadTrack{expireAt: time.Now().Add(expiration), delta: delta}
+}
+
+// shouldRefresh reports whether to refresh the key early, XFetch: now - delta * beta * ln(rand()) >= expiry,
+// the key loaded by other processes is not tracked and not refreshed early.
+func (l *Loader[K, V]) shouldRefresh(cacheKey string) bool {
+	if l.o.beta <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, ok := l.tracks[cacheKey]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.After(t.expireAt) {
+		delete(l.tracks, cacheKey)
+		return false
+	}
+	gap := -float64(t.delta) * l.o.beta * math.Log(1-rand.Float64()) //nolint
+	if now.Add(time.Duration(gap)).Before(t.expireAt) {
+		return false
+	}
+	delete(l.tracks, cacheKey) // refresh only once, it is tracked again after loaded
+	return true
+}
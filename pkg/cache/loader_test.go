@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+	"github.com/go-dev-frame/sponge/pkg/utils"
+)
+
+var errRecordNotFound = errors.New("record not found")
+
+func newTestLoader(t *testing.T, loadCount *int32, opts ...LoaderOption) (*Loader[uint64, *redisUser], *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	c := NewRedisCache(client, "", encoding.JSONEncoding{}, func() interface{} {
+		return &redisUser{}
+	})
+
+	keyFn := func(id uint64) string {
+		return "user:" + utils.Uint64ToStr(id)
+	}
+	loadFn := func(ctx context.Context, id uint64) (*redisUser, error) {
+		atomic.AddInt32(loadCount, 1)
+		time.Sleep(time.Millisecond * 50)
+		if id > 100 {
+			return nil, errRecordNotFound
+		}
+		return &redisUser{ID: id, Name: "foo"}, nil
+	}
+	return NewLoader(c, keyFn, loadFn, opts...), mr
+}
+
+func TestLoader(t *testing.T) {
+	var loadCount int32
+	l, mr := newTestLoader(t, &loadCount,
+		WithLoaderExpiration(time.Minute),
+		WithExpirationJitter(0.5),
+		WithEarlyRefresh(0),
+		WithNotFoundError(errRecordNotFound),
+	)
+	ctx := context.Background()
+
+	// concurrent misses of the same key are merged into one load
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := l.Get(ctx, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", user.Name)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loadCount))
+	ttl := mr.TTL("user:1")
+	assert.GreaterOrEqual(t, ttl, time.Minute)
+	assert.Less(t, ttl, time.Second*90)
+
+	// get from cache
+	user, err := l.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), user.ID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loadCount))
+
+	// not found, the placeholder is cached
+	_, err = l.Get(ctx, 101)
+	assert.ErrorIs(t, err, errRecordNotFound)
+	_, err = l.Get(ctx, 101)
+	assert.ErrorIs(t, err, errRecordNotFound)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loadCount))
+	assert.Equal(t, NotFoundPlaceholder, mustGet(t, mr, "user:101"))
+
+	// cache error
+	mr.Close()
+	_, err = l.Get(ctx, 2)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loadCount))
+}
+
+func TestLoaderEarlyRefresh(t *testing.T) {
+	var loadCount int32
+	var cacheErrCount int32
+	l, mr := newTestLoader(t, &loadCount,
+		WithLoaderExpiration(time.Minute),
+		WithEarlyRefresh(1e6), // refresh on the first hit
+		WithLoaderErrorHandler(func(ctx context.Context, key string, err error) {
+			atomic.AddInt32(&cacheErrCount, 1)
+		}),
+	)
+	ctx := context.Background()
+
+	_, err := l.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loadCount))
+
+	// the key is refreshed in background, the cached data is returned
+	user, err := l.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", user.Name)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loadCount))
+
+	// the not found error is ErrNotFound by default, the load error is returned
+	_, err = l.Get(ctx, 101)
+	assert.ErrorIs(t, err, errRecordNotFound)
+	assert.False(t, mr.Exists("user:101"))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&cacheErrCount))
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	val, err := mr.Get(key)
+	assert.NoError(t, err)
+	return val
+}
@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+// ErrTagNotSupported the cache does not support tags
+var ErrTagNotSupported = errors.New("cache: tag not supported")
+
+// TagCache is the cache that supports tags, the keys set with the same tag can be deleted together,
+// e.g. tag the list and detail caches of a tenant with "tenant:1", and invalidate them when the tenant changes.
+// It is implemented by the redis, redis cluster, memory and two-level caches.
+type TagCache interface {
+	SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// BuildTagKey construct the key of the tag which holds the cache keys of the tag, the tag is wrapped
+// in a hash tag, e.g. tag:{tenant:1}, so the keys with the same hash tag, e.g. user:{tenant:1}:1, are
+// in the same slot as the tag key in redis cluster.
+func BuildTagKey(keyPrefix string, tag string) (string, error) {
+	if tag == "" {
+		return "", errors.New("[cache] tag should not be empty")
+	}
+	return BuildCacheKey(keyPrefix, "tag:{"+tag+"}")
+}
+
+func buildTagKeys(keyPrefix string, tags []string) ([]string, error) {
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKey, err := BuildTagKey(keyPrefix, tag)
+		if err != nil {
+			return nil, err
+		}
+		tagKeys = append(tagKeys, tagKey)
+	}
+	return tagKeys, nil
+}
+
+// encodeValue returns the cache key and the encoded value
+func encodeValue(e encoding.Encoding, keyPrefix string, key string, val interface{}) (string, []byte, error) {
+	buf, err := encoding.Marshal(e, val)
+	if err != nil {
+		return "", nil, fmt.Errorf("encoding.Marshal error: %v, key=%s, val=%+v ", err, key, val)
+	}
+	cacheKey, err := BuildCacheKey(keyPrefix, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+	}
+	if len(buf) == 0 {
+		buf = NotFoundPlaceholderBytes
+	}
+	return cacheKey, buf, nil
+}
+
+// ----------------------------------------------------------------------------
+
+var (
+	// add the cache key to the tag set, the tag set lives as long as the longest-lived key,
+	// ARGV[2] is the expiration in milliseconds, 0 means never expire
+	addTagScript = redis.NewScript(`
+local exists = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local expiration = tonumber(ARGV[2])
+if expiration <= 0 then
+	return redis.call("PERSIST", KEYS[1])
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if exists == 0 or (ttl >= 0 and ttl < expiration) then
+	return redis.call("PEXPIRE", KEYS[1], expiration)
+end
+return 0`)
+
+	// return the cache keys of the tag and delete the tag set
+	popTagScript = redis.NewScript(`
+local keys = redis.call("SMEMBERS", KEYS[1])
+redis.call("DEL", KEYS[1])
+return keys`)
+)
+
+// redisSetWithTags adds the cache key to the tag sets, and sets the value, only one key is
+// accessed by each command, so it works with redis cluster.
+func redisSetWithTags(ctx context.Context, client redis.UniversalClient, cacheKey string, buf []byte,
+	expiration time.Duration, tagKeys []string) error {
+	pipeline := client.Pipeline()
+	for _, tagKey := range tagKeys {
+		addTagScript.Eval(ctx, pipeline, []string{tagKey}, cacheKey, expiration.Milliseconds())
+	}
+	pipeline.Set(ctx, cacheKey, buf, expiration)
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("pipeline.Exec error: %v, cacheKey=%s, tagKeys=%+v", err, cacheKey, tagKeys)
+	}
+	return nil
+}
+
+// redisInvalidateTags deletes the tag sets and the cache keys of the tags, returns the deleted cache keys,
+// the keys are deleted one by one, so they don't have to be in the same slot of redis cluster.
+func redisInvalidateTags(ctx context.Context, client redis.UniversalClient, tagKeys []string) ([]string, error) {
+	var cacheKeys []string
+	for _, tagKey := range tagKeys {
+		keys, err := popTagScript.Run(ctx, client, []string{tagKey}).StringSlice()
+		if err != nil {
+			return nil, fmt.Errorf("pop tag error: %v, tagKey=%s", err, tagKey)
+		}
+		cacheKeys = append(cacheKeys, keys...)
+	}
+	if len(cacheKeys) == 0 {
+		return nil, nil
+	}
+
+	pipeline := client.Pipeline()
+	for _, cacheKey := range cacheKeys {
+		pipeline.Del(ctx, cacheKey)
+	}
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline.Exec error: %v, keys=%+v", err, cacheKeys)
+	}
+	return cacheKeys, nil
+}
+
+// SetWithTags set one value with tags
+func (c *redisCache) SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error {
+	cacheKey, buf, err := encodeValue(c.encoding, c.KeyPrefix, key, val)
+	if err != nil {
+		return err
+	}
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	return redisSetWithTags(ctx, c.client, cacheKey, buf, expiration, tagKeys)
+}
+
+// InvalidateTags delete the values of the tags
+func (c *redisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	_, err = redisInvalidateTags(ctx, c.client, tagKeys)
+	return err
+}
+
+// SetWithTags set one value with tags
+func (c *redisClusterCache) SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error {
+	cacheKey, buf, err := encodeValue(c.encoding, c.KeyPrefix, key, val)
+	if err != nil {
+		return err
+	}
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	return redisSetWithTags(ctx, c.client, cacheKey, buf, expiration, tagKeys)
+}
+
+// InvalidateTags delete the values of the tags
+func (c *redisClusterCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	_, err = redisInvalidateTags(ctx, c.client, tagKeys)
+	return err
+}
+
+// SetWithTags set one value with tags, the local cache of all the replicas is invalidated
+func (c *tieredCache) SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error {
+	cacheKey, buf, err := encodeValue(c.encoding, c.KeyPrefix, key, val)
+	if err != nil {
+		return err
+	}
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	err = redisSetWithTags(ctx, c.client, cacheKey, buf, expiration, tagKeys)
+	if err != nil {
+		return err
+	}
+	c.setLocal(cacheKey, buf, expiration)
+	return c.invalidator.publish(ctx, cacheKey)
+}
+
+// InvalidateTags delete the values of the tags from redis and the local cache of all the replicas
+func (c *tieredCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	tagKeys, err := buildTagKeys(c.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	cacheKeys, err := redisInvalidateTags(ctx, c.client, tagKeys)
+	if err != nil {
+		return err
+	}
+	c.delLocal(cacheKeys...)
+	return c.invalidator.publish(ctx, cacheKeys...)
+}
+
+// ----------------------------------------------------------------------------
+
+// tag indices of the memory cache clients, the memory caches sharing a client share the index
+var tagIndices sync.Map // *ristretto.Cache --> *tagIndex
+
+// interval of removing the expired keys and the tags whose keys are all expired
+const tagPruneInterval = time.Minute
+
+// tagIndex the in-memory index of tag key and cache keys
+type tagIndex struct {
+	mu        sync.Mutex
+	tags      map[string]map[string]time.Time // tag key --> cache key --> expiration time, zero means never expire
+	nextPrune time.Time
+}
+
+func getTagIndex(client *ristretto.Cache) *tagIndex {
+	v, _ := tagIndices.LoadOrStore(client, &tagIndex{tags: make(map[string]map[string]time.Time)})
+	return v.(*tagIndex)
+}
+
+// add the cache key to the tags
+func (idx *tagIndex) add(cacheKey string, expiration time.Duration, tagKeys []string) {
+	now := time.Now()
+	var expireAt time.Time
+	if expiration > 0 {
+		expireAt = now.Add(expiration)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.pruneLocked(now)
+	for _, tagKey := range tagKeys {
+		keys, ok := idx.tags[tagKey]
+		if !ok {
+			keys = make(map[string]time.Time)
+			idx.tags[tagKey] = keys
+		}
+		keys[cacheKey] = expireAt
+	}
+}
+
+// pruneLocked removes the expired keys of all the tags every tagPruneInterval, the tag is removed
+// if all its keys are expired, so the tags that are never invalidated don't grow without bound.
+func (idx *tagIndex) pruneLocked(now time.Time) {
+	if now.Before(idx.nextPrune) {
+		return
+	}
+	idx.nextPrune = now.Add(tagPruneInterval)
+	for tagKey, keys := range idx.tags {
+		for k, t := range keys {
+			if !t.IsZero() && now.After(t) {
+				delete(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			delete(idx.tags, tagKey)
+		}
+	}
+}
+
+// pop returns the cache keys of the tags, and removes the tags
+func (idx *tagIndex) pop(tagKeys []string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.pruneLocked(time.Now())
+	var cacheKeys []string
+	for _, tagKey := range tagKeys {
+		for k := range idx.tags[tagKey] {
+			cacheKeys = append(cacheKeys, k)
+		}
+		delete(idx.tags, tagKey)
+	}
+	return cacheKeys
+}
+
+// SetWithTags set one value with tags
+func (m *memoryCache) SetWithTags(ctx context.Context, key string, val interface{}, expiration time.Duration, tags ...string) error {
+	tagKeys, err := buildTagKeys(m.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	err = m.Set(ctx, key, val, expiration)
+	if err != nil {
+		return err
+	}
+	cacheKey, _ := BuildCacheKey(m.KeyPrefix, key)
+	getTagIndex(m.client).add(cacheKey, expiration, tagKeys)
+	return nil
+}
+
+// InvalidateTags delete the values of the tags
+func (m *memoryCache) InvalidateTags(_ context.Context, tags ...string) error {
+	tagKeys, err := buildTagKeys(m.KeyPrefix, tags)
+	if err != nil {
+		return err
+	}
+	for _, cacheKey := range getTagIndex(m.client).pop(tagKeys) {
+		m.client.Del(cacheKey)
+	}
+	return nil
+}
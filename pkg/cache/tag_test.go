@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+func testTagCache(t *testing.T, c Cache) {
+	ctx := context.Background()
+	tc, ok := c.(TagCache)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	assert.NoError(t, tc.SetWithTags(ctx, "user:1", &redisUser{ID: 1, Name: "foo"}, time.Hour, "tenant:1"))
+	assert.NoError(t, tc.SetWithTags(ctx, "user:list:1", &redisUser{ID: 1, Name: "foo"}, time.Minute, "tenant:1", "list"))
+	assert.NoError(t, tc.SetWithTags(ctx, "user:2", &redisUser{ID: 2, Name: "bar"}, 0, "tenant:2"))
+	assert.NoError(t, c.Set(ctx, "user:3", &redisUser{ID: 3, Name: "baz"}, time.Hour))
+
+	assert.NoError(t, tc.InvalidateTags(ctx, "tenant:1"))
+	assert.ErrorIs(t, c.Get(ctx, "user:1", &redisUser{}), CacheNotFound)
+	assert.ErrorIs(t, c.Get(ctx, "user:list:1", &redisUser{}), CacheNotFound)
+	assert.NoError(t, c.Get(ctx, "user:2", &redisUser{}))
+	assert.NoError(t, c.Get(ctx, "user:3", &redisUser{}))
+
+	// the tag is removed after invalidated
+	assert.NoError(t, tc.InvalidateTags(ctx, "tenant:1", "list"))
+	assert.NoError(t, tc.InvalidateTags(ctx, "tenant:2"))
+	assert.ErrorIs(t, c.Get(ctx, "user:2", &redisUser{}), CacheNotFound)
+	assert.NoError(t, c.Get(ctx, "user:3", &redisUser{}))
+
+	assert.Error(t, tc.SetWithTags(ctx, "user:4", &redisUser{ID: 4}, time.Hour, ""))
+	assert.Error(t, tc.InvalidateTags(ctx, ""))
+}
+
+func TestRedisCacheTags(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	c := NewRedisCache(client, "", encoding.JSONEncoding{}, func() interface{} { return &redisUser{} })
+	testTagCache(t, c)
+
+	// the tag set lives as long as the longest-lived key
+	ctx := context.Background()
+	tc := c.(TagCache)
+	assert.NoError(t, tc.SetWithTags(ctx, "a", &redisUser{ID: 1}, time.Minute, "t"))
+	assert.NoError(t, tc.SetWithTags(ctx, "b", &redisUser{ID: 2}, time.Hour, "t"))
+	assert.NoError(t, tc.SetWithTags(ctx, "c", &redisUser{ID: 3}, time.Minute, "t"))
+	assert.Equal(t, time.Hour, mr.TTL("tag:{t}"))
+	assert.NoError(t, tc.SetWithTags(ctx, "d", &redisUser{ID: 4}, 0, "t"))
+	assert.Equal(t, time.Duration(0), mr.TTL("tag:{t}"))
+	members, err := mr.Members("tag:{t}")
+	assert.NoError(t, err)
+	assert.Len(t, members, 4)
+}
+
+func TestRedisClusterCacheTags(t *testing.T) {
+	c := newRedisClusterCache()
+	defer c.Close()
+	testTagCache(t, c.ICache.(Cache))
+}
+
+func TestMemoryCacheTags(t *testing.T) {
+	c := NewMemoryCache("tags", encoding.JSONEncoding{}, func() interface{} { return &redisUser{} })
+	testTagCache(t, c)
+
+	// remove the expired keys, and the tags whose keys are all expired
+	idx := &tagIndex{tags: make(map[string]map[string]time.Time)}
+	idx.add("expired", time.Millisecond, []string{"tag:{t}", "tag:{expired}"})
+	idx.add("k1", time.Hour, []string{"tag:{t}"})
+	idx.add("k2", 0, []string{"tag:{t}"})
+	time.Sleep(time.Millisecond * 2)
+	idx.add("k3", time.Hour, []string{"tag:{t}"})
+	assert.Len(t, idx.tags, 2) // not pruned before the interval elapses
+	idx.nextPrune = time.Now()
+	idx.add("k4", time.Hour, []string{"tag:{other}"})
+	assert.Len(t, idx.tags, 2)
+	assert.NotContains(t, idx.tags, "tag:{expired}")
+	assert.ElementsMatch(t, []string{"k1", "k2", "k3"}, idx.pop([]string{"tag:{t}"}))
+}
+
+func TestTieredCacheTags(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTieredReplica(mr.Addr())
+	b := newTieredReplica(mr.Addr())
+	testTagCache(t, a)
+
+	// invalidating tags removes the local cache of other replicas
+	ctx := context.Background()
+	assert.NoError(t, a.(TagCache).SetWithTags(ctx, "user:1", &redisUser{ID: 1, Name: "foo"}, time.Hour, "tenant:1"))
+	assert.NoError(t, b.Get(ctx, "user:1", &redisUser{}))
+	time.Sleep(time.Millisecond * 10)
+	assert.NoError(t, a.(TagCache).InvalidateTags(ctx, "tenant:1"))
+	time.Sleep(time.Millisecond * 100)
+	assert.ErrorIs(t, b.Get(ctx, "user:1", &redisUser{}), CacheNotFound)
+}
+
+func TestTypedTags(t *testing.T) {
+	ctx := context.Background()
+	c := NewTypedMemoryCache[*redisUser]("typed", encoding.JSONEncoding{})
+	assert.NoError(t, c.SetWithTags(ctx, "1", &redisUser{ID: 1}, time.Hour, "tenant:1"))
+	assert.NoError(t, c.InvalidateTags(ctx, "tenant:1"))
+	_, err := c.Get(ctx, "1")
+	assert.ErrorIs(t, err, CacheNotFound)
+
+	c = NewTyped[*redisUser](&noTagCache{c.Cache()}, "typed")
+	assert.ErrorIs(t, c.SetWithTags(ctx, "1", &redisUser{ID: 1}, time.Hour, "tenant:1"), ErrTagNotSupported)
+	assert.ErrorIs(t, c.InvalidateTags(ctx, "tenant:1"), ErrTagNotSupported)
+}
+
+type noTagCache struct {
+	Cache
+}
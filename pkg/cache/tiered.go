@@ -0,0 +1,495 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+// DefaultInvalidateChannel the redis pub/sub channel used to invalidate the local cache of other replicas
+const DefaultInvalidateChannel = "cache:invalidate"
+
+// TieredOption set the tiered cache options.
+type TieredOption func(*tieredOptions)
+
+type tieredOptions struct {
+	localExpiration time.Duration
+	channel         string
+	local           *ristretto.Cache
+}
+
+func defaultTieredOptions() *tieredOptions {
+	return &tieredOptions{
+		localExpiration: time.Second * 30,
+		channel:         DefaultInvalidateChannel,
+	}
+}
+
+func (o *tieredOptions) apply(opts ...TieredOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithLocalExpiration set the max expiration of the local cache, it is the max time of reading
+// stale data if an invalidation message is lost, default is 30s.
+func WithLocalExpiration(d time.Duration) TieredOption {
+	return func(o *tieredOptions) {
+		if d > 0 {
+			o.localExpiration = d
+		}
+	}
+}
+
+// WithInvalidateChannel set the redis pub/sub channel of invalidation, default is "cache:invalidate".
+func WithInvalidateChannel(channel string) TieredOption {
+	return func(o *tieredOptions) {
+		if channel != "" {
+			o.channel = channel
+		}
+	}
+}
+
+// WithLocalCache set the local cache client, default is the global memory cache client.
+func WithLocalCache(local *ristretto.Cache) TieredOption {
+	return func(o *tieredOptions) {
+		if local != nil {
+			o.local = local
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// tieredCache two-level cache object, reads the local cache first, falls back to redis
+type tieredCache struct {
+	local           *ristretto.Cache
+	client          redis.UniversalClient
+	KeyPrefix       string
+	encoding        encoding.Encoding
+	newObject       func() interface{}
+	localExpiration time.Duration
+	invalidator     *invalidator
+	closeOnce       sync.Once
+}
+
+// NewTieredCache new a two-level cache, the local cache (ristretto) is read first, falls back to redis,
+// and the local cache is populated with a short expiration. Set and Del write redis and invalidate the
+// local cache of all the replicas via redis pub/sub. The returned cache implements io.Closer, close it
+// to unsubscribe the invalidation channel when it is no longer used.
+func NewTieredCache(client *redis.Client, keyPrefix string, encode encoding.Encoding, newObject func() interface{}, opts ...TieredOption) Cache {
+	return newTieredCache(client, keyPrefix, encode, newObject, opts...)
+}
+
+// NewTieredClusterCache new a two-level cache with redis cluster.
+func NewTieredClusterCache(client *redis.ClusterClient, keyPrefix string, encode encoding.Encoding, newObject func() interface{}, opts ...TieredOption) Cache {
+	return newTieredCache(client, keyPrefix, encode, newObject, opts...)
+}
+
+func newTieredCache(client redis.UniversalClient, keyPrefix string, encode encoding.Encoding, newObject func() interface{}, opts ...TieredOption) Cache {
+	o := defaultTieredOptions()
+	o.apply(opts...)
+	if o.local == nil {
+		o.local = GetGlobalMemoryCli()
+	}
+
+	return &tieredCache{
+		local:           o.local,
+		client:          client,
+		KeyPrefix:       keyPrefix,
+		encoding:        encode,
+		newObject:       newObject,
+		localExpiration: o.localExpiration,
+		invalidator:     getInvalidator(client, o.channel, o.local),
+	}
+}
+
+// setLocal sets the value written to redis to the local cache.
+func (c *tieredCache) setLocal(cacheKey string, data []byte, expiration time.Duration) {
+	if expiration <= 0 || expiration > c.localExpiration {
+		expiration = c.localExpiration
+	}
+	c.invalidator.update([]string{cacheKey}, func() {
+		c.local.SetWithTTL(cacheKey, data, 0, expiration)
+	})
+}
+
+// cacheLocal sets the value read from redis to the local cache, it is skipped if the key is changed
+// or invalidated after the generation was got before reading redis, the value read may be stale.
+func (c *tieredCache) cacheLocal(generation uint64, cacheKey string, data []byte) {
+	c.invalidator.updateIfUnchanged(cacheKey, generation, func() {
+		c.local.SetWithTTL(cacheKey, data, 0, c.localExpiration)
+	})
+}
+
+// delLocal deletes the keys from the local cache.
+func (c *tieredCache) delLocal(cacheKeys ...string) {
+	c.invalidator.update(cacheKeys, func() {
+		for _, cacheKey := range cacheKeys {
+			c.local.Del(cacheKey)
+		}
+	})
+}
+
+// Close unsubscribes the invalidation channel if it is not used by other tiered caches.
+func (c *tieredCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.invalidator.release()
+	})
+	return err
+}
+
+func (c *tieredCache) decode(key string, cacheKey string, dataBytes []byte, val interface{}) error {
+	// prevent Unmarshal from reporting an error if data is empty
+	if len(dataBytes) == 0 || bytes.Equal(dataBytes, NotFoundPlaceholderBytes) {
+		return ErrPlaceholder
+	}
+	err := encoding.Unmarshal(c.encoding, dataBytes, val)
+	if err != nil {
+		return fmt.Errorf("encoding.Unmarshal error: %v, key=%s, cacheKey=%s, type=%T, json=%s ",
+			err, key, cacheKey, val, dataBytes)
+	}
+	return nil
+}
+
+// Set one value
+func (c *tieredCache) Set(ctx context.Context, key string, val interface{}, expiration time.Duration) error {
+	buf, err := encoding.Marshal(c.encoding, val)
+	if err != nil {
+		return fmt.Errorf("encoding.Marshal error: %v, key=%s, val=%+v ", err, key, val)
+	}
+	cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+	if err != nil {
+		return fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+	}
+	if len(buf) == 0 {
+		buf = NotFoundPlaceholderBytes
+	}
+
+	err = c.client.Set(ctx, cacheKey, buf, expiration).Err()
+	if err != nil {
+		return fmt.Errorf("c.client.Set error: %v, cacheKey=%s", err, cacheKey)
+	}
+	c.setLocal(cacheKey, buf, expiration)
+	return c.invalidator.publish(ctx, cacheKey)
+}
+
+// Get one value, reads the local cache first, falls back to redis
+func (c *tieredCache) Get(ctx context.Context, key string, val interface{}) error {
+	cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+	if err != nil {
+		return fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+	}
+
+	if data, ok := c.local.Get(cacheKey); ok {
+		if dataBytes, isBytes := data.([]byte); isBytes {
+			return c.decode(key, cacheKey, dataBytes, val)
+		}
+	}
+
+	generation := c.invalidator.generation(cacheKey)
+	dataBytes, err := c.client.Get(ctx, cacheKey).Bytes()
+	// NOTE: don't handle the case where redis value is nil
+	// but leave it to the upstream for processing
+	if err != nil {
+		return err
+	}
+	c.cacheLocal(generation, cacheKey, dataBytes)
+	return c.decode(key, cacheKey, dataBytes, val)
+}
+
+// MultiSet set multiple values
+func (c *tieredCache) MultiSet(ctx context.Context, valueMap map[string]interface{}, expiration time.Duration) error {
+	if len(valueMap) == 0 {
+		return nil
+	}
+
+	values := make(map[string][]byte, len(valueMap))
+	pipeline := c.client.Pipeline()
+	for key, value := range valueMap {
+		buf, err := encoding.Marshal(c.encoding, value)
+		if err != nil {
+			return fmt.Errorf("encoding.Marshal error: %v, key=%s, val=%+v ", err, key, value)
+		}
+		cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+		if err != nil {
+			return fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+		}
+		if len(buf) == 0 {
+			buf = NotFoundPlaceholderBytes
+		}
+		values[cacheKey] = buf
+		pipeline.Set(ctx, cacheKey, buf, expiration)
+	}
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("pipeline.Exec error: %v", err)
+	}
+
+	cacheKeys := make([]string, 0, len(values))
+	for cacheKey, buf := range values {
+		c.setLocal(cacheKey, buf, expiration)
+		cacheKeys = append(cacheKeys, cacheKey)
+	}
+	return c.invalidator.publish(ctx, cacheKeys...)
+}
+
+// MultiGet get multiple values, the key in valueMap is the cache key, the same as redis cache
+func (c *tieredCache) MultiGet(ctx context.Context, keys []string, value interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	valueMap := reflect.ValueOf(value)
+	setValue := func(cacheKey string, dataBytes []byte) {
+		if len(dataBytes) == 0 || bytes.Equal(dataBytes, NotFoundPlaceholderBytes) {
+			return
+		}
+		object := c.newObject()
+		if err := encoding.Unmarshal(c.encoding, dataBytes, object); err != nil {
+			fmt.Printf("unmarshal data error: %+v, cacheKey=%s valueType=%T\n", err, cacheKey, value)
+			return
+		}
+		valueMap.SetMapIndex(reflect.ValueOf(cacheKey), reflect.ValueOf(object))
+	}
+
+	// read the local cache first, and read the missed keys from redis
+	var missedKeys []string
+	for _, key := range keys {
+		cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+		if err != nil {
+			return fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+		}
+		if data, ok := c.local.Get(cacheKey); ok {
+			if dataBytes, isBytes := data.([]byte); isBytes {
+				setValue(cacheKey, dataBytes)
+				continue
+			}
+		}
+		missedKeys = append(missedKeys, cacheKey)
+	}
+	if len(missedKeys) == 0 {
+		return nil
+	}
+
+	// pipeline instead of MGET, the keys may be in different slots of redis cluster
+	pipeline := c.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(missedKeys))
+	generations := make([]uint64, len(missedKeys))
+	for i, cacheKey := range missedKeys {
+		generations[i] = c.invalidator.generation(cacheKey)
+		cmds[i] = pipeline.Get(ctx, cacheKey)
+	}
+	_, err := pipeline.Exec(ctx)
+	if err != nil && err != redis.Nil { //nolint
+		return fmt.Errorf("pipeline.Exec error: %v, keys=%+v", err, missedKeys)
+	}
+	for i, cmd := range cmds {
+		dataBytes, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		c.cacheLocal(generations[i], missedKeys[i], dataBytes)
+		setValue(missedKeys[i], dataBytes)
+	}
+	return nil
+}
+
+// Del delete multiple values from redis and the local cache of all the replicas
+func (c *tieredCache) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	cacheKeys := make([]string, 0, len(keys))
+	pipeline := c.client.Pipeline()
+	for _, key := range keys {
+		cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+		if err != nil {
+			continue
+		}
+		cacheKeys = append(cacheKeys, cacheKey)
+		pipeline.Del(ctx, cacheKey)
+	}
+	_, err := pipeline.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("pipeline.Exec error: %v, keys=%+v", err, cacheKeys)
+	}
+
+	c.delLocal(cacheKeys...)
+	return c.invalidator.publish(ctx, cacheKeys...)
+}
+
+// SetCacheWithNotFound set value for notfound
+func (c *tieredCache) SetCacheWithNotFound(ctx context.Context, key string) error {
+	cacheKey, err := BuildCacheKey(c.KeyPrefix, key)
+	if err != nil {
+		return fmt.Errorf("BuildCacheKey error: %v, key=%s", err, key)
+	}
+
+	err = c.client.Set(ctx, cacheKey, NotFoundPlaceholder, DefaultNotFoundExpireTime).Err()
+	if err != nil {
+		return err
+	}
+	c.setLocal(cacheKey, NotFoundPlaceholderBytes, DefaultNotFoundExpireTime)
+	return c.invalidator.publish(ctx, cacheKey)
+}
+
+// ----------------------------------------------------------------------------
+
+type invalidatorKey struct {
+	client  redis.UniversalClient
+	channel string
+	local   *ristretto.Cache
+}
+
+// the invalidators are shared by the tiered caches with the same redis client, channel and local cache,
+// so there is only one subscription for all the tiered caches, it is closed when all of them are closed.
+var (
+	invalidators   = map[invalidatorKey]*invalidator{}
+	invalidatorsMu sync.Mutex
+)
+
+// invalidator publishes the changed keys, and deletes the keys changed by other replicas from the local cache.
+type invalidator struct {
+	key     invalidatorKey
+	id      string // id of the current replica, the messages published by itself are ignored
+	client  redis.UniversalClient
+	channel string
+	local   *ristretto.Cache
+	pubsub  *redis.PubSub
+	refs    int // number of the tiered caches using the invalidator, protected by invalidatorsMu
+
+	mu   sync.Mutex
+	gens [generationStripes]uint64 // increased when the keys of the stripe are changed or invalidated
+}
+
+// number of the stripes of the generations, the keys are hashed to the stripes
+const generationStripes = 256
+
+func generationStripe(cacheKey string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(cacheKey))
+	return h.Sum32() % generationStripes
+}
+
+type invalidateMessage struct {
+	ID   string   `json:"id"`
+	Keys []string `json:"keys"`
+}
+
+func getInvalidator(client redis.UniversalClient, channel string, local *ristretto.Cache) *invalidator {
+	invalidatorsMu.Lock()
+	defer invalidatorsMu.Unlock()
+
+	key := invalidatorKey{client: client, channel: channel, local: local}
+	if i, ok := invalidators[key]; ok {
+		i.refs++
+		return i
+	}
+
+	hostname, _ := os.Hostname()
+	i := &invalidator{
+		key:     key,
+		id:      fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		client:  client,
+		channel: channel,
+		local:   local,
+		refs:    1,
+	}
+	i.subscribe()
+	invalidators[key] = i
+	return i
+}
+
+// release closes the subscription when the invalidator is not used by any tiered cache.
+func (i *invalidator) release() error {
+	invalidatorsMu.Lock()
+	defer invalidatorsMu.Unlock()
+
+	i.refs--
+	if i.refs > 0 {
+		return nil
+	}
+	delete(invalidators, i.key)
+	return i.pubsub.Close()
+}
+
+// generation returns the generation of the key, it is got before reading redis.
+func (i *invalidator) generation(cacheKey string) uint64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.gens[generationStripe(cacheKey)]
+}
+
+// update changes the local cache and increases the generations of the keys, so the values
+// of the keys being read from redis are not set to the local cache.
+func (i *invalidator) update(cacheKeys []string, fn func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, cacheKey := range cacheKeys {
+		i.gens[generationStripe(cacheKey)]++
+	}
+	fn()
+}
+
+// updateIfUnchanged changes the local cache if the generation of the key is not changed.
+func (i *invalidator) updateIfUnchanged(cacheKey string, generation uint64, fn func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.gens[generationStripe(cacheKey)] == generation {
+		fn()
+	}
+}
+
+// subscribe the channel, the subscription is re-established automatically when the connection is broken,
+// the messages published during the disconnection are lost, the stale data expires with the local expiration.
+func (i *invalidator) subscribe() {
+	pubsub := i.client.Subscribe(context.Background(), i.channel)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_, _ = pubsub.Receive(ctx) // wait for the subscription to be confirmed
+	i.pubsub = pubsub
+
+	// the channel is closed when the subscription is closed
+	go func() {
+		for msg := range pubsub.Channel() {
+			m := &invalidateMessage{}
+			if err := json.Unmarshal([]byte(msg.Payload), m); err != nil || m.ID == i.id {
+				continue
+			}
+			i.update(m.Keys, func() {
+				for _, key := range m.Keys {
+					i.local.Del(key)
+				}
+			})
+		}
+	}()
+}
+
+func (i *invalidator) publish(ctx context.Context, cacheKeys ...string) error {
+	if len(cacheKeys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(&invalidateMessage{ID: i.id, Keys: cacheKeys})
+	if err != nil {
+		return err
+	}
+	err = i.client.Publish(ctx, i.channel, data).Err()
+	if err != nil {
+		return fmt.Errorf("publish invalidation error: %v, keys=%+v", err, cacheKeys)
+	}
+	return nil
+}
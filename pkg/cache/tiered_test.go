@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+// newTieredReplica simulates a replica with its own redis client and local cache
+func newTieredReplica(addr string) Cache {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	return NewTieredCache(client, "", encoding.JSONEncoding{}, func() interface{} {
+		return &redisUser{}
+	}, WithLocalCache(InitMemory()), WithLocalExpiration(time.Minute))
+}
+
+func TestTieredCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	a := newTieredReplica(mr.Addr())
+	b := newTieredReplica(mr.Addr())
+
+	// replica b reads redis, and populates the local cache
+	err := a.Set(ctx, "user:1", &redisUser{ID: 1, Name: "foo"}, time.Hour)
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond * 100) // the invalidation is received by replica b
+	val := &redisUser{}
+	assert.NoError(t, b.Get(ctx, "user:1", val))
+	assert.Equal(t, "foo", val.Name)
+
+	// replica b reads the local cache
+	time.Sleep(time.Millisecond * 10)
+	assert.NoError(t, mr.Set("user:1", `{"ID":1,"Name":"changed"}`))
+	val = &redisUser{}
+	assert.NoError(t, b.Get(ctx, "user:1", val))
+	assert.Equal(t, "foo", val.Name)
+
+	// Set invalidates the local cache of replica b
+	err = a.Set(ctx, "user:1", &redisUser{ID: 1, Name: "bar"}, time.Hour)
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond * 100)
+	val = &redisUser{}
+	assert.NoError(t, b.Get(ctx, "user:1", val))
+	assert.Equal(t, "bar", val.Name)
+
+	// Del invalidates the local cache of replica b
+	time.Sleep(time.Millisecond * 10)
+	assert.NoError(t, a.Del(ctx, "user:1"))
+	time.Sleep(time.Millisecond * 100)
+	err = b.Get(ctx, "user:1", &redisUser{})
+	assert.ErrorIs(t, err, CacheNotFound)
+
+	// MultiSet and MultiGet
+	err = a.MultiSet(ctx, newTestData(), time.Hour)
+	assert.NoError(t, err)
+	vals := make(map[string]*redisUser)
+	assert.NoError(t, b.MultiGet(ctx, []string{"1", "2", "3"}, vals))
+	assert.Len(t, vals, 2)
+	assert.Equal(t, "foo", vals["1"].Name)
+	time.Sleep(time.Millisecond * 10)
+	vals = make(map[string]*redisUser)
+	assert.NoError(t, b.MultiGet(ctx, []string{"1", "2"}, vals)) // from local cache
+	assert.Len(t, vals, 2)
+
+	// placeholder
+	assert.NoError(t, a.SetCacheWithNotFound(ctx, "user:2"))
+	err = b.Get(ctx, "user:2", &redisUser{})
+	assert.ErrorIs(t, err, ErrPlaceholder)
+	err = a.Get(ctx, "user:2", &redisUser{})
+	assert.ErrorIs(t, err, ErrPlaceholder)
+}
+
+func TestTieredCacheError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	c := NewTieredClusterCache(client, "prefix", encoding.JSONEncoding{}, func() interface{} {
+		return &redisUser{}
+	}, WithInvalidateChannel("test:invalidate"), WithLocalExpiration(time.Second))
+
+	assert.Error(t, c.Set(ctx, "", &redisUser{}, time.Minute))
+	assert.Error(t, c.Set(ctx, "1", nil, time.Minute))
+	assert.Error(t, c.Get(ctx, "", &redisUser{}))
+	assert.Error(t, c.Get(ctx, "1", &redisUser{}))
+	assert.Error(t, c.SetCacheWithNotFound(ctx, ""))
+	assert.Error(t, c.MultiSet(ctx, map[string]interface{}{"": &redisUser{}}, time.Minute))
+	assert.Error(t, c.MultiGet(ctx, []string{""}, map[string]*redisUser{}))
+	assert.NoError(t, c.MultiSet(ctx, nil, time.Minute))
+	assert.NoError(t, c.MultiGet(ctx, nil, map[string]*redisUser{}))
+	assert.NoError(t, c.Del(ctx))
+	assert.NoError(t, c.Del(ctx, ""))
+
+	mr.Close()
+	assert.Error(t, c.Set(ctx, "1", &redisUser{}, time.Minute))
+	assert.Error(t, c.Del(ctx, "1"))
+}
+
+func TestTieredCacheStaleRead(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTieredReplica(mr.Addr()).(*tieredCache)
+	defer c.Close()
+
+	// the key is invalidated while the value is being read from redis
+	generation := c.invalidator.generation("user:1")
+	c.delLocal("user:1")
+	c.cacheLocal(generation, "user:1", []byte(`{"ID":1,"Name":"stale"}`))
+	c.local.Wait()
+	_, ok := c.local.Get("user:1")
+	assert.False(t, ok)
+
+	generation = c.invalidator.generation("user:1")
+	c.cacheLocal(generation, "user:1", []byte(`{"ID":1,"Name":"foo"}`))
+	c.local.Wait()
+	_, ok = c.local.Get("user:1")
+	assert.True(t, ok)
+}
+
+func TestTieredCacheClose(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	local := InitMemory()
+	newObject := func() interface{} { return &redisUser{} }
+	a := NewTieredCache(client, "", encoding.JSONEncoding{}, newObject, WithLocalCache(local), WithInvalidateChannel("test:close"))
+	b := NewTieredCache(client, "", encoding.JSONEncoding{}, newObject, WithLocalCache(local), WithInvalidateChannel("test:close"))
+	assert.Equal(t, 1, mr.PubSubNumSub("test:close")["test:close"])
+
+	// the subscription is shared, and closed after all the caches are closed
+	assert.NoError(t, a.(io.Closer).Close())
+	assert.NoError(t, a.(io.Closer).Close())
+	assert.Equal(t, 1, mr.PubSubNumSub("test:close")["test:close"])
+	assert.NoError(t, b.(io.Closer).Close())
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, mr.PubSubNumSub("test:close")["test:close"])
+
+	// a new subscription is created
+	c := NewTieredCache(client, "", encoding.JSONEncoding{}, newObject, WithLocalCache(local), WithInvalidateChannel("test:close"))
+	assert.Equal(t, 1, mr.PubSubNumSub("test:close")["test:close"])
+	assert.NoError(t, c.(io.Closer).Close())
+}
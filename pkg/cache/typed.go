@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+// Typed is a type-safe cache wrapper of the value type T, it wraps any Cache, no type assertion
+// is required when getting the value.
+type Typed[T any] struct {
+	cache     Cache
+	keyPrefix string
+}
+
+// NewObject returns the newObject function of type T used by the cache, e.g. NewRedisCache, NewMemoryCache.
+func NewObject[T any]() func() interface{} {
+	return func() interface{} {
+		return new(T)
+	}
+}
+
+// NewTyped wraps the cache c as a type-safe cache, the newObject of c must be NewObject[T](),
+// and keyPrefix must be the same as the keyPrefix of c.
+func NewTyped[T any](c Cache, keyPrefix string) *Typed[T] {
+	return &Typed[T]{cache: c, keyPrefix: keyPrefix}
+}
+
+// NewTypedRedisCache new a type-safe redis cache.
+func NewTypedRedisCache[T any](client *redis.Client, keyPrefix string, encode encoding.Encoding) *Typed[T] {
+	return NewTyped[T](NewRedisCache(client, keyPrefix, encode, NewObject[T]()), keyPrefix)
+}
+
+// NewTypedRedisClusterCache new a type-safe redis cluster cache.
+func NewTypedRedisClusterCache[T any](client *redis.ClusterClient, keyPrefix string, encode encoding.Encoding) *Typed[T] {
+	return NewTyped[T](NewRedisClusterCache(client, keyPrefix, encode, NewObject[T]()), keyPrefix)
+}
+
+// NewTypedMemoryCache new a type-safe memory cache.
+func NewTypedMemoryCache[T any](keyPrefix string, encode encoding.Encoding) *Typed[T] {
+	return NewTyped[T](NewMemoryCache(keyPrefix, encode, NewObject[T]()), keyPrefix)
+}
+
+// Cache returns the wrapped cache, e.g. used by NewLoader.
+func (t *Typed[T]) Cache() Cache {
+	return t.cache
+}
+
+// Set one value
+func (t *Typed[T]) Set(ctx context.Context, key string, val T, expiration time.Duration) error {
+	return t.cache.Set(ctx, key, &val, expiration)
+}
+
+// Get one value, returns CacheNotFound if the key does not exist, ErrPlaceholder if the placeholder is hit.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	var val T
+	err := t.cache.Get(ctx, key, &val)
+	return val, err
+}
+
+// MultiSet set multiple values
+func (t *Typed[T]) MultiSet(ctx context.Context, valMap map[string]T, expiration time.Duration) error {
+	if len(valMap) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(valMap))
+	for key, val := range valMap {
+		v := val
+		m[key] = &v // the encoding requires a pointer
+	}
+	return t.cache.MultiSet(ctx, m, expiration)
+}
+
+// MultiGet get multiple values, the key of the returned map is the key passed in,
+// the missed keys and the placeholders are not in the map.
+func (t *Typed[T]) MultiGet(ctx context.Context, keys []string) (map[string]T, error) {
+	itemMap := make(map[string]*T, len(keys))
+	if err := t.cache.MultiGet(ctx, keys, itemMap); err != nil {
+		return nil, err
+	}
+
+	// the map of some caches is keyed by the key with prefix
+	retMap := make(map[string]T, len(itemMap))
+	for _, key := range keys {
+		val, ok := itemMap[key]
+		if !ok && t.keyPrefix != "" {
+			cacheKey, err := BuildCacheKey(t.keyPrefix, key)
+			if err != nil {
+				continue
+			}
+			val, ok = itemMap[cacheKey]
+		}
+		if ok && val != nil {
+			retMap[key] = *val
+		}
+	}
+	return retMap, nil
+}
+
+// Del delete multiple values
+func (t *Typed[T]) Del(ctx context.Context, keys ...string) error {
+	return t.cache.Del(ctx, keys...)
+}
+
+// SetCacheWithNotFound set placeholder value of the key to prevent cache penetration
+func (t *Typed[T]) SetCacheWithNotFound(ctx context.Context, key string) error {
+	return t.cache.SetCacheWithNotFound(ctx, key)
+}
+
+// SetWithTags set one value with tags, returns ErrTagNotSupported if the wrapped cache does not implement TagCache
+func (t *Typed[T]) SetWithTags(ctx context.Context, key string, val T, expiration time.Duration, tags ...string) error {
+	tc, ok := t.cache.(TagCache)
+	if !ok {
+		return ErrTagNotSupported
+	}
+	return tc.SetWithTags(ctx, key, &val, expiration, tags...)
+}
+
+// InvalidateTags delete the values of the tags, returns ErrTagNotSupported if the wrapped cache does not implement TagCache
+func (t *Typed[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	tc, ok := t.cache.(TagCache)
+	if !ok {
+		return ErrTagNotSupported
+	}
+	return tc.InvalidateTags(ctx, tags...)
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-dev-frame/sponge/pkg/encoding"
+)
+
+func TestTyped(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	caches := map[string]*Typed[*redisUser]{
+		"redis":  NewTypedRedisCache[*redisUser](client, "test", encoding.JSONEncoding{}),
+		"memory": NewTypedMemoryCache[*redisUser]("test", encoding.JSONEncoding{}),
+	}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			assert.NotNil(t, c.Cache())
+
+			err := c.Set(ctx, "1", &redisUser{ID: 1, Name: "foo"}, time.Hour)
+			assert.NoError(t, err)
+			user, err := c.Get(ctx, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", user.Name)
+
+			err = c.MultiSet(ctx, map[string]*redisUser{
+				"2": {ID: 2, Name: "bar"},
+				"3": {ID: 3, Name: "baz"},
+			}, time.Hour)
+			assert.NoError(t, err)
+			assert.NoError(t, c.SetCacheWithNotFound(ctx, "4"))
+			users, err := c.MultiGet(ctx, []string{"1", "2", "3", "4", "5"})
+			assert.NoError(t, err)
+			assert.Len(t, users, 3)
+			assert.Equal(t, "baz", users["3"].Name)
+
+			time.Sleep(time.Millisecond * 10) // the memory cache sets the placeholder asynchronously
+			_, err = c.Get(ctx, "4")
+			assert.ErrorIs(t, err, ErrPlaceholder)
+			assert.NoError(t, c.Del(ctx, "1"))
+			_, err = c.Get(ctx, "1")
+			assert.ErrorIs(t, err, CacheNotFound)
+		})
+	}
+
+	// value type
+	s := NewTypedRedisCache[string](client, "", encoding.JSONEncoding{})
+	assert.NoError(t, s.Set(ctx, "token", "abc", time.Hour))
+	token, err := s.Get(ctx, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	tokens, err := s.MultiGet(ctx, []string{"token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", tokens["token"])
+	assert.NoError(t, s.MultiSet(ctx, nil, time.Hour))
+}
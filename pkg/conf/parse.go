@@ -17,6 +17,17 @@ import (
 
 // Parse configuration files to struct, including yaml, toml, json, etc., and turn on listening for configuration file changes if fs is not empty
 func Parse(configFile string, obj interface{}, reloads ...func()) error {
+	return parse(viper.GetViper(), configFile, obj, reloads...)
+}
+
+// ParseIsolated is the same as Parse, but uses an isolated viper instance, it does not affect
+// the configuration parsed by Parse, it is used to parse the configuration files other than
+// the service configuration file, e.g. the route table of the gateway.
+func ParseIsolated(configFile string, obj interface{}, reloads ...func()) error {
+	return parse(viper.New(), configFile, obj, reloads...)
+}
+
+func parse(vp *viper.Viper, configFile string, obj interface{}, reloads ...func()) error {
 	v := reflect.ValueOf(obj)
 	if v.Kind() != reflect.Ptr || v.IsNil() {
 		return fmt.Errorf("obj must be a non-nil pointer")
@@ -33,21 +44,21 @@ func Parse(configFile string, obj interface{}, reloads ...func()) error {
 		filename = strings.ReplaceAll(filename, "."+ext, "") // excluding suffix names
 	}
 
-	viper.AddConfigPath(filePathStr) // path
-	viper.SetConfigName(filename)    // file name
-	viper.SetConfigType(ext)         // get the configuration type from the file name
-	err = viper.ReadInConfig()
+	vp.AddConfigPath(filePathStr) // path
+	vp.SetConfigName(filename)    // file name
+	vp.SetConfigType(ext)         // get the configuration type from the file name
+	err = vp.ReadInConfig()
 	if err != nil {
 		return err
 	}
 
-	err = viper.Unmarshal(obj)
+	err = vp.Unmarshal(obj)
 	if err != nil {
 		return err
 	}
 
 	if len(reloads) > 0 {
-		watchConfig(obj, reloads...)
+		watchConfig(vp, obj, reloads...)
 	}
 
 	return nil
@@ -65,16 +76,16 @@ func ParseConfigData(data []byte, format string, obj interface{}) error {
 }
 
 // listening for profile updates
-func watchConfig(obj interface{}, reloads ...func()) {
-	viper.WatchConfig()
+func watchConfig(vp *viper.Viper, obj interface{}, reloads ...func()) {
+	vp.WatchConfig()
 
 	// Note: OnConfigChange is called twice on Windows
-	viper.OnConfigChange(func(e fsnotify.Event) {
+	vp.OnConfigChange(func(e fsnotify.Event) {
 		t := reflect.TypeOf(obj).Elem()
 		v := reflect.New(t)
 		reflect.ValueOf(obj).Elem().Set(v.Elem()) // reset object
 
-		err := viper.Unmarshal(obj)
+		err := vp.Unmarshal(obj)
 		if err != nil {
 			fmt.Println("viper.Unmarshal error: ", err)
 		} else {
@@ -82,3 +82,47 @@ func TestParseConfigData(t *testing.T) {
 	}
 	t.Log(Show(conf))
 }
+
+func TestParseIsolated(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/routes.yml"
+	_ = os.WriteFile(file, []byte("name: foo\n"), 0666)
+
+	type config struct {
+		Name string `yaml:"name" json:"name"`
+	}
+	obj := &config{}
+	reloaded := make(chan string, 10)
+	err := ParseIsolated(file, obj, func() { reloaded <- obj.Name })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Name != "foo" {
+		t.Fatalf("expected foo, got %s", obj.Name)
+	}
+
+	// does not affect the global viper
+	global := make(map[string]interface{})
+	if err = Parse("test.yml", &global); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := global["app"]; !ok {
+		t.Error("expected app in global config")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_ = os.WriteFile(file, []byte("name: bar\n"), 0666)
+	select {
+	case name := <-reloaded:
+		if name != "bar" {
+			t.Errorf("expected bar, got %s", name)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("expected reload but got timeout")
+	}
+
+	err = ParseIsolated("notfound.yml", obj)
+	if err == nil {
+		t.Error("expected error for not found file")
+	}
+}
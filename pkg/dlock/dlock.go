@@ -10,3 +10,9 @@ type Locker interface {
 	TryLock(ctx context.Context) (bool, error)
 	Close() error
 }
+
+// Extender is implemented by the lockers whose lock expires, Extend resets the expiration of the
+// lock held, false is returned if the lock is lost, it is used to hold the lock for a long time.
+type Extender interface {
+	Extend(ctx context.Context) (bool, error)
+}
@@ -0,0 +1,190 @@
+package dlock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Election is the interface of leader election, only one candidate is the leader at a time,
+// the leadership is kept by the lease (etcd) or the expiration of the key (redis) which is
+// kept alive automatically until the candidate resigns or the lease is lost.
+type Election interface {
+	// Campaign blocks until the candidate is elected as leader or the context is canceled,
+	// it returns nil immediately if the candidate is already the leader.
+	Campaign(ctx context.Context) error
+	// Resign gives up the leadership, the other candidates can be elected.
+	Resign(ctx context.Context) error
+	// Run keeps campaigning until the context is canceled, it campaigns again after the leadership
+	// is lost, and resigns before it returns, the work of the leader is started by OnElected.
+	Run(ctx context.Context) error
+	// IsLeader reports whether the candidate is the leader.
+	IsLeader() bool
+	// Leader returns the id of the current leader, empty if there is no leader.
+	Leader(ctx context.Context) (string, error)
+	// Observe returns a channel that receives the id of the new leader when the leader changes,
+	// the channel is closed when the context is canceled.
+	Observe(ctx context.Context) <-chan string
+	// Close resigns and releases the resources.
+	Close() error
+}
+
+// ElectionOption set the election options.
+type ElectionOption func(*electionOptions)
+
+type electionOptions struct {
+	candidateID   string
+	ttl           time.Duration
+	retryInterval time.Duration
+	onElected     func(ctx context.Context)
+	onRevoked     func()
+}
+
+func defaultElectionOptions() *electionOptions {
+	hostname, _ := os.Hostname()
+	return &electionOptions{
+		candidateID:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ttl:           time.Duration(defaultTTL) * time.Second,
+		retryInterval: time.Second,
+	}
+}
+
+func (o *electionOptions) apply(opts ...ElectionOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithCandidateID set the id of the candidate, default is hostname-pid.
+func WithCandidateID(id string) ElectionOption {
+	return func(o *electionOptions) {
+		if id != "" {
+			o.candidateID = id
+		}
+	}
+}
+
+// WithElectionTTL set the ttl of the leadership, the leadership is lost if it is not kept alive
+// within the ttl, e.g. the leader crashed, default is 15s, the minimum is 1s for etcd.
+func WithElectionTTL(d time.Duration) ElectionOption {
+	return func(o *electionOptions) {
+		if d > 0 {
+			o.ttl = d
+		}
+	}
+}
+
+// WithRetryInterval set the interval of campaigning again, default is 1s.
+func WithRetryInterval(d time.Duration) ElectionOption {
+	return func(o *electionOptions) {
+		if d > 0 {
+			o.retryInterval = d
+		}
+	}
+}
+
+// WithOnElected set the function called in a new goroutine when the candidate is elected as leader,
+// the ctx is canceled when the leadership is lost or resigned, the work of the leader should stop then.
+func WithOnElected(fn func(ctx context.Context)) ElectionOption {
+	return func(o *electionOptions) {
+		o.onElected = fn
+	}
+}
+
+// WithOnRevoked set the function called when the leadership is lost or resigned.
+func WithOnRevoked(fn func()) ElectionOption {
+	return func(o *electionOptions) {
+		o.onRevoked = fn
+	}
+}
+
+// leadership holds the leader state and calls the callbacks, it is shared by the elections.
+type leadership struct {
+	o *electionOptions
+
+	mu        sync.Mutex
+	leaderCtx context.Context // canceled when the leadership is lost
+	cancel    context.CancelFunc
+}
+
+// IsLeader reports whether the candidate is the leader.
+func (l *leadership) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leaderCtx != nil
+}
+
+// elected sets the candidate as leader, the returned ctx is canceled when the leadership is lost.
+func (l *leadership) elected() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.leaderCtx != nil {
+		return l.leaderCtx
+	}
+	l.leaderCtx, l.cancel = context.WithCancel(context.Background())
+	if l.o.onElected != nil {
+		go l.o.onElected(l.leaderCtx)
+	}
+	return l.leaderCtx
+}
+
+// revoked clears the leader state, it is safe to call multiple times.
+func (l *leadership) revoked() {
+	l.mu.Lock()
+	if l.leaderCtx == nil {
+		l.mu.Unlock()
+		return
+	}
+	l.cancel()
+	l.leaderCtx, l.cancel = nil, nil
+	l.mu.Unlock()
+
+	if l.o.onRevoked != nil {
+		l.o.onRevoked()
+	}
+}
+
+// lost returns a channel that is closed when the leadership is lost, nil if not the leader.
+func (l *leadership) lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.leaderCtx == nil {
+		return nil
+	}
+	return l.leaderCtx.Done()
+}
+
+// run keeps campaigning until ctx is canceled, and resigns before it returns.
+func (l *leadership) run(ctx context.Context, campaign func(ctx context.Context) error, resign func(ctx context.Context) error) error {
+	defer func() {
+		resignCtx, cancel := context.WithTimeout(context.Background(), l.o.retryInterval+time.Second)
+		defer cancel()
+		_ = resign(resignCtx)
+	}()
+
+	for {
+		if err := campaign(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(l.o.retryInterval):
+			}
+			continue
+		}
+
+		lost := l.lost()
+		if lost == nil { // lost already
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-lost:
+		}
+	}
+}
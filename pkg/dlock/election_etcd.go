@@ -0,0 +1,177 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// EtcdElection implements Election using etcd, the leadership is bound to the lease of
+// the etcd session which is kept alive automatically, a new session is created if the
+// previous one is expired.
+type EtcdElection struct {
+	leadership
+	client *clientv3.Client
+	prefix string
+
+	sessionMu sync.Mutex
+	session   *concurrency.Session
+	election  *concurrency.Election
+}
+
+// NewEtcdElection creates a new leader election using etcd, the candidates with the same prefix compete for the leadership.
+func NewEtcdElection(client *clientv3.Client, prefix string, opts ...ElectionOption) (Election, error) {
+	if client == nil {
+		return nil, errors.New("etcd client is nil")
+	}
+	if prefix == "" {
+		return nil, errors.New("prefix is empty")
+	}
+	o := defaultElectionOptions()
+	o.apply(opts...)
+	if o.ttl < time.Second {
+		o.ttl = time.Second
+	}
+	return &EtcdElection{
+		leadership: leadership{o: o},
+		client:     client,
+		prefix:     prefix,
+	}, nil
+}
+
+// getElection returns the election of the current session, a new session is created if the previous one is expired.
+func (e *EtcdElection) getElection(ctx context.Context) (*concurrency.Session, *concurrency.Election, error) {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+
+	if e.session != nil {
+		select {
+		case <-e.session.Done():
+		default:
+			return e.session, e.election, nil
+		}
+	}
+
+	// grant the lease with ctx, so it does not block when etcd is unavailable
+	lease, err := e.client.Grant(ctx, int64(e.o.ttl/time.Second))
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := concurrency.NewSession(e.client, concurrency.WithLease(lease.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	e.session, e.election = session, concurrency.NewElection(session, e.prefix)
+	return e.session, e.election, nil
+}
+
+// Campaign blocks until the candidate is elected as leader or the context is canceled.
+func (e *EtcdElection) Campaign(ctx context.Context) error {
+	if e.IsLeader() {
+		return nil
+	}
+
+	session, election, err := e.getElection(ctx)
+	if err != nil {
+		return err
+	}
+	if err = election.Campaign(ctx, e.o.candidateID); err != nil {
+		return err
+	}
+
+	leaderCtx := e.elected()
+	go func() {
+		select {
+		case <-leaderCtx.Done():
+		case <-session.Done(): // the lease is expired, e.g. etcd is unavailable
+			e.revoked()
+		}
+	}()
+	return nil
+}
+
+// Resign gives up the leadership.
+func (e *EtcdElection) Resign(ctx context.Context) error {
+	if !e.IsLeader() {
+		return nil
+	}
+	defer e.revoked()
+	_, election, err := e.getElection(ctx)
+	if err != nil {
+		return err
+	}
+	return election.Resign(ctx)
+}
+
+// Run keeps campaigning until the context is canceled, and resigns before it returns.
+func (e *EtcdElection) Run(ctx context.Context) error {
+	return e.run(ctx, e.Campaign, e.Resign)
+}
+
+// Leader returns the id of the current leader, empty if there is no leader.
+func (e *EtcdElection) Leader(ctx context.Context) (string, error) {
+	_, election, err := e.getElection(ctx)
+	if err != nil {
+		return "", err
+	}
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		if errors.Is(err, concurrency.ErrElectionNoLeader) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Observe returns a channel that receives the id of the new leader when the leader changes.
+func (e *EtcdElection) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	_, election, err := e.getElection(ctx)
+	if err != nil {
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+		var last string
+		for resp := range election.Observe(ctx) {
+			if len(resp.Kvs) == 0 {
+				continue
+			}
+			id := string(resp.Kvs[0].Value)
+			if id == last {
+				continue
+			}
+			last = id
+			select {
+			case ch <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Close resigns if the candidate is the leader, and closes the etcd session.
+func (e *EtcdElection) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := e.Resign(ctx)
+
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	if e.session != nil {
+		if closeErr := e.session.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		e.session, e.election = nil, nil
+	}
+	return err
+}
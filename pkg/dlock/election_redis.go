@@ -0,0 +1,192 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// set the key if it does not exist, or reset the expiration if the key is held by the candidate
+	campaignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0`)
+
+	// reset the expiration if the key is held by the candidate
+	keepAliveScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// delete the key if it is held by the candidate
+	resignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// RedisElection implements Election using Redis, the leader holds the key whose value is
+// the candidate id, and resets the expiration of the key every ttl/3.
+type RedisElection struct {
+	leadership
+	client redis.UniversalClient
+	key    string
+}
+
+// NewRedisElection creates a new leader election using Redis.
+func NewRedisElection(client *redis.Client, key string, opts ...ElectionOption) (Election, error) {
+	if client == nil {
+		return nil, errors.New("redis client is nil")
+	}
+	return newRedisElection(client, key, opts...)
+}
+
+// NewRedisClusterElection creates a new leader election using Redis cluster.
+func NewRedisClusterElection(clusterClient *redis.ClusterClient, key string, opts ...ElectionOption) (Election, error) {
+	if clusterClient == nil {
+		return nil, errors.New("cluster redis client is nil")
+	}
+	return newRedisElection(clusterClient, key, opts...)
+}
+
+func newRedisElection(client redis.UniversalClient, key string, opts ...ElectionOption) (Election, error) {
+	if key == "" {
+		return nil, errors.New("key is empty")
+	}
+	o := defaultElectionOptions()
+	o.apply(opts...)
+	return &RedisElection{
+		leadership: leadership{o: o},
+		client:     client,
+		key:        key,
+	}, nil
+}
+
+// Campaign blocks until the candidate is elected as leader or the context is canceled.
+func (e *RedisElection) Campaign(ctx context.Context) error {
+	if e.IsLeader() {
+		return nil
+	}
+
+	var sentAt time.Time
+	for {
+		sentAt = time.Now()
+		ok, err := campaignScript.Run(ctx, e.client, []string{e.key}, e.o.candidateID, e.o.ttl.Milliseconds()).Bool()
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.o.retryInterval):
+		}
+	}
+
+	leaderCtx := e.elected()
+	go e.keepAlive(leaderCtx, sentAt)
+	return nil
+}
+
+// keepAlive resets the expiration of the key until the leadership is lost, the leadership is
+// revoked if the key is held by others, or the key may expire before the next keep alive, e.g.
+// redis is unavailable. The key expires at ttl after the last succeeded request was sent at the
+// earliest, so the leadership is revoked when the request is older than ttl - interval.
+func (e *RedisElection) keepAlive(leaderCtx context.Context, lastSent time.Time) {
+	interval := e.o.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-leaderCtx.Done():
+			return
+		case <-ticker.C:
+			sentAt := time.Now()
+			deadline := lastSent.Add(e.o.ttl - interval)
+			if d := sentAt.Add(interval); d.Before(deadline) {
+				deadline = d
+			}
+			ctx, cancel := context.WithDeadline(leaderCtx, deadline)
+			ok, err := keepAliveScript.Run(ctx, e.client, []string{e.key}, e.o.candidateID, e.o.ttl.Milliseconds()).Bool()
+			cancel()
+			if err == nil && ok {
+				lastSent = sentAt
+				continue
+			}
+			if (err == nil && !ok) || time.Since(lastSent) >= e.o.ttl-interval {
+				e.revoked()
+				return
+			}
+		}
+	}
+}
+
+// Resign gives up the leadership, the key is deleted if it is held by the candidate.
+func (e *RedisElection) Resign(ctx context.Context) error {
+	if !e.IsLeader() {
+		return nil
+	}
+	defer e.revoked()
+	return resignScript.Run(ctx, e.client, []string{e.key}, e.o.candidateID).Err()
+}
+
+// Run keeps campaigning until the context is canceled, and resigns before it returns.
+func (e *RedisElection) Run(ctx context.Context) error {
+	return e.run(ctx, e.Campaign, e.Resign)
+}
+
+// Leader returns the id of the current leader, empty if there is no leader.
+func (e *RedisElection) Leader(ctx context.Context) (string, error) {
+	id, err := e.client.Get(ctx, e.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return id, err
+}
+
+// Observe returns a channel that receives the id of the new leader when the leader changes,
+// the leader is polled every retry interval.
+func (e *RedisElection) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(e.o.retryInterval)
+		defer ticker.Stop()
+		var last string
+		for {
+			if id, err := e.Leader(ctx); err == nil && id != "" && id != last {
+				last = id
+				select {
+				case ch <- id:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
+
+// Close resigns if the candidate is the leader.
+func (e *RedisElection) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	return e.Resign(ctx)
+}
@@ -0,0 +1,166 @@
+package dlock
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestRedisElection(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	var electedCount, revokedCount int32
+	leaderDone := make(chan struct{})
+	a, err := NewRedisElection(client, "sponge:election",
+		WithCandidateID("a"),
+		WithElectionTTL(time.Millisecond*600),
+		WithRetryInterval(time.Millisecond*50),
+		WithOnElected(func(ctx context.Context) {
+			atomic.AddInt32(&electedCount, 1)
+			<-ctx.Done()
+			leaderDone <- struct{}{}
+		}),
+		WithOnRevoked(func() {
+			atomic.AddInt32(&revokedCount, 1)
+		}),
+	)
+	require.NoError(t, err)
+	b, err := NewRedisElection(client, "sponge:election", WithCandidateID("b"), WithElectionTTL(time.Millisecond*600), WithRetryInterval(time.Millisecond*50))
+	require.NoError(t, err)
+	defer b.Close()
+
+	ctx := context.Background()
+	leader, err := a.Leader(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, leader)
+
+	observeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaders := b.Observe(observeCtx)
+
+	// a is elected
+	assert.NoError(t, a.Campaign(ctx))
+	assert.NoError(t, a.Campaign(ctx))
+	assert.True(t, a.IsLeader())
+	leader, _ = b.Leader(ctx)
+	assert.Equal(t, "a", leader)
+	assert.Equal(t, "a", <-leaders)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&electedCount))
+
+	// b can't be elected while a is the leader, the leadership of a is kept alive
+	campaignCtx, campaignCancel := context.WithTimeout(ctx, time.Second)
+	assert.ErrorIs(t, b.Campaign(campaignCtx), context.DeadlineExceeded)
+	campaignCancel()
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+
+	// a resigns, b is elected
+	assert.NoError(t, a.Resign(ctx))
+	<-leaderDone
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&revokedCount))
+	assert.NoError(t, b.Campaign(ctx))
+	assert.Equal(t, "b", <-leaders)
+
+	// b loses the leadership when the key is taken by others
+	mr.Set("sponge:election", "c")
+	time.Sleep(time.Millisecond * 300)
+	assert.False(t, b.IsLeader())
+	mr.Del("sponge:election")
+
+	// a keeps campaigning after the leadership is lost, and resigns when ctx is canceled
+	runCtx, runCancel := context.WithCancel(ctx)
+	runDone := make(chan struct{})
+	go func() {
+		assert.NoError(t, a.Run(runCtx))
+		close(runDone)
+	}()
+	time.Sleep(time.Millisecond * 100)
+	assert.True(t, a.IsLeader())
+	mr.Del("sponge:election")
+	<-leaderDone
+	time.Sleep(time.Millisecond * 300)
+	assert.True(t, a.IsLeader())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&electedCount))
+	runCancel()
+	<-leaderDone
+	<-runDone
+	assert.False(t, a.IsLeader())
+	assert.False(t, mr.Exists("sponge:election"))
+	assert.NoError(t, a.Close())
+
+	_, err = NewRedisElection(nil, "key")
+	assert.Error(t, err)
+	_, err = NewRedisElection(client, "")
+	assert.Error(t, err)
+	_, err = NewRedisClusterElection(nil, "key")
+	assert.Error(t, err)
+}
+
+func TestRedisElection_Unavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	revoked := make(chan time.Time, 1)
+	e, err := NewRedisElection(client, "sponge:election",
+		WithElectionTTL(time.Millisecond*600),
+		WithOnRevoked(func() { revoked <- time.Now() }),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, e.Campaign(context.Background()))
+	mr.SetError("mock error")
+
+	// the leadership is revoked before the key can expire
+	select {
+	case at := <-revoked:
+		assert.Less(t, at.Sub(start), time.Millisecond*600)
+	case <-time.After(time.Second):
+		t.Fatal("the leadership is not revoked")
+	}
+	assert.False(t, e.IsLeader())
+}
+
+func TestEtcdElection(t *testing.T) {
+	_, err := NewEtcdElection(nil, "sponge/election")
+	assert.Error(t, err)
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:2379"}, DialTimeout: time.Second})
+	if err != nil {
+		t.Log(err)
+		return
+	}
+	defer cli.Close()
+	_, err = NewEtcdElection(cli, "")
+	assert.Error(t, err)
+
+	e, err := NewEtcdElection(cli, "sponge/election", WithCandidateID("a"), WithElectionTTL(time.Second*5))
+	require.NoError(t, err)
+	defer e.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	if err = e.Campaign(ctx); err != nil {
+		// ignore test error about not being able to connect to real etcd
+		t.Logf("campaign failed, err=%v", err)
+		return
+	}
+	assert.True(t, e.IsLeader())
+	leader, err := e.Leader(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", leader)
+	assert.NoError(t, e.Resign(ctx))
+	assert.False(t, e.IsLeader())
+}
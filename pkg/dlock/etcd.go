@@ -72,6 +72,21 @@ func (l *EtcdLock) TryLock(ctx context.Context) (bool, error) {
 	return false, err
 }
 
+// Extend reports whether the lock is still held, the lease of the etcd session is kept alive
+// automatically, false is returned if the session is expired or the lock is lost.
+func (l *EtcdLock) Extend(ctx context.Context) (bool, error) {
+	select {
+	case <-l.session.Done():
+		return false, errors.New("etcd session is expired")
+	default:
+	}
+	resp, err := l.session.Client().Txn(ctx).If(l.mutex.IsOwner()).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
 // Close releases the lock and the etcd session.
 func (l *EtcdLock) Close() error {
 	if l.session != nil {
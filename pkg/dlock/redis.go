@@ -3,6 +3,7 @@ package dlock
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
@@ -11,7 +12,9 @@ import (
 
 // RedisLock implements Locker using Redis.
 type RedisLock struct {
-	mutex *redsync.Mutex
+	mutex    *redsync.Mutex
+	tryMutex *redsync.Mutex                // tries once, so the error of the attempt is returned
+	owner    atomic.Pointer[redsync.Mutex] // the mutex holding the lock
 }
 
 // NewRedisLock creates a new RedisLock.
@@ -40,32 +43,52 @@ func newLocker(delegate redis.UniversalClient, key string, options ...redsync.Op
 	pool := goredis.NewPool(delegate)
 	rs := redsync.New(pool)
 	mutex := rs.NewMutex(key, options...)
+	tryOptions := append(options[:len(options):len(options)], redsync.WithTries(1))
+	tryMutex := rs.NewMutex(key, tryOptions...)
 
-	return &RedisLock{
-		mutex: mutex,
+	l := &RedisLock{
+		mutex:    mutex,
+		tryMutex: tryMutex,
 	}
+	l.owner.Store(mutex)
+	return l
 }
 
-// TryLock tries to acquire the lock without blocking.
+// TryLock tries to acquire the lock without blocking, false and nil error are returned if the
+// lock is held by others.
 func (l *RedisLock) TryLock(ctx context.Context) (bool, error) {
-	err := l.mutex.TryLockContext(ctx)
+	err := l.tryMutex.TryLockContext(ctx)
 	if err == nil {
+		l.owner.Store(l.tryMutex)
 		return true, nil
 	}
+	var taken *redsync.ErrTaken
+	if errors.As(err, &taken) {
+		return false, nil
+	}
 	return false, err
 }
 
 // Lock blocks until the lock is acquired or the context is canceled.
 func (l *RedisLock) Lock(ctx context.Context) error {
-	return l.mutex.LockContext(ctx)
+	err := l.mutex.LockContext(ctx)
+	if err == nil {
+		l.owner.Store(l.mutex)
+	}
+	return err
 }
 
 // Unlock releases the lock, if unlocking the key is successful, the key will be automatically deleted
 func (l *RedisLock) Unlock(ctx context.Context) error {
-	_, err := l.mutex.UnlockContext(ctx)
+	_, err := l.owner.Load().UnlockContext(ctx)
 	return err
 }
 
+// Extend resets the expiration of the lock held, false is returned if the lock is lost.
+func (l *RedisLock) Extend(ctx context.Context) (bool, error) {
+	return l.owner.Load().ExtendContext(ctx)
+}
+
 // Close no-op for RedisLock.
 func (l *RedisLock) Close() error {
 	return nil
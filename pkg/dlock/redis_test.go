@@ -7,6 +7,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
 	"github.com/go-dev-frame/sponge/pkg/goredis"
 )
 
@@ -120,3 +125,53 @@ func testLockAndUnlock(initLocker func() Locker, isBlock bool, t *testing.T) {
 
 	waitGroup.Wait()
 }
+
+func TestRedisLock_Extend(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	locker, err := NewRedisLock(client, "test_extend_lock", redsync.WithExpiry(time.Second))
+	assert.NoError(t, err)
+	ctx := context.Background()
+	ok, err := locker.TryLock(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mr.FastForward(time.Millisecond * 800)
+	ok, err = locker.(Extender).Extend(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	mr.FastForward(time.Millisecond * 800)
+	assert.True(t, mr.Exists("test_extend_lock"))
+
+	assert.NoError(t, locker.Unlock(ctx))
+	ok, _ = locker.(Extender).Extend(ctx)
+	assert.False(t, ok)
+}
+
+func TestRedisLock_TryLockTaken(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	locker1, _ := NewRedisLock(client, "test_taken_lock")
+	locker2, _ := NewRedisLock(client, "test_taken_lock")
+	ok, err := locker1.TryLock(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = locker2.TryLock(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, locker1.Unlock(ctx))
+	ok, err = locker2.TryLock(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, locker2.Unlock(ctx))
+
+	mr.Close()
+	_, err = locker1.TryLock(ctx)
+	assert.Error(t, err)
+}
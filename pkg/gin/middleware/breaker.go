@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +24,8 @@ type circuitBreakerOptions struct {
 	validCodes map[int]struct{}
 	// degrade func
 	degradeHandler func(c *gin.Context)
+	// fallback functions, key is route full path, "" is for all routes without their own fallback
+	fallbacks map[string]FallbackHandler
 }
 
 func defaultCircuitBreakerOptions() *circuitBreakerOptions {
@@ -52,7 +56,8 @@ func WithGroup(g *group.Group) CircuitBreakerOption {
 	}
 }
 
-// WithBreakerOption set the circuit breaker options.
+// WithBreakerOption set the circuit breaker options, the SRE breaker is used by default,
+// add circuitbreaker.WithStateMachine() to use the state machine breaker.
 func WithBreakerOption(opts ...circuitbreaker.Option) CircuitBreakerOption {
 	return func(o *circuitBreakerOptions) {
 		if len(opts) > 0 {
@@ -79,17 +84,50 @@ func WithDegradeHandler(handler func(c *gin.Context)) CircuitBreakerOption {
 	}
 }
 
+// FallbackHandler is the fallback function of a route, it is called when the breaker rejects the
+// request or the response status code is the valid code, err is ErrNotAllowed or the failed error.
+// The fallback writes the response instead, e.g. cached response, default value or degraded error code.
+type FallbackHandler func(c *gin.Context, err error)
+
+// WithFallback set the fallback function of the route, fullPath is the route path registered in gin,
+// e.g. "/api/v1/user/:id", if fullPath is empty, the fallback is used for all the routes without
+// their own fallback, it takes precedence over WithDegradeHandler.
+// Note: the response of a route with fallback is buffered until the handler returns, so that the
+// failed response can be replaced by the fallback. If the handler flushes the response, e.g. streaming
+// or SSE, the buffered response is written and the later writes are not buffered, the fallback is not
+// called for the response that is already written.
+func WithFallback(fullPath string, handler FallbackHandler) CircuitBreakerOption {
+	return func(o *circuitBreakerOptions) {
+		if handler == nil {
+			return
+		}
+		if o.fallbacks == nil {
+			o.fallbacks = make(map[string]FallbackHandler)
+		}
+		o.fallbacks[fullPath] = handler
+	}
+}
+
+func (o *circuitBreakerOptions) getFallback(fullPath string) FallbackHandler {
+	if fn, ok := o.fallbacks[fullPath]; ok {
+		return fn
+	}
+	return o.fallbacks[""]
+}
+
 // CircuitBreaker a circuit breaker middleware
 func CircuitBreaker(opts ...CircuitBreakerOption) gin.HandlerFunc {
 	o := defaultCircuitBreakerOptions()
 	o.apply(opts...)
 
 	return func(c *gin.Context) {
+		fallback := o.getFallback(c.FullPath())
 		breaker := o.group.Get(c.FullPath()).(circuitbreaker.CircuitBreaker)
-		if err := breaker.Allow(); err != nil {
-			// NOTE: when client reject request locally, keep adding counter let the drop ratio higher.
-			breaker.MarkFailed()
-			if o.degradeHandler != nil {
+		done, err := circuitbreaker.Acquire(breaker)
+		if err != nil {
+			if fallback != nil {
+				fallback(c, err)
+			} else if o.degradeHandler != nil {
 				o.degradeHandler(c)
 			} else {
 				response.Output(c, http.StatusServiceUnavailable, err.Error())
@@ -98,15 +136,156 @@ func CircuitBreaker(opts ...CircuitBreakerOption) gin.HandlerFunc {
 			return
 		}
 
+		var bw *bufferedWriter
+		if fallback != nil {
+			bw = newBufferedWriter(c.Writer)
+			c.Writer = bw
+		}
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// the handler panics, mark failed and restore the writer, so that the recovery
+			// middleware outside can write the response
+			done(false)
+			if bw != nil {
+				c.Writer = bw.ResponseWriter
+			}
+		}()
+
 		c.Next()
 
+		finished = true
 		code := c.Writer.Status()
 		// NOTE: need to check internal and service unavailable error
 		_, isHit := o.validCodes[code]
-		if isHit {
-			breaker.MarkFailed()
-		} else {
-			breaker.MarkSuccess()
+		done(!isHit)
+
+		if bw != nil {
+			c.Writer = bw.ResponseWriter
+			if bw.streaming {
+				return
+			}
+			if isHit {
+				fallback(c, fmt.Errorf("request failed, status code %d", code))
+				return
+			}
+			bw.flush()
+		}
+	}
+}
+
+// bufferedWriter buffers the response header, status code and body until flush is called,
+// it stops buffering after the handler calls Flush.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	streaming bool // the response is flushed by the handler, writes go to the underlying writer
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+	}
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	if w.streaming {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 && w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(data)
+	}
+	w.WriteHeaderNow()
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.WriteString(s)
+	}
+	w.WriteHeaderNow()
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.streaming {
+		return w.ResponseWriter.Status()
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	if w.streaming {
+		return w.ResponseWriter.Written()
+	}
+	return w.status != 0
+}
+
+// Flush writes the buffered response, and stops buffering, e.g. streaming or SSE responses.
+func (w *bufferedWriter) Flush() {
+	if !w.streaming {
+		w.WriteHeaderNow()
+		w.flush()
+		w.streaming = true
+	}
+	w.ResponseWriter.Flush()
+}
+
+// flush writes the buffered response to the underlying writer.
+func (w *bufferedWriter) flush() {
+	header := w.ResponseWriter.Header()
+	for k := range header {
+		if _, ok := w.header[k]; !ok {
+			header.Del(k)
 		}
 	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
 }
@@ -3,12 +3,14 @@ package middleware
 import (
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/go-dev-frame/sponge/pkg/container/group"
 	"github.com/go-dev-frame/sponge/pkg/gin/response"
@@ -91,3 +93,128 @@ func TestCircuitBreaker(t *testing.T) {
 			time.Now().Format(time.RFC3339Nano), success, failures, degradeCount)
 	}
 }
+
+type rejectBreaker struct{}
+
+func (rejectBreaker) Allow() error { return circuitbreaker.ErrNotAllowed }
+func (rejectBreaker) MarkSuccess() {}
+func (rejectBreaker) MarkFailed()  {}
+
+func TestCircuitBreakerFallback(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(CircuitBreaker(
+		WithFallback("/user/:id", func(c *gin.Context, err error) {
+			c.String(http.StatusOK, "cached user")
+		}),
+		WithFallback("", func(c *gin.Context, err error) {
+			response.Output(c, http.StatusServiceUnavailable, "degraded")
+		}),
+	))
+	r.GET("/user/:id", func(c *gin.Context) {
+		c.Header("X-Failed", "true")
+		c.String(http.StatusInternalServerError, "internal error")
+	})
+	r.GET("/list", func(c *gin.Context) {
+		c.Header("X-Test", "ok")
+		c.String(http.StatusOK, "list")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cached user", w.Body.String())
+	assert.Empty(t, w.Header().Get("X-Failed"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "list", w.Body.String())
+	assert.Equal(t, "ok", w.Header().Get("X-Test"))
+
+	// rejected by breaker
+	r = gin.New()
+	r.Use(CircuitBreaker(
+		WithGroup(group.NewGroup(func() interface{} { return rejectBreaker{} })),
+		WithDegradeHandler(func(c *gin.Context) {
+			c.String(http.StatusOK, "degrade")
+		}),
+		WithFallback("/user/:id", func(c *gin.Context, err error) {
+			assert.ErrorIs(t, err, ErrNotAllowed)
+			c.String(http.StatusOK, "default user")
+		}),
+	))
+	r.GET("/user/:id", func(c *gin.Context) { c.String(http.StatusOK, "user") })
+	r.GET("/list", func(c *gin.Context) { c.String(http.StatusOK, "list") })
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/1", nil))
+	assert.Equal(t, "default user", w.Body.String())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+	assert.Equal(t, "degrade", w.Body.String())
+}
+
+func TestCircuitBreaker_StateMachine(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(CircuitBreaker(
+		WithBreakerOption(
+			circuitbreaker.WithStateMachine(),
+			circuitbreaker.WithRequest(10),
+			circuitbreaker.WithOpenTimeout(time.Hour),
+		),
+	))
+	var calls int
+	r.GET("/hello", func(c *gin.Context) {
+		calls++
+		c.String(http.StatusInternalServerError, "internal error")
+	})
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 10, calls)
+}
+
+func TestCircuitBreakerFallback_PanicAndStreaming(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(gin.Recovery(), CircuitBreaker(
+		WithBreakerOption(
+			circuitbreaker.WithStateMachine(),
+			circuitbreaker.WithRequest(1),
+			circuitbreaker.WithOpenTimeout(time.Hour),
+		),
+		WithFallback("", func(c *gin.Context, err error) {
+			c.String(http.StatusOK, "fallback")
+		}),
+	))
+	r.GET("/panic", func(c *gin.Context) { panic("mock panic") })
+	r.GET("/stream", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+		_, _ = c.Writer.WriteString("a")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString("b")
+	})
+
+	// the response is not buffered after flush, the fallback is not called
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "ab", w.Body.String())
+	assert.True(t, w.Flushed)
+
+	// the recovery outside writes the response, and the panic is marked failed
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, "fallback", w.Body.String())
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/go-dev-frame/sponge/pkg/gin/response"
 	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
@@ -23,6 +25,15 @@ type rateLimitOptions struct {
 	bucket       int
 	cpuThreshold int64
 	cpuQuota     float64
+
+	limiter       rl.Limiter
+	keyLimiter    rl.KeyLimiter
+	keyFn         func(c *gin.Context) string
+	keyFailClosed bool
+	keyLimiterLog *zap.Logger
+
+	routePriorities map[string]rl.Priority
+	priorityHeader  string
 }
 
 func defaultRatelimitOptions() *rateLimitOptions {
@@ -39,6 +50,19 @@ func (o *rateLimitOptions) apply(opts ...RateLimitOption) {
 	}
 }
 
+// getLimiter returns the limiter set by WithLimiter, or a new adaptive rate limiter based on CPU usage.
+func (o *rateLimitOptions) getLimiter() rl.Limiter {
+	if o.limiter != nil {
+		return o.limiter
+	}
+	return rl.NewLimiter(
+		rl.WithWindow(o.window),
+		rl.WithBucket(o.bucket),
+		rl.WithCPUThreshold(o.cpuThreshold),
+		rl.WithCPUQuota(o.cpuQuota),
+	)
+}
+
 // WithWindow with window size.
 func WithWindow(d time.Duration) RateLimitOption {
 	return func(o *rateLimitOptions) {
@@ -67,16 +91,87 @@ func WithCPUQuota(quota float64) RateLimitOption {
 	}
 }
 
+// WithLimiter use the limiter instead of the adaptive rate limiter based on CPU usage, e.g. the adaptive
+// concurrency limiter rl.NewVegasLimiter or rl.NewGradientLimiter which is suitable for IO-bound services.
+func WithLimiter(limiter rl.Limiter) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithRoutePriority set the priority of the routes, fullPaths are the route paths registered in gin,
+// e.g. "/api/v1/login", when overloaded, the requests of lower priority are shed first,
+// the priority of route takes precedence over the priority in the request header.
+func WithRoutePriority(priority rl.Priority, fullPaths ...string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		if o.routePriorities == nil {
+			o.routePriorities = make(map[string]rl.Priority)
+		}
+		for _, fullPath := range fullPaths {
+			o.routePriorities[fullPath] = priority
+		}
+	}
+}
+
+// WithPriorityHeader set the request header of priority, the value is "critical", "default" or "sheddable",
+// e.g. "X-Priority", when overloaded, the requests of lower priority are shed first.
+func WithPriorityHeader(header string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.priorityHeader = header
+	}
+}
+
+// WithKeyLimiter use a rate limiter keyed by the request instead of the adaptive rate limiter, e.g. the
+// distributed limiter rl.NewRedisLimiter, keyFn returns the key of the request, e.g. API key or user id,
+// default is the client IP, the request with empty key is not limited.
+func WithKeyLimiter(limiter rl.KeyLimiter, keyFn func(c *gin.Context) string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.keyLimiter = limiter
+		o.keyFn = keyFn
+	}
+}
+
+// WithKeyLimiterFailClosed reject the requests when the key limiter is unavailable, e.g. redis is down,
+// default is fail open, the requests are allowed.
+func WithKeyLimiterFailClosed() RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.keyFailClosed = true
+	}
+}
+
+// WithKeyLimiterLog set the logger of the key limiter errors, the errors are logged at most
+// once every 10 seconds, default is zap.NewProduction.
+func WithKeyLimiterLog(log *zap.Logger) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		if log != nil {
+			o.keyLimiterLog = log
+		}
+	}
+}
+
+// keyLimiterErrorLog returns the logger of the key limiter errors, the same message is logged
+// at most once every 10 seconds, so that the errors of an unavailable limiter do not flood the log.
+func (o *rateLimitOptions) keyLimiterErrorLog() *zap.Logger {
+	log := o.keyLimiterLog
+	if log == nil {
+		log = defaultLogger
+	}
+	return log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, 10*time.Second, 1, 0)
+	}))
+}
+
 // RateLimit an adaptive rate limiter middleware
 func RateLimit(opts ...RateLimitOption) gin.HandlerFunc {
 	o := defaultRatelimitOptions()
 	o.apply(opts...)
-	limiter := rl.NewLimiter(
-		rl.WithWindow(o.window),
-		rl.WithBucket(o.bucket),
-		rl.WithCPUThreshold(o.cpuThreshold),
-		rl.WithCPUQuota(o.cpuQuota),
-	)
+	if o.keyLimiter != nil {
+		return keyRateLimit(o)
+	}
+	limiter := o.getLimiter()
+	if len(o.routePriorities) > 0 || o.priorityHeader != "" {
+		return priorityRateLimit(rl.NewShedder(limiter, rl.WithShedderName("http")), o)
+	}
 
 	return func(c *gin.Context) {
 		done, err := limiter.Allow()
@@ -92,6 +187,45 @@ func RateLimit(opts ...RateLimitOption) gin.HandlerFunc {
 	}
 }
 
+// keyRateLimit limits the requests by key, the standard rate limit headers are set in the response,
+// the requests are allowed if the limiter is unavailable, unless WithKeyLimiterFailClosed is set.
+func keyRateLimit(o *rateLimitOptions) gin.HandlerFunc {
+	limiter, keyFn := o.keyLimiter, o.keyFn
+	if keyFn == nil {
+		keyFn = func(c *gin.Context) string { return c.ClientIP() }
+	}
+	errLog := o.keyLimiterErrorLog()
+
+	return func(c *gin.Context) {
+		key := keyFn(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := limiter.AllowKey(c.Request.Context(), key)
+		if err != nil {
+			errLog.Error("key rate limiter error", zap.Error(err), zap.Bool("failClosed", o.keyFailClosed))
+			if o.keyFailClosed {
+				response.Output(c, http.StatusServiceUnavailable, err.Error())
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		for k, v := range result.Headers() {
+			c.Header(k, v)
+		}
+		if !result.Allowed {
+			response.Output(c, http.StatusTooManyRequests, ErrLimitExceed.Error())
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // Timeout request time out
 func Timeout(d time.Duration) gin.HandlerFunc {
 	if d < time.Millisecond {
@@ -112,3 +246,30 @@ func Timeout(d time.Duration) gin.HandlerFunc {
 		}
 	}
 }
+
+// priorityRateLimit sheds the requests by priority when overloaded.
+func priorityRateLimit(shedder *rl.Shedder, o *rateLimitOptions) gin.HandlerFunc {
+	getPriority := func(c *gin.Context) rl.Priority {
+		if p, ok := o.routePriorities[c.FullPath()]; ok {
+			return p
+		}
+		if o.priorityHeader != "" {
+			p, _ := rl.ParsePriority(c.GetHeader(o.priorityHeader))
+			return p
+		}
+		return rl.PriorityDefault
+	}
+
+	return func(c *gin.Context) {
+		done, err := shedder.AllowPriority(getPriority(c))
+		if err != nil {
+			response.Output(c, http.StatusTooManyRequests, err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		done(rl.DoneInfo{Err: c.Request.Context().Err()})
+	}
+}
@@ -3,15 +3,23 @@ package middleware
 import (
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/go-dev-frame/sponge/pkg/gin/response"
 	"github.com/go-dev-frame/sponge/pkg/httpcli"
+	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
 	"github.com/go-dev-frame/sponge/pkg/utils"
 )
 
@@ -77,3 +85,145 @@ func TestRateLimiter(t *testing.T) {
 			time.Now().Format(time.RFC3339Nano), success, failures)
 	}
 }
+
+func TestRateLimitWithKeyLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(RateLimit(WithKeyLimiter(rl.NewRedisLimiter(client, 2, time.Minute), func(c *gin.Context) string {
+		return c.GetHeader("X-API-Key")
+	})))
+	r.GET("/hello", func(c *gin.Context) {
+		response.Success(c, "hello")
+	})
+
+	request := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := request("key1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get(rl.HeaderLimit))
+	}
+	w := request("key1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(rl.HeaderRemaining))
+	assert.Equal(t, "30", w.Header().Get(rl.HeaderRetryAfter))
+
+	assert.Equal(t, http.StatusOK, request("key2").Code)
+	// no key, not limited
+	for i := 0; i < 3; i++ {
+		w = request("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(rl.HeaderLimit))
+	}
+
+	// redis is unavailable, fail open
+	mr.Close()
+	assert.Equal(t, http.StatusOK, request("key1").Code)
+}
+
+func TestRateLimitWithKeyLimiterError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	mr.Close() // redis is unavailable
+
+	gin.SetMode(gin.ReleaseMode)
+	newRouter := func(opts ...RateLimitOption) *gin.Engine {
+		r := gin.New()
+		r.Use(RateLimit(append([]RateLimitOption{WithKeyLimiter(rl.NewRedisLimiter(client, 2, time.Minute), nil)}, opts...)...))
+		r.GET("/hello", func(c *gin.Context) {
+			response.Success(c, "hello")
+		})
+		return r
+	}
+	request := func(r *gin.Engine) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		return w.Code
+	}
+
+	// fail open, the errors are logged once in 10 seconds
+	core, logs := observer.New(zapcore.InfoLevel)
+	r := newRouter(WithKeyLimiterLog(zap.New(core)))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request(r))
+	}
+	assert.Equal(t, 1, logs.FilterMessage("key rate limiter error").Len())
+
+	// fail closed
+	r = newRouter(WithKeyLimiterFailClosed())
+	assert.Equal(t, http.StatusServiceUnavailable, request(r))
+}
+
+func TestRateLimitWithLimiter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(RateLimit(WithLimiter(rl.NewGradientLimiter(rl.WithInitialLimit(1)))))
+	nestedCode := 0
+	r.GET("/hello", func(c *gin.Context) {
+		// the limit of in-flight requests is reached
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nested", nil))
+		nestedCode = w.Code
+		response.Success(c, "hello")
+	})
+	r.GET("/nested", func(c *gin.Context) {
+		response.Success(c, "nested")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusTooManyRequests, nestedCode)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nested", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitWithPriority(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(RateLimit(
+		WithLimiter(rl.NewVegasLimiter(rl.WithInitialLimit(1))),
+		WithRoutePriority(rl.PriorityCritical, "/login"),
+		WithPriorityHeader("X-Priority"),
+	))
+	request := func(path string, priority string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Priority", priority)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	nestedCode := 0
+	r.GET("/login", func(c *gin.Context) {
+		// the limit of in-flight requests is reached
+		nestedCode = request("/hello", "")
+		response.Success(c, "login")
+	})
+	r.GET("/hello", func(c *gin.Context) {
+		response.Success(c, "hello")
+	})
+
+	assert.Equal(t, http.StatusOK, request("/login", "sheddable"))
+	assert.Equal(t, http.StatusTooManyRequests, nestedCode)
+
+	// the default request was rejected, the sheddable requests are shed
+	assert.Equal(t, http.StatusTooManyRequests, request("/hello", "sheddable"))
+	assert.Equal(t, http.StatusOK, request("/hello", "default"))
+	assert.Equal(t, http.StatusOK, request("/hello", ""))
+	assert.Equal(t, http.StatusOK, request("/login", ""))
+}
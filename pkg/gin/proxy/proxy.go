@@ -2,8 +2,10 @@ package proxy
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
 	"github.com/go-dev-frame/sponge/pkg/proxykit"
 )
@@ -12,15 +14,29 @@ import (
 type Proxy struct {
 	r       *gin.Engine
 	manager *proxykit.RouteManager
+
+	mu       sync.Mutex
+	watchers []*proxykit.DiscoveryWatcher
+
+	zapLogger      *zap.Logger
+	routesMu       sync.RWMutex
+	staticPrefixes map[string]struct{}      // prefix paths registered by Pass
+	dynamicRoutes  map[string]*dynamicRoute // prefix path -> dynamic route
+	matchOrder     []*dynamicRoute          // sorted by the length of prefix path in descending order
+	noRoute        gin.HandlersChain        // handlers of the requests that match no route
 }
 
-// New creates a new Proxy instance.
+// New creates a new Proxy instance, it must be called before the gin engine is run. The dynamic
+// routes are dispatched by the NoRoute handler of gin, which is registered by New and replaces the
+// NoRoute handler registered before, use WithNoRouteHandler to handle the requests that match no route.
 func New(r *gin.Engine, opts ...Option) *Proxy {
 	o := defaultOptions()
 	o.apply(opts...)
 
 	if o.zapLogger != nil {
 		proxykit.SetLogger(o.zapLogger)
+	} else {
+		o.zapLogger, _ = zap.NewProduction()
 	}
 
 	manager := proxykit.NewRouteManager()
@@ -40,10 +56,28 @@ func New(r *gin.Engine, opts ...Option) *Proxy {
 		managerGroup.GET("", gin.WrapF(manager.HandleGetBackend))
 	}
 
-	return &Proxy{
-		r:       r,
-		manager: manager,
+	p := &Proxy{
+		r:              r,
+		manager:        manager,
+		zapLogger:      o.zapLogger,
+		staticPrefixes: make(map[string]struct{}),
+		dynamicRoutes:  make(map[string]*dynamicRoute),
+		noRoute:        o.noRouteHandlers,
 	}
+	r.NoRoute(p.dispatch)
+
+	// setup admin endpoints routes of the dynamic routes
+	if o.enableAdmin {
+		adminGroup := r.Group(o.adminPrefixPath, o.adminMiddlewares...)
+		{
+			adminGroup.GET("/routes", p.handleListRoutes)
+			adminGroup.POST("/routes", p.handleAddRoute)
+			adminGroup.PUT("/routes", p.handleUpdateRoute)
+			adminGroup.DELETE("/routes", p.handleDeleteRoute)
+		}
+	}
+
+	return p
 }
 
 // Pass registers proxy endpoints to gin engine.
@@ -55,27 +89,42 @@ func (p *Proxy) Pass(prefixPath string, endpoints []string, opts ...PassOption)
 	if err != nil {
 		return fmt.Errorf("parse backends error: %v", err)
 	}
-	proxykit.StartHealthChecks(backends, proxykit.HealthCheckConfig{
+	for i, b := range backends {
+		b.SetWeight(o.weights[endpoints[i]])
+	}
+	healthCheckConfig := proxykit.HealthCheckConfig{
 		Interval: o.healthCheckInterval,
 		Timeout:  o.healthCheckTimeout,
-	})
+	}
 
-	var balancer proxykit.Balancer
-	switch o.balancerType {
-	case BalancerRoundRobin:
-		balancer = proxykit.NewRoundRobin(backends)
-	case BalancerLeastConn:
-		balancer = proxykit.NewLeastConnections(backends)
-	case BalancerIPHash:
-		balancer = proxykit.NewIPHash(backends)
-	default:
-		return fmt.Errorf("unsupported balancer type: %s", o.balancerType)
+	balancer, err := newBalancer(o.balancerType, backends, o.hashOptions...)
+	if err != nil {
+		return err
 	}
 
-	apiRoute, err := p.manager.AddRoute(prefixPath, balancer)
+	p.routesMu.Lock()
+	defer p.routesMu.Unlock()
+	apiRoute, err := p.manager.AddRoute(prefixPath, balancer, o.proxyOptions...)
 	if err != nil {
 		return fmt.Errorf("could not add initial route: %v", err)
 	}
+	p.staticPrefixes[apiRoute.PrefixPath] = struct{}{}
+	proxykit.StartHealthChecks(backends, healthCheckConfig)
+
+	if o.discovery != nil {
+		discoveryOpts := append([]proxykit.DiscoveryOption{proxykit.WithDiscoveryHealthCheck(healthCheckConfig)}, o.discoveryOptions...)
+		watcher, err := proxykit.NewDiscoveryWatcher(apiRoute, o.discovery, o.serviceName, discoveryOpts...)
+		if err != nil {
+			// the proxy endpoints are not registered yet, roll back the route
+			p.manager.RemoveRoute(apiRoute.PrefixPath)
+			delete(p.staticPrefixes, apiRoute.PrefixPath)
+			stopHealthChecks(apiRoute)
+			return fmt.Errorf("watch service '%s' error: %v", o.serviceName, err)
+		}
+		p.mu.Lock()
+		p.watchers = append(p.watchers, watcher)
+		p.mu.Unlock()
+	}
 
 	// setup proxy endpoints routes
 	proxyRelativePath := proxykit.AnyRelativePath(prefixPath) // /prefixPath/*path
@@ -84,3 +133,37 @@ func (p *Proxy) Pass(prefixPath string, endpoints []string, opts ...PassOption)
 
 	return nil
 }
+
+func newBalancer(balancerType string, backends []*proxykit.Backend, hashOpts ...proxykit.ConsistentHashOption) (proxykit.Balancer, error) {
+	switch balancerType {
+	case BalancerRoundRobin:
+		return proxykit.NewRoundRobin(backends), nil
+	case BalancerLeastConn:
+		return proxykit.NewLeastConnections(backends), nil
+	case BalancerIPHash:
+		return proxykit.NewIPHash(backends), nil
+	case BalancerWeightedRoundRobin:
+		return proxykit.NewWeightedRoundRobin(backends), nil
+	case BalancerConsistentHash:
+		return proxykit.NewConsistentHash(backends, hashOpts...), nil
+	case BalancerP2C:
+		return proxykit.NewP2C(backends), nil
+	default:
+		return nil, fmt.Errorf("unsupported balancer type: %s", balancerType)
+	}
+}
+
+// Close stops watching the service discovery.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var lastErr error
+	for _, w := range p.watchers {
+		if err := w.Stop(); err != nil {
+			lastErr = err
+		}
+	}
+	p.watchers = nil
+	return lastErr
+}
@@ -6,6 +6,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/proxykit"
+	"github.com/go-dev-frame/sponge/pkg/servicerd/registry"
+	"github.com/go-dev-frame/sponge/pkg/shield/circuitbreaker"
 )
 
 // Option set options.
@@ -15,6 +19,12 @@ type options struct {
 	managerPrefixPath  string // default "/endpoints"
 	managerMiddlewares []gin.HandlerFunc
 	zapLogger          *zap.Logger
+
+	enableAdmin      bool
+	adminPrefixPath  string // default "/admin"
+	adminMiddlewares []gin.HandlerFunc
+
+	noRouteHandlers []gin.HandlerFunc
 }
 
 func (o *options) apply(opts ...Option) {
@@ -26,6 +36,7 @@ func (o *options) apply(opts ...Option) {
 func defaultOptions() *options {
 	return &options{
 		managerPrefixPath: "/endpoints",
+		adminPrefixPath:   "/admin",
 	}
 }
 
@@ -43,6 +54,30 @@ func WithManagerEndpoints(managerPrefixPath string, middlewares ...gin.HandlerFu
 	}
 }
 
+// WithNoRouteHandler set the handlers of the requests that match neither the routes registered to gin
+// nor the dynamic routes, the proxy owns the NoRoute handler of gin, register the NoRoute handlers by
+// this option instead of gin.Engine.NoRoute, default responds 404.
+func WithNoRouteHandler(handlers ...gin.HandlerFunc) Option {
+	return func(o *options) {
+		o.noRouteHandlers = handlers
+	}
+}
+
+// WithAdminEndpoints enables the admin API for adding, modifying and removing the dynamic routes
+// at runtime, adminPrefixPath default "/admin", the admin API should be protected by middlewares.
+func WithAdminEndpoints(adminPrefixPath string, middlewares ...gin.HandlerFunc) Option {
+	return func(o *options) {
+		o.enableAdmin = true
+		if adminPrefixPath != "" {
+			if !strings.HasPrefix(adminPrefixPath, "/") {
+				adminPrefixPath = "/" + adminPrefixPath
+			}
+			o.adminPrefixPath = strings.TrimSuffix(adminPrefixPath, "/")
+		}
+		o.adminMiddlewares = middlewares
+	}
+}
+
 // WithLogger sets logger.
 func WithLogger(logger *zap.Logger) Option {
 	return func(o *options) {
@@ -53,9 +88,12 @@ func WithLogger(logger *zap.Logger) Option {
 // -------------------------------------------------------------------------------------------
 
 var (
-	BalancerRoundRobin = "round_robin"
-	BalancerLeastConn  = "least_conn"
-	BalancerIPHash     = "ip_hash"
+	BalancerRoundRobin         = "round_robin"
+	BalancerLeastConn          = "least_conn"
+	BalancerIPHash             = "ip_hash"
+	BalancerWeightedRoundRobin = "weighted_round_robin"
+	BalancerConsistentHash     = "consistent_hash"
+	BalancerP2C                = "p2c"
 )
 
 // PassOption set passOptions.
@@ -64,8 +102,15 @@ type PassOption func(*passOptions)
 type passOptions struct {
 	healthCheckInterval time.Duration // default 5s
 	healthCheckTimeout  time.Duration // default 3s
-	balancerType        string        // supported values: "round_robin", "least_conn", "ip_hash", default "round_robin"
+	balancerType        string        // supported values: "round_robin", "least_conn", "ip_hash", "weighted_round_robin", "consistent_hash", "p2c", default "round_robin"
 	passMiddlewares     []gin.HandlerFunc
+	weights             map[string]int // endpoint -> weight
+	hashOptions         []proxykit.ConsistentHashOption
+	proxyOptions        []proxykit.ProxyOption
+
+	discovery        registry.Discovery
+	serviceName      string
+	discoveryOptions []proxykit.DiscoveryOption
 }
 
 func (o *passOptions) apply(opts ...PassOption) {
@@ -107,3 +152,75 @@ func WithPassMiddlewares(middlewares ...gin.HandlerFunc) PassOption {
 		o.passMiddlewares = middlewares
 	}
 }
+
+// WithPassWeights sets the weights of the endpoints, key is endpoint, value is weight (default 1),
+// used by "weighted_round_robin" and "consistent_hash" balancers.
+func WithPassWeights(weights map[string]int) PassOption {
+	return func(o *passOptions) {
+		o.weights = weights
+	}
+}
+
+// WithPassHashKey sets the hash key of the "consistent_hash" balancer, e.g. proxykit.HashKeyHeader("X-User-Id"),
+// proxykit.HashKeyCookie("session_id"), proxykit.HashKeyPath(), default is the client IP.
+// virtualNodes is the number of virtual nodes per endpoint on the hash ring, default 160 if less than 1.
+func WithPassHashKey(keyFn proxykit.HashKeyFunc, virtualNodes int) PassOption {
+	return func(o *passOptions) {
+		o.hashOptions = []proxykit.ConsistentHashOption{
+			proxykit.WithHashKey(keyFn),
+			proxykit.WithVirtualNodes(virtualNodes),
+		}
+	}
+}
+
+// WithPassOutlierDetection enables passive health check, the endpoint with consecutive 5xx responses
+// or connection errors is ejected for an exponentially growing period, the zero value of the config
+// fields use the default values.
+func WithPassOutlierDetection(config proxykit.OutlierDetectionConfig) PassOption {
+	return func(o *passOptions) {
+		o.proxyOptions = append(o.proxyOptions, proxykit.WithOutlierDetection(config))
+	}
+}
+
+// WithPassCircuitBreaker enables circuit breaker for each endpoint, the requests rejected by the
+// breaker of an endpoint are forwarded to other endpoints.
+func WithPassCircuitBreaker(opts ...circuitbreaker.Option) PassOption {
+	return func(o *passOptions) {
+		o.proxyOptions = append(o.proxyOptions, proxykit.WithCircuitBreaker(opts...))
+	}
+}
+
+// WithPassRetry enables retrying the idempotent requests on another endpoint when the response status
+// code is retryable (default 502, 503, 504), the retries are limited by the budget of the policy.
+func WithPassRetry(policy proxykit.RetryPolicy) PassOption {
+	return func(o *passOptions) {
+		o.proxyOptions = append(o.proxyOptions, proxykit.WithRetry(policy))
+	}
+}
+
+// WithPassHedging enables hedged requests for the idempotent requests, if the endpoint does not respond
+// within the delay, the request is sent to another endpoint and the first response wins.
+func WithPassHedging(policy proxykit.HedgePolicy) PassOption {
+	return func(o *passOptions) {
+		o.proxyOptions = append(o.proxyOptions, proxykit.WithHedging(policy))
+	}
+}
+
+// WithPassMirror enables mirroring a percentage of the requests to the canary endpoint, the responses
+// of the canary endpoint are discarded, and the differences from the primary responses are logged.
+func WithPassMirror(policy proxykit.MirrorPolicy) PassOption {
+	return func(o *passOptions) {
+		o.proxyOptions = append(o.proxyOptions, proxykit.WithMirror(policy))
+	}
+}
+
+// WithPassDiscovery sets the service discovery, the endpoints are kept in sync with the instances of
+// the service, new instances are added and disappeared instances are drained and removed, the static
+// endpoints passed to Pass are not affected and can be empty.
+func WithPassDiscovery(discovery registry.Discovery, serviceName string, opts ...proxykit.DiscoveryOption) PassOption {
+	return func(o *passOptions) {
+		o.discovery = discovery
+		o.serviceName = serviceName
+		o.discoveryOptions = opts
+	}
+}
@@ -6,6 +6,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/proxykit"
+	"github.com/go-dev-frame/sponge/pkg/shield/circuitbreaker"
 )
 
 func TestDefaultOptions(t *testing.T) {
@@ -50,6 +53,21 @@ func TestWithManagerEndpointsEmpty(t *testing.T) {
 	}
 }
 
+func TestWithAdminEndpoints(t *testing.T) {
+	opts := defaultOptions()
+	if opts.enableAdmin {
+		t.Errorf("expected admin disabled by default")
+	}
+	opts.apply(WithAdminEndpoints("", func(c *gin.Context) {}))
+	if !opts.enableAdmin || opts.adminPrefixPath != "/admin" || len(opts.adminMiddlewares) != 1 {
+		t.Errorf("unexpected admin options: %v %s %d", opts.enableAdmin, opts.adminPrefixPath, len(opts.adminMiddlewares))
+	}
+	opts.apply(WithAdminEndpoints("gateway/"))
+	if opts.adminPrefixPath != "/gateway" {
+		t.Errorf("expected /gateway, got %s", opts.adminPrefixPath)
+	}
+}
+
 func TestWithLogger(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	opts := defaultOptions()
@@ -115,3 +133,43 @@ func TestWithPassMiddlewares(t *testing.T) {
 		t.Errorf("expected 2 middlewares, got %d", len(opts.passMiddlewares))
 	}
 }
+
+func TestWithPassWeightsAndHashKey(t *testing.T) {
+	opts := defaultPassOptions()
+	opts.apply(
+		WithPassWeights(map[string]int{"http://localhost:8081": 3}),
+		WithPassHashKey(proxykit.HashKeyHeader("X-User-Id"), 100),
+	)
+
+	if opts.weights["http://localhost:8081"] != 3 {
+		t.Errorf("expected weight 3, got %d", opts.weights["http://localhost:8081"])
+	}
+	if len(opts.hashOptions) != 2 {
+		t.Errorf("expected 2 hash options, got %d", len(opts.hashOptions))
+	}
+}
+
+func TestWithPassOutlierDetectionAndCircuitBreaker(t *testing.T) {
+	opts := defaultPassOptions()
+	opts.apply(
+		WithPassOutlierDetection(proxykit.OutlierDetectionConfig{ConsecutiveErrors: 3}),
+		WithPassCircuitBreaker(circuitbreaker.WithRequest(10)),
+	)
+
+	if len(opts.proxyOptions) != 2 {
+		t.Errorf("expected 2 proxy options, got %d", len(opts.proxyOptions))
+	}
+}
+
+func TestWithPassRetryHedgingMirror(t *testing.T) {
+	opts := defaultPassOptions()
+	opts.apply(
+		WithPassRetry(proxykit.RetryPolicy{MaxAttempts: 3}),
+		WithPassHedging(proxykit.HedgePolicy{Delay: 50 * time.Millisecond}),
+		WithPassMirror(proxykit.MirrorPolicy{Target: "http://localhost:9090", Percent: 10}),
+	)
+
+	if len(opts.proxyOptions) != 3 {
+		t.Errorf("expected 3 proxy options, got %d", len(opts.proxyOptions))
+	}
+}
@@ -1,13 +1,18 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/go-dev-frame/sponge/pkg/servicerd/registry"
 )
 
 func TestProxy(t *testing.T) {
@@ -36,7 +41,7 @@ func TestProxy(t *testing.T) {
 	})
 
 	t.Run("SuccessWithAllBalancers", func(t *testing.T) {
-		balancers := []string{"round_robin", "least_conn", "ip_hash"}
+		balancers := []string{"round_robin", "least_conn", "ip_hash", "weighted_round_robin", "consistent_hash", "p2c"}
 		for _, b := range balancers {
 			t.Run(b, func(t *testing.T) {
 				r := gin.New()
@@ -82,6 +87,50 @@ func TestProxy(t *testing.T) {
 		assert.Contains(t, err.Error(), "unsupported balancer type: unknown_balancer")
 	})
 
+	t.Run("SuccessWithDiscovery", func(t *testing.T) {
+		r := gin.New()
+		p := New(r)
+		d := &discovery{instances: []*registry.ServiceInstance{
+			registry.NewServiceInstance("1", "backend", []string{backendServer.URL}),
+		}}
+		err := p.Pass("/proxy", nil, WithPassDiscovery(d, "backend"))
+		require.NoError(t, err)
+		defer p.Close()
+
+		proxyServer := httptest.NewServer(r)
+		defer proxyServer.Close()
+
+		var code int
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get(proxyServer.URL + "/proxy/hello")
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			code = resp.StatusCode
+			if code == http.StatusOK {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("ErrorWatchDiscovery", func(t *testing.T) {
+		r := gin.New()
+		p := New(r)
+		d := &discovery{err: errors.New("watch error")}
+		err := p.Pass("/proxy", validEndpoints, WithPassDiscovery(d, "backend"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "watch service 'backend' error")
+
+		// the route is rolled back, and can be registered again
+		_, exists := p.manager.GetRoute("/proxy/")
+		assert.False(t, exists)
+		assert.Empty(t, p.staticPrefixes)
+		assert.False(t, routeExists(r.Routes(), "GET", "/proxy/*path"))
+		err = p.Pass("/proxy", validEndpoints)
+		require.NoError(t, err)
+	})
+
 	t.Run("SuccessWithEmptyEndpoints", func(t *testing.T) {
 		r := gin.New()
 		p := New(r)
@@ -90,6 +139,41 @@ func TestProxy(t *testing.T) {
 	})
 }
 
+type discovery struct {
+	instances []*registry.ServiceInstance
+	err       error
+}
+
+func (d *discovery) GetService(ctx context.Context, serviceName string) ([]*registry.ServiceInstance, error) {
+	return d.instances, nil
+}
+
+func (d *discovery) Watch(ctx context.Context, serviceName string) (registry.Watcher, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &watcher{ctx: ctx, instances: d.instances}, nil
+}
+
+type watcher struct {
+	ctx       context.Context
+	instances []*registry.ServiceInstance
+	sent      bool
+}
+
+func (w *watcher) Next() ([]*registry.ServiceInstance, error) {
+	if !w.sent {
+		w.sent = true
+		return w.instances, nil
+	}
+	<-w.ctx.Done()
+	return nil, w.ctx.Err()
+}
+
+func (w *watcher) Stop() error {
+	return nil
+}
+
 func dummyMiddleware(c *gin.Context) {
 	c.Next()
 }
@@ -0,0 +1,387 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/conf"
+	"github.com/go-dev-frame/sponge/pkg/proxykit"
+)
+
+var (
+	// ErrRouteExists is returned when adding a dynamic route that already exists.
+	ErrRouteExists = errors.New("route already exists")
+	// ErrRouteNotFound is returned when modifying or removing a dynamic route that does not exist.
+	ErrRouteNotFound = errors.New("route not found")
+)
+
+// EndpointConfig defined the endpoint of a dynamic route.
+type EndpointConfig struct {
+	URL    string `yaml:"url" json:"url"`       // e.g. http://localhost:8081
+	Weight int    `yaml:"weight" json:"weight"` // default 1, used by "weighted_round_robin" and "consistent_hash" balancers
+}
+
+// RouteConfig defined a dynamic route, the requests whose path starts with the prefix path
+// are forwarded to the endpoints.
+type RouteConfig struct {
+	PrefixPath   string           `yaml:"prefixPath" json:"prefixPath"`
+	Endpoints    []EndpointConfig `yaml:"endpoints" json:"endpoints"`
+	Balancer     string           `yaml:"balancer" json:"balancer"`         // default "round_robin"
+	HashKey      string           `yaml:"hashKey" json:"hashKey"`           // hash key of "consistent_hash" balancer, "header:<name>", "cookie:<name>" or "path", default is client IP
+	VirtualNodes int              `yaml:"virtualNodes" json:"virtualNodes"` // virtual nodes per endpoint of "consistent_hash" balancer, default 160
+
+	// path strip rules, the prefix path is stripped by default
+	KeepPrefix bool   `yaml:"keepPrefix" json:"keepPrefix"` // keep the prefix path in the forwarded path
+	AddPrefix  string `yaml:"addPrefix" json:"addPrefix"`   // prefix added to the forwarded path, e.g. /api/v1
+
+	RequestHeaders  proxykit.HeaderRewrite     `yaml:"requestHeaders" json:"requestHeaders"`
+	ResponseHeaders proxykit.HeaderRewrite     `yaml:"responseHeaders" json:"responseHeaders"`
+	HealthCheck     proxykit.HealthCheckConfig `yaml:"healthCheck" json:"healthCheck"` // default interval 5s, timeout 2s
+}
+
+// RouteTable defined the dynamic routes, it is the content of the route table file.
+type RouteTable struct {
+	Routes []RouteConfig `yaml:"routes" json:"routes"`
+}
+
+type dynamicRoute struct {
+	config RouteConfig
+	route  *proxykit.Route
+}
+
+func (c *RouteConfig) normalize() {
+	c.PrefixPath = proxykit.NormalizePrefixPath(c.PrefixPath)
+	if c.Balancer == "" {
+		c.Balancer = BalancerRoundRobin
+	}
+}
+
+func (c *RouteConfig) validate() error {
+	if c.PrefixPath == "/" {
+		return errors.New("prefixPath cannot be empty or '/'")
+	}
+	if len(c.Endpoints) == 0 {
+		return fmt.Errorf("route '%s' has no endpoints", c.PrefixPath)
+	}
+	for _, e := range c.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("route '%s' has invalid endpoint '%s'", c.PrefixPath, e.URL)
+		}
+	}
+	if _, err := newBalancer(c.Balancer, nil); err != nil {
+		return fmt.Errorf("route '%s': %v", c.PrefixPath, err)
+	}
+	if _, err := parseHashKey(c.HashKey); err != nil {
+		return fmt.Errorf("route '%s': %v", c.PrefixPath, err)
+	}
+	return nil
+}
+
+func parseHashKey(hashKey string) (proxykit.HashKeyFunc, error) {
+	if hashKey == "" {
+		return nil, nil
+	}
+	if hashKey == "path" {
+		return proxykit.HashKeyPath(), nil
+	}
+	kind, name, ok := strings.Cut(hashKey, ":")
+	if ok && name != "" {
+		switch kind {
+		case "header":
+			return proxykit.HashKeyHeader(name), nil
+		case "cookie":
+			return proxykit.HashKeyCookie(name), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported hash key: %s", hashKey)
+}
+
+// LoadRoutes loads the dynamic routes from the route table file (yaml, json or toml), the dynamic
+// routes are updated when the file changes, the routes not in the file are removed.
+func (p *Proxy) LoadRoutes(configFile string) error {
+	table := &RouteTable{}
+	reload := func() {
+		if err := p.ApplyRoutes(table.Routes); err != nil {
+			p.zapLogger.Error("reload route table error", zap.String("file", configFile), zap.Error(err))
+			return
+		}
+		p.zapLogger.Info("reload route table success", zap.String("file", configFile), zap.Int("routes", len(table.Routes)))
+	}
+	if err := conf.ParseIsolated(configFile, table, reload); err != nil {
+		return fmt.Errorf("parse route table error: %v", err)
+	}
+	return p.ApplyRoutes(table.Routes)
+}
+
+// ApplyRoutes replaces all the dynamic routes, the unchanged routes are kept, the changed routes
+// are replaced and the routes not in the list are removed, no route is changed if any route is invalid.
+func (p *Proxy) ApplyRoutes(routes []RouteConfig) error {
+	configs := make(map[string]RouteConfig, len(routes))
+	for _, cfg := range routes {
+		cfg.normalize()
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		if _, ok := configs[cfg.PrefixPath]; ok {
+			return fmt.Errorf("duplicate route '%s'", cfg.PrefixPath)
+		}
+		configs[cfg.PrefixPath] = cfg
+	}
+
+	p.routesMu.Lock()
+	defer p.routesMu.Unlock()
+
+	// create all the changed routes before changing anything
+	changed := make(map[string]*proxykit.Route)
+	for prefixPath, cfg := range configs {
+		if _, ok := p.staticPrefixes[prefixPath]; ok {
+			return fmt.Errorf("route '%s' conflicts with the route registered by Pass", prefixPath)
+		}
+		if dr, ok := p.dynamicRoutes[prefixPath]; ok && reflect.DeepEqual(dr.config, cfg) {
+			continue
+		}
+		route, err := newRoute(cfg)
+		if err != nil {
+			return err
+		}
+		changed[prefixPath] = route
+	}
+
+	for prefixPath := range p.dynamicRoutes {
+		if _, ok := configs[prefixPath]; !ok {
+			p.removeRoute(prefixPath)
+		}
+	}
+	for prefixPath, route := range changed {
+		p.setRoute(configs[prefixPath], route)
+	}
+	p.sortRoutes()
+	return nil
+}
+
+// AddRoute adds a dynamic route, ErrRouteExists is returned if the route already exists.
+func (p *Proxy) AddRoute(cfg RouteConfig) error {
+	return p.modifyRoute(cfg, false)
+}
+
+// UpdateRoute modifies a dynamic route, ErrRouteNotFound is returned if the route does not exist.
+func (p *Proxy) UpdateRoute(cfg RouteConfig) error {
+	return p.modifyRoute(cfg, true)
+}
+
+func (p *Proxy) modifyRoute(cfg RouteConfig, exists bool) error {
+	cfg.normalize()
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	p.routesMu.Lock()
+	defer p.routesMu.Unlock()
+
+	if _, ok := p.staticPrefixes[cfg.PrefixPath]; ok {
+		return fmt.Errorf("route '%s' conflicts with the route registered by Pass", cfg.PrefixPath)
+	}
+	if _, ok := p.dynamicRoutes[cfg.PrefixPath]; ok != exists {
+		if exists {
+			return fmt.Errorf("%w: %s", ErrRouteNotFound, cfg.PrefixPath)
+		}
+		return fmt.Errorf("%w: %s", ErrRouteExists, cfg.PrefixPath)
+	}
+	route, err := newRoute(cfg)
+	if err != nil {
+		return err
+	}
+	p.setRoute(cfg, route)
+	p.sortRoutes()
+	return nil
+}
+
+// DeleteRoute removes a dynamic route, ErrRouteNotFound is returned if the route does not exist.
+func (p *Proxy) DeleteRoute(prefixPath string) error {
+	prefixPath = proxykit.NormalizePrefixPath(prefixPath)
+
+	p.routesMu.Lock()
+	defer p.routesMu.Unlock()
+
+	if _, ok := p.dynamicRoutes[prefixPath]; !ok {
+		return fmt.Errorf("%w: %s", ErrRouteNotFound, prefixPath)
+	}
+	p.removeRoute(prefixPath)
+	p.sortRoutes()
+	return nil
+}
+
+// Routes returns the dynamic routes sorted by prefix path.
+func (p *Proxy) Routes() []RouteConfig {
+	p.routesMu.RLock()
+	defer p.routesMu.RUnlock()
+
+	routes := make([]RouteConfig, 0, len(p.dynamicRoutes))
+	for _, dr := range p.dynamicRoutes {
+		routes = append(routes, dr.config)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].PrefixPath < routes[j].PrefixPath
+	})
+	return routes
+}
+
+// newRoute creates the route of the config, the route is not added to the manager.
+func newRoute(cfg RouteConfig) (*proxykit.Route, error) {
+	endpoints := make([]string, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		endpoints = append(endpoints, e.URL)
+	}
+	backends, err := proxykit.ParseBackends(cfg.PrefixPath, endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("parse backends error: %v", err)
+	}
+	for i, b := range backends {
+		b.SetWeight(cfg.Endpoints[i].Weight)
+	}
+
+	var hashOpts []proxykit.ConsistentHashOption
+	if keyFn, _ := parseHashKey(cfg.HashKey); keyFn != nil {
+		hashOpts = append(hashOpts, proxykit.WithHashKey(keyFn))
+	}
+	hashOpts = append(hashOpts, proxykit.WithVirtualNodes(cfg.VirtualNodes))
+	balancer, err := newBalancer(cfg.Balancer, backends, hashOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return proxykit.NewRoute(cfg.PrefixPath, balancer, proxykit.WithRewrite(proxykit.RewriteConfig{
+		KeepPrefix:      cfg.KeepPrefix,
+		AddPrefix:       cfg.AddPrefix,
+		RequestHeaders:  cfg.RequestHeaders,
+		ResponseHeaders: cfg.ResponseHeaders,
+	}))
+}
+
+// setRoute adds or replaces a dynamic route created by newRoute, it must be called with the routesMu held.
+func (p *Proxy) setRoute(cfg RouteConfig, route *proxykit.Route) {
+	old := p.manager.PutRoute(route)
+	proxykit.StartHealthChecks(route.Backends, cfg.HealthCheck)
+	if old != nil {
+		stopHealthChecks(old)
+	}
+	p.dynamicRoutes[cfg.PrefixPath] = &dynamicRoute{config: cfg, route: route}
+}
+
+// removeRoute removes a dynamic route, it must be called with the routesMu held.
+func (p *Proxy) removeRoute(prefixPath string) {
+	if route, ok := p.manager.RemoveRoute(prefixPath); ok {
+		stopHealthChecks(route)
+	}
+	delete(p.dynamicRoutes, prefixPath)
+}
+
+func stopHealthChecks(route *proxykit.Route) {
+	for _, b := range route.Balancer.GetBackends() {
+		b.StopHealthCheck()
+	}
+}
+
+// sortRoutes sorts the dynamic routes for longest prefix matching, it must be called with the routesMu held.
+func (p *Proxy) sortRoutes() {
+	order := make([]*dynamicRoute, 0, len(p.dynamicRoutes))
+	for _, dr := range p.dynamicRoutes {
+		order = append(order, dr)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return len(order[i].config.PrefixPath) > len(order[j].config.PrefixPath)
+	})
+	p.matchOrder = order
+}
+
+func (p *Proxy) matchRoute(path string) *proxykit.Route {
+	p.routesMu.RLock()
+	defer p.routesMu.RUnlock()
+
+	for _, dr := range p.matchOrder {
+		if strings.HasPrefix(path, dr.config.PrefixPath) {
+			return dr.route
+		}
+	}
+	return nil
+}
+
+// dispatch is the NoRoute handler of gin, the dynamic routes serve the requests that do not
+// match the routes registered to gin, the other requests are handled by the NoRoute handlers.
+func (p *Proxy) dispatch(c *gin.Context) {
+	route := p.matchRoute(c.Request.URL.Path)
+	if route != nil {
+		route.Proxy.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+	if len(p.noRoute) == 0 {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+	for _, handler := range p.noRoute {
+		handler(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+}
+
+// -------------------------------------------------------------------------------------------
+
+func (p *Proxy) handleListRoutes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"routes": p.Routes()})
+}
+
+func (p *Proxy) handleAddRoute(c *gin.Context) {
+	p.handleModifyRoute(c, p.AddRoute)
+}
+
+func (p *Proxy) handleUpdateRoute(c *gin.Context) {
+	p.handleModifyRoute(c, p.UpdateRoute)
+}
+
+func (p *Proxy) handleModifyRoute(c *gin.Context, fn func(RouteConfig) error) {
+	var cfg RouteConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid JSON: " + err.Error()})
+		return
+	}
+	if err := fn(cfg); err != nil {
+		c.JSON(routeErrorStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (p *Proxy) handleDeleteRoute(c *gin.Context) {
+	prefixPath := c.Query("prefixPath")
+	if prefixPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "'prefixPath' query parameter is required"})
+		return
+	}
+	if err := p.DeleteRoute(prefixPath); err != nil {
+		c.JSON(routeErrorStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func routeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRouteExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrRouteNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
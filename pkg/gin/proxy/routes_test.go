@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEchoServer(t *testing.T, name string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		w.Header().Set("X-Debug", "true")
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doGet(t *testing.T, url string) (int, string, http.Header) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header
+}
+
+func doJSON(t *testing.T, method string, url string, body interface{}) int {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestProxy_LoadRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv1 := newEchoServer(t, "srv1")
+	srv2 := newEchoServer(t, "srv2")
+
+	file := filepath.Join(t.TempDir(), "routes.yml")
+	content := `routes:
+  - prefixPath: /api/
+    endpoints:
+      - url: ` + srv1.URL + `
+    keepPrefix: true
+    requestHeaders:
+      set:
+        X-Token: abc
+    responseHeaders:
+      remove: [X-Debug]
+  - prefixPath: /api/v2
+    endpoints:
+      - url: ` + srv2.URL + `
+        weight: 2
+    balancer: consistent_hash
+    hashKey: header:X-User-Id
+    addPrefix: /v2
+    healthCheck:
+      interval: 1h
+`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0666))
+
+	r := gin.New()
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	p := New(r)
+	require.NoError(t, p.LoadRoutes(file))
+	require.Len(t, p.Routes(), 2)
+
+	proxyServer := httptest.NewServer(r)
+	defer proxyServer.Close()
+
+	code, body, header := doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "/api/users", body)
+	assert.Equal(t, "abc", header.Get("X-Token"))
+	assert.Empty(t, header.Get("X-Debug"))
+
+	// longest prefix match
+	code, body, header = doGet(t, proxyServer.URL+"/api/v2/users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "/v2/users", body)
+	assert.Equal(t, "srv2", header.Get("X-Backend"))
+
+	code, _, _ = doGet(t, proxyServer.URL+"/ping")
+	assert.Equal(t, http.StatusOK, code)
+	code, _, _ = doGet(t, proxyServer.URL+"/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// hot reload, /api/v2/ is removed and /api/ is changed
+	time.Sleep(100 * time.Millisecond)
+	content = `routes:
+  - prefixPath: /api/
+    endpoints:
+      - url: ` + srv2.URL + `
+`
+	require.NoError(t, os.WriteFile(file, []byte(content), 0666))
+	for i := 0; i < 100; i++ {
+		if routes := p.Routes(); len(routes) == 1 && routes[0].Endpoints[0].URL == srv2.URL {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	code, body, header = doGet(t, proxyServer.URL+"/api/v2/users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "/v2/users", body)
+	assert.Equal(t, "srv2", header.Get("X-Backend"))
+	assert.Equal(t, "true", header.Get("X-Debug"))
+
+	assert.Error(t, p.LoadRoutes(filepath.Join(t.TempDir(), "notfound.yml")))
+}
+
+func TestProxy_NoRouteHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := newEchoServer(t, "srv")
+
+	r := gin.New()
+	p := New(r, WithNoRouteHandler(func(c *gin.Context) {
+		c.String(http.StatusTeapot, "no route")
+	}))
+	proxyServer := httptest.NewServer(r)
+	defer proxyServer.Close()
+
+	// the dispatcher is registered before any dynamic route is applied
+	code, body, _ := doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, http.StatusTeapot, code)
+	assert.Equal(t, "no route", body)
+
+	require.NoError(t, p.ApplyRoutes([]RouteConfig{{PrefixPath: "/api", Endpoints: []EndpointConfig{{URL: srv.URL}}}}))
+	code, body, _ = doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "/users", body)
+
+	code, body, _ = doGet(t, proxyServer.URL+"/unknown")
+	assert.Equal(t, http.StatusTeapot, code)
+	assert.Equal(t, "no route", body)
+}
+
+func TestProxy_ApplyRoutesError(t *testing.T) {
+	srv := newEchoServer(t, "srv")
+	r := gin.New()
+	p := New(r)
+	require.NoError(t, p.Pass("/static", []string{srv.URL}))
+
+	testData := []RouteConfig{
+		{PrefixPath: "/", Endpoints: []EndpointConfig{{URL: srv.URL}}},
+		{PrefixPath: "/api"},
+		{PrefixPath: "/api", Endpoints: []EndpointConfig{{URL: "localhost"}}},
+		{PrefixPath: "/api", Endpoints: []EndpointConfig{{URL: srv.URL}}, Balancer: "unknown"},
+		{PrefixPath: "/api", Endpoints: []EndpointConfig{{URL: srv.URL}}, HashKey: "query:id"},
+		{PrefixPath: "/static", Endpoints: []EndpointConfig{{URL: srv.URL}}},
+	}
+	for _, cfg := range testData {
+		assert.Error(t, p.ApplyRoutes([]RouteConfig{cfg}), cfg.PrefixPath)
+	}
+
+	cfg := RouteConfig{PrefixPath: "/api", Endpoints: []EndpointConfig{{URL: srv.URL}}}
+	assert.Error(t, p.ApplyRoutes([]RouteConfig{cfg, cfg}))
+	assert.Empty(t, p.Routes())
+
+	// no route is changed if any route is invalid
+	require.NoError(t, p.ApplyRoutes([]RouteConfig{cfg}))
+	for _, invalid := range testData {
+		other := RouteConfig{PrefixPath: "/other", Endpoints: []EndpointConfig{{URL: srv.URL}}}
+		assert.Error(t, p.ApplyRoutes([]RouteConfig{other, invalid}), invalid.PrefixPath)
+		routes := p.Routes()
+		require.Len(t, routes, 1)
+		assert.Equal(t, "/api/", routes[0].PrefixPath)
+	}
+}
+
+func TestProxy_AdminAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv1 := newEchoServer(t, "srv1")
+	srv2 := newEchoServer(t, "srv2")
+
+	r := gin.New()
+	p := New(r, WithAdminEndpoints("/admin", dummyMiddleware))
+	require.NoError(t, p.Pass("/static", []string{srv1.URL}))
+	proxyServer := httptest.NewServer(r)
+	defer proxyServer.Close()
+	adminURL := proxyServer.URL + "/admin/routes"
+
+	routes := r.Routes()
+	assert.True(t, routeExists(routes, "GET", "/admin/routes"))
+	assert.True(t, routeExists(routes, "DELETE", "/admin/routes"))
+
+	cfg := RouteConfig{
+		PrefixPath: "/api",
+		Endpoints:  []EndpointConfig{{URL: srv1.URL}},
+		Balancer:   BalancerWeightedRoundRobin,
+	}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, adminURL, cfg))
+	assert.Equal(t, http.StatusConflict, doJSON(t, http.MethodPost, adminURL, cfg))
+	_, _, header := doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, "srv1", header.Get("X-Backend"))
+
+	cfg.Endpoints = []EndpointConfig{{URL: srv2.URL}}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPut, adminURL, cfg))
+	_, _, header = doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, "srv2", header.Get("X-Backend"))
+
+	code, body, _ := doGet(t, adminURL)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"prefixPath":"/api/"`)
+
+	cfg.PrefixPath = "/other"
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodPut, adminURL, cfg))
+	cfg.PrefixPath = "/static"
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, http.MethodPost, adminURL, cfg))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, http.MethodPost, adminURL, "invalid"))
+
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodDelete, adminURL+"?prefixPath=/api/", nil))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodDelete, adminURL+"?prefixPath=/api/", nil))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, http.MethodDelete, adminURL, nil))
+	code, _, _ = doGet(t, proxyServer.URL+"/api/users")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// the static route is not affected
+	_, _, header = doGet(t, proxyServer.URL+"/static/users")
+	assert.Equal(t, "srv1", header.Get("X-Backend"))
+}
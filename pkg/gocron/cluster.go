@@ -0,0 +1,217 @@
+package gocron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/go-dev-frame/sponge/pkg/dlock"
+)
+
+// task name and distributed lock mapping, used by singleton tasks
+var lockers = sync.Map{}
+
+func defaultNodeID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// WithNodeID set the id of the current node, default is hostname-pid.
+func WithNodeID(id string) Option {
+	return func(o *options) {
+		if id != "" {
+			o.nodeID = id
+		}
+	}
+}
+
+// WithLocker set the function to create a distributed locker for the singleton task, the key
+// of the locker should be unique for each task name, e.g. dlock.NewRedisLock(client, "gocron:"+taskName).
+func WithLocker(newLocker func(taskName string) (dlock.Locker, error)) Option {
+	return func(o *options) {
+		o.newLocker = newLocker
+	}
+}
+
+// WithLockRenewInterval set the interval of renewing the lock while the singleton task is running,
+// it should be less than the expiration of the lock, default 3s.
+func WithLockRenewInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.renewInterval = d
+		}
+	}
+}
+
+// newJob returns the job of the task, the singleton task is guarded by the distributed lock,
+// only one node in the cluster runs the task at a time, the others skip the execution.
+func newJob(task *Task, tc *taskContext) (cron.Job, error) {
+	o, log := gOpts, cronLog
+	if !task.IsSingleton {
+		return cron.FuncJob(func() {
+			execute(tc.get(), task, o, log)
+		}), nil
+	}
+
+	if o.newLocker == nil {
+		return nil, fmt.Errorf("task '%s' is singleton, but the locker is not set, use WithLocker in Init", task.Name)
+	}
+	locker, err := o.newLocker(task.Name)
+	if err != nil {
+		return nil, fmt.Errorf("create locker of task '%s' error: %v", task.Name, err)
+	}
+	l := &singletonLock{name: task.Name, locker: locker, o: o, log: log}
+	lockers.Store(task.Name, l)
+	return newSingletonJob(task, l, tc), nil
+}
+
+// newSingletonJob returns the job which runs the task only if the lock is acquired.
+func newSingletonJob(task *Task, l *singletonLock, tc *taskContext) cron.Job {
+	singleton := cron.FuncJob(func() {
+		ctx, cancel := context.WithCancel(tc.get())
+		defer cancel()
+		ok, err := l.acquire(cancel)
+		if err != nil {
+			l.log.Error(err, "try lock", "task", task.Name, "node", l.o.nodeID)
+			return
+		}
+		if !ok {
+			// the task of this tick is run by another node
+			l.log.Info("skip", "task", task.Name, "node", l.o.nodeID)
+			return
+		}
+
+		defer l.finish()
+		l.log.Info("singleton", "task", task.Name, "node", l.o.nodeID)
+		execute(ctx, task, l.o, l.log)
+	})
+
+	// skip the execution if the previous one is still running on the current node
+	return cron.NewChain(cron.SkipIfStillRunning(l.log)).Then(singleton)
+}
+
+// singletonLock is the distributed lock of the singleton task on the current node. The lock is
+// not released after the task finishes, but held until the next tick fires on the current node,
+// so the other nodes whose clocks are a little behind can't acquire it and run the same tick again.
+type singletonLock struct {
+	name   string
+	locker dlock.Locker
+	o      *options
+	log    cron.Logger
+
+	mu             sync.Mutex
+	stop           func() // stops renewing the held lock, nil if the lock is not held
+	running        bool   // the task is running with the lock
+	releasePending bool   // release the lock after the task finishes
+}
+
+// acquire releases the lock held by the previous tick, and tries to acquire the lock for the
+// current tick, onLost is called if the lock is lost while it is held.
+func (l *singletonLock) acquire(onLost func()) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked()
+	ok, err := l.locker.TryLock(context.Background())
+	if err != nil || !ok {
+		return false, err
+	}
+	l.stop = renewLock(l.name, l.locker, l.o, l.log, onLost)
+	l.running, l.releasePending = true, false
+	return true, nil
+}
+
+// finish marks the task finished, the lock is held until the next tick unless release is called.
+func (l *singletonLock) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running = false
+	if l.releasePending {
+		l.releaseLocked()
+	}
+}
+
+// release releases the lock if it is held, e.g. the task is paused or deleted on the current node,
+// the lock is released after the task finishes if it is running.
+func (l *singletonLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		l.releasePending = true
+		return
+	}
+	l.releaseLocked()
+}
+
+func (l *singletonLock) releaseLocked() {
+	l.releasePending = false
+	if l.stop == nil {
+		return
+	}
+	l.stop()
+	l.stop = nil
+	if err := l.locker.Unlock(context.Background()); err != nil {
+		l.log.Error(err, "unlock", "task", l.name, "node", l.o.nodeID)
+	}
+}
+
+// renewLock renews the lock periodically until stop is called, onLost is called and the renewal
+// stops if the lock is lost.
+func renewLock(name string, locker dlock.Locker, o *options, log cron.Logger, onLost func()) (stop func()) {
+	extender, ok := locker.(dlock.Extender)
+	if !ok {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(o.renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ok, err := extender.Extend(context.Background())
+				if ok {
+					continue
+				}
+				if err == nil {
+					err = errors.New("lock is lost")
+				}
+				log.Error(err, "renew lock", "task", name, "node", o.nodeID)
+				onLost()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// releaseLocker releases the distributed lock of the task held by the current node.
+func releaseLocker(name string) {
+	if v, ok := lockers.Load(name); ok {
+		v.(*singletonLock).release()
+	}
+}
+
+// closeLocker releases and closes the distributed locker of the task.
+func closeLocker(name string) {
+	if v, ok := lockers.LoadAndDelete(name); ok {
+		l := v.(*singletonLock)
+		l.release()
+		_ = l.locker.Close()
+	}
+}
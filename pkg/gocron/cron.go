@@ -2,20 +2,26 @@
 package gocron
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
 var (
-	c *cron.Cron
+	c       *cron.Cron
+	gOpts   = defaultOptions()
+	cronLog cron.Logger
 	// task name and id mapping, used to add, delete, modify and query tasks
 	nameID = sync.Map{}
 	// id and task name mapping, used in log printing
 	idName = sync.Map{}
+	// task name and managed task mapping, including the paused tasks
+	nameTask = sync.Map{}
 )
 
 // Task scheduled task
@@ -28,6 +34,19 @@ type Task struct {
 	Name      string // task name
 	Fn        func() // task function
 	IsRunOnce bool   // if the task is only run once
+
+	// task function with context and error, it is used instead of Fn if not nil, the context is
+	// canceled when the timeout is reached, the task is retried if the error is not nil.
+	FnWithContext func(ctx context.Context) error
+	Timeout       time.Duration // timeout of each attempt, 0 means no timeout, only for FnWithContext, Run returns error if it is set for Fn
+	Retries       int           // max retries when the task fails, 0 means no retry
+	RetryInterval time.Duration // interval before the first retry, doubled for each retry, default 1s
+	Jitter        time.Duration // max random delay before each execution, spread the executions of many tasks
+
+	// if true, only one node in the cluster runs the task at a time, guarded by the distributed
+	// lock created by WithLocker, the execution is skipped if the task is running on any node,
+	// it can't be used with IsRunOnce.
+	IsSingleton bool
 }
 
 // Init initialize and start timed tasks
@@ -36,6 +55,7 @@ func Init(opts ...Option) error {
 	o.apply(opts...)
 
 	log := &zapLog{zapLog: o.zapLog, isOnlyPrintError: o.isOnlyPrintError}
+	gOpts, cronLog = o, log
 	cronOpts := []cron.Option{
 		cron.WithLogger(log),
 		cron.WithChain(
@@ -58,9 +78,12 @@ func Run(tasks ...*Task) error {
 		return errors.New("cron is not initialized")
 	}
 
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
 	var errs []string
 	for _, task := range tasks {
-		if IsRunningTask(task.Name) {
+		if _, ok := nameTask.Load(task.Name); ok {
 			errs = append(errs, fmt.Sprintf("task '%s' is already exists", task.Name))
 			continue
 		}
@@ -70,13 +93,21 @@ func Run(tasks ...*Task) error {
 			continue
 		}
 
-		id, err := c.AddFunc(task.TimeSpec, task.Fn)
+		tc := newTaskContext()
+		job, err := newJob(task, tc)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		id, err := c.AddJob(task.TimeSpec, job)
 		if err != nil {
+			closeLocker(task.Name)
 			errs = append(errs, fmt.Sprintf("run task '%s' error: %v", task.Name, err))
 			continue
 		}
 		idName.Store(id, task.Name)
 		nameID.Store(task.Name, id)
+		nameTask.Store(task.Name, &managedTask{Task: task, job: job, ctx: tc})
 	}
 
 	if len(errs) > 0 {
@@ -87,14 +118,29 @@ func Run(tasks ...*Task) error {
 }
 
 func checkRunOnce(task *Task) error {
-	if task.Fn == nil {
+	if task.Fn == nil && task.FnWithContext == nil {
 		return fmt.Errorf("task '%s' is nil", task.Name)
 	}
+	if task.Timeout > 0 && task.FnWithContext == nil {
+		return fmt.Errorf("task '%s' timeout is only supported by FnWithContext", task.Name)
+	}
+	if task.IsRunOnce && task.IsSingleton {
+		// the task would be deleted by the node which runs it, but the other nodes keep it
+		return fmt.Errorf("task '%s' can't be both run once and singleton", task.Name)
+	}
 	if task.IsRunOnce {
-		job := task.Fn
-		task.Fn = func() {
-			job()
-			DeleteTask(task.Name)
+		if task.FnWithContext != nil {
+			job := task.FnWithContext
+			task.FnWithContext = func(ctx context.Context) error {
+				defer DeleteTask(task.Name)
+				return job(ctx)
+			}
+		} else {
+			job := task.Fn
+			task.Fn = func() {
+				job()
+				DeleteTask(task.Name)
+			}
 		}
 	}
 	return nil
@@ -116,24 +162,34 @@ func GetRunningTasks() []string {
 	return names
 }
 
-// DeleteTask stop and delete the specified task
+// DeleteTask stop and delete the specified task, including the paused task
 func DeleteTask(name string) {
-	if id, ok := nameID.Load(name); ok {
-		entryID, isOk := id.(cron.EntryID)
-		if !isOk {
-			return
-		}
-		c.Remove(entryID)
-		nameID.Delete(name)
-		idName.Delete(entryID)
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
+	if c != nil {
+		removeEntry(name)
+	}
+	if v, ok := nameTask.LoadAndDelete(name); ok {
+		v.(*managedTask).ctx.interrupt()
+		closeLocker(name)
 	}
 }
 
-// Stop all scheduled tasks
+// Stop all scheduled tasks, the executions in progress are interrupted as PauseTask does,
+// and the locks of the singleton tasks held by the current node are released.
 func Stop() {
 	if c != nil {
 		c.Stop()
 	}
+	nameTask.Range(func(key, value interface{}) bool {
+		value.(*managedTask).ctx.interrupt()
+		return true
+	})
+	lockers.Range(func(key, value interface{}) bool {
+		value.(*singletonLock).release()
+		return true
+	})
 }
 
 // EverySecond every second size (1~59)
@@ -1,11 +1,21 @@
 package gocron
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/dlock"
 )
 
 func TestInitAndRun(t *testing.T) {
@@ -149,3 +159,147 @@ func TestEvery(t *testing.T) {
 
 	time.Sleep(time.Second * 7)
 }
+
+func TestSingletonTask(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	newLocker := func(taskName string) (dlock.Locker, error) {
+		return dlock.NewRedisLock(client, "gocron:"+taskName, redsync.WithExpiry(time.Second*2))
+	}
+
+	var executions []*Execution
+	mu := &sync.Mutex{}
+	err := Init(
+		WithLog(zap.NewNop()),
+		WithNodeID("node-1"),
+		WithLocker(newLocker),
+		WithLockRenewInterval(time.Millisecond*500),
+		WithExecutionHook(func(e *Execution) {
+			if e.TaskName != "singletonTask" {
+				return
+			}
+			mu.Lock()
+			executions = append(executions, e)
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	// the lock is held by another node
+	otherNode, _ := newLocker("singletonTask")
+	ok, err := otherNode.TryLock(context.Background())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	var count int32
+	err = Run(&Task{
+		Name:        "singletonTask",
+		TimeSpec:    "@every 1s",
+		IsSingleton: true,
+		Fn: func() {
+			atomic.AddInt32(&count, 1)
+			time.Sleep(time.Millisecond * 2500) // longer than the expiration of the lock
+		},
+	})
+	assert.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 1200)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&count))
+
+	// the lock is released by another node
+	assert.NoError(t, otherNode.Unlock(context.Background()))
+	time.Sleep(time.Millisecond * 1500)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	// the lock is renewed while running
+	mr.FastForward(time.Second)
+	assert.LessOrEqual(t, mr.TTL("gocron:singletonTask"), time.Second)
+	time.Sleep(time.Millisecond * 600)
+	assert.Equal(t, time.Second*2, mr.TTL("gocron:singletonTask"))
+	ok, _ = otherNode.TryLock(context.Background())
+	assert.False(t, ok)
+
+	time.Sleep(time.Millisecond * 1500)
+	DeleteTask("singletonTask")
+	mu.Lock()
+	assert.NotEmpty(t, executions)
+	assert.Equal(t, "singletonTask", executions[0].TaskName)
+	assert.Equal(t, "node-1", executions[0].NodeID)
+	mu.Unlock()
+
+	// the locker is not set
+	_ = Init(WithLog(zap.NewNop()))
+	err = Run(&Task{Name: "singletonTask2", TimeSpec: "@every 1s", IsSingleton: true, Fn: func() {}})
+	assert.Error(t, err)
+
+	// run once is not supported by the singleton task
+	_ = Init(WithLog(zap.NewNop()), WithLocker(newLocker))
+	err = Run(&Task{Name: "singletonTask3", TimeSpec: "@every 1s", IsSingleton: true, IsRunOnce: true, Fn: func() {}})
+	assert.Error(t, err)
+	assert.False(t, IsRunningTask("singletonTask3"))
+}
+
+func TestSingletonTask_SameTick(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	var count int32
+	task := &Task{Name: "sameTickTask", Fn: func() { atomic.AddInt32(&count, 1) }}
+	newNode := func(nodeID string) (cron.Job, *singletonLock) {
+		locker, _ := dlock.NewRedisLock(client, "gocron:"+task.Name)
+		o := defaultOptions()
+		o.nodeID = nodeID
+		o.history = nil
+		l := &singletonLock{name: task.Name, locker: locker, o: o, log: &zapLog{zapLog: zap.NewNop()}}
+		return newSingletonJob(task, l, newTaskContext()), l
+	}
+	node1, lock1 := newNode("node-1")
+	node2, lock2 := newNode("node-2")
+	defer lock1.release()
+	defer lock2.release()
+
+	// the task finishes quickly on node-1, node-2 fires the same tick later
+	node1.Run()
+	node2.Run()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// next tick, node-2 fires earlier than node-1
+	node2.Run()
+	node1.Run()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	// node-1 releases the lock, e.g. the task is paused
+	lock1.release()
+	node2.Run()
+	node1.Run()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+}
+
+func TestSingletonTask_LockLost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	locker, _ := dlock.NewRedisLock(client, "gocron:lostTask")
+	o := defaultOptions()
+	o.renewInterval = time.Millisecond * 50
+	l := &singletonLock{name: "lostTask", locker: locker, o: o, log: &zapLog{zapLog: zap.NewNop()}}
+	defer l.release()
+
+	var taskErr error
+	task := &Task{Name: "lostTask", FnWithContext: func(ctx context.Context) error {
+		mr.Del("gocron:lostTask") // the lock expires or is taken by others
+		select {
+		case <-ctx.Done():
+			taskErr = ctx.Err()
+		case <-time.After(time.Second * 3):
+		}
+		return taskErr
+	}}
+	newSingletonJob(task, l, newTaskContext()).Run()
+	assert.ErrorIs(t, taskErr, context.Canceled)
+}
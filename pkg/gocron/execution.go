@@ -0,0 +1,119 @@
+package gocron
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Execution is the record of a task execution.
+type Execution struct {
+	TaskName  string    `json:"taskName"`
+	NodeID    string    `json:"nodeID"` // the node that ran the task
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Attempts  int       `json:"attempts"` // 1 + retries
+	Error     string    `json:"error"`    // error of the last attempt, empty if succeeded
+}
+
+// Duration returns the duration of the execution, including the retries.
+func (e *Execution) Duration() time.Duration {
+	return e.EndTime.Sub(e.StartTime)
+}
+
+// WithExecutionHook set the function called after each execution of the tasks,
+// e.g. save the node that ran the task.
+func WithExecutionHook(fn func(e *Execution)) Option {
+	return func(o *options) {
+		o.executionHook = fn
+	}
+}
+
+// WithHistory set the store of execution history, default is in-memory store which keeps
+// the last 100 executions of each task, e.g. NewGormHistory save the history to database.
+func WithHistory(store HistoryStore) Option {
+	return func(o *options) {
+		if store != nil {
+			o.history = store
+		}
+	}
+}
+
+// execute runs the task with jitter, timeout and retries, and records the execution, the context
+// of FnWithContext is derived from ctx. If ctx is canceled, e.g. the task is paused or deleted, or
+// the lock of the singleton task is lost, the jitter and retry waits are interrupted.
+func execute(ctx context.Context, task *Task, o *options, log cron.Logger) {
+	if task.Jitter > 0 && !sleep(ctx, time.Duration(rand.Int63n(int64(task.Jitter)))) { //nolint
+		log.Info("canceled", "task", task.Name)
+		return
+	}
+
+	e := &Execution{TaskName: task.Name, NodeID: o.nodeID, StartTime: time.Now()}
+	interval := task.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	var err error
+	for {
+		e.Attempts++
+		err = runOnce(ctx, task)
+		if err == nil || e.Attempts > task.Retries || ctx.Err() != nil {
+			break
+		}
+		log.Error(err, "retry", "task", task.Name, "attempt", e.Attempts, "interval", interval.String())
+		if !sleep(ctx, interval) {
+			break
+		}
+		interval *= 2
+	}
+	e.EndTime = time.Now()
+	if err != nil {
+		e.Error = err.Error()
+		log.Error(err, "failed", "task", task.Name, "attempts", e.Attempts)
+	}
+
+	if o.history != nil {
+		if err := o.history.Save(context.Background(), e); err != nil {
+			log.Error(err, "save history", "task", task.Name)
+		}
+	}
+	if o.executionHook != nil {
+		o.executionHook(e)
+	}
+}
+
+// runOnce runs the task function once, the panic is converted to error.
+func runOnce(ctx context.Context, task *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if task.FnWithContext == nil {
+		task.Fn()
+		return nil
+	}
+
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+	return task.FnWithContext(ctx)
+}
+
+// sleep waits for the duration, false is returned if ctx is done before the duration elapses.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,110 @@
+package gocron
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestExecute(t *testing.T) {
+	o := defaultOptions()
+	log := &zapLog{zapLog: zap.NewNop()}
+
+	// retry until success
+	var count int32
+	task := &Task{
+		Name:          "retryTask",
+		RetryInterval: time.Millisecond * 10,
+		Retries:       3,
+		FnWithContext: func(ctx context.Context) error {
+			if atomic.AddInt32(&count, 1) < 3 {
+				return errors.New("mock error")
+			}
+			return nil
+		},
+	}
+	execute(context.Background(), task, o, log)
+	records, _ := o.history.List(context.Background(), task.Name, 0)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 3, records[0].Attempts)
+	assert.Empty(t, records[0].Error)
+	assert.GreaterOrEqual(t, records[0].Duration(), time.Millisecond*30) // 10ms + 20ms
+
+	// timeout of each attempt
+	task = &Task{
+		Name:          "timeoutTask",
+		Timeout:       time.Millisecond * 50,
+		Retries:       1,
+		RetryInterval: time.Millisecond * 10,
+		FnWithContext: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	execute(context.Background(), task, o, log)
+	records, _ = o.history.List(context.Background(), task.Name, 0)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 2, records[0].Attempts)
+	assert.Equal(t, context.DeadlineExceeded.Error(), records[0].Error)
+
+	// panic is recorded as error, jitter delays the execution
+	var e *Execution
+	o.executionHook = func(ex *Execution) { e = ex }
+	task = &Task{
+		Name:   "panicTask",
+		Jitter: time.Millisecond * 20,
+		Fn:     func() { panic("mock panic") },
+	}
+	execute(context.Background(), task, o, log)
+	assert.NotNil(t, e)
+	assert.Equal(t, 1, e.Attempts)
+	assert.Contains(t, e.Error, "mock panic")
+}
+
+func TestExecute_Interrupt(t *testing.T) {
+	o := defaultOptions()
+	log := &zapLog{zapLog: zap.NewNop()}
+
+	// the retry wait is interrupted
+	var count int32
+	task := &Task{
+		Name:          "interruptRetryTask",
+		RetryInterval: time.Hour,
+		Retries:       3,
+		FnWithContext: func(ctx context.Context) error {
+			atomic.AddInt32(&count, 1)
+			return errors.New("mock error")
+		},
+	}
+	tc := newTaskContext()
+	time.AfterFunc(time.Millisecond*50, tc.interrupt)
+	start := time.Now()
+	execute(tc.get(), task, o, log)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	records, _ := o.history.List(context.Background(), task.Name, 0)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 1, records[0].Attempts)
+
+	// the jitter wait is interrupted, the task is not run
+	task = &Task{
+		Name:   "interruptJitterTask",
+		Jitter: time.Hour,
+		Fn:     func() { atomic.AddInt32(&count, 1) },
+	}
+	time.AfterFunc(time.Millisecond*50, tc.interrupt)
+	start = time.Now()
+	execute(tc.get(), task, o, log)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// the later executions are not affected
+	task.Jitter = 0
+	execute(tc.get(), task, o, log)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
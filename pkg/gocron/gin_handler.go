@@ -0,0 +1,109 @@
+package gocron
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/go-dev-frame/sponge/pkg/errcode"
+	"github.com/go-dev-frame/sponge/pkg/gin/response"
+)
+
+// RescheduleRequest request params of reschedule task
+type RescheduleRequest struct {
+	TimeSpec string `json:"timeSpec" binding:"required"`
+}
+
+// RegisterRoutes register the gin handlers to manage the tasks without redeploys,
+// protect the router group with authentication middleware, e.g.
+//
+//	gocron.RegisterRoutes(r.Group("/admin/gocron", middleware.Auth()))
+//
+// routes:
+//
+//	GET    /tasks                  list tasks with time spec, next run and last execution
+//	GET    /tasks/:name/history    list execution history of the task, query param limit, default 20
+//	POST   /tasks/:name/pause      pause the task
+//	POST   /tasks/:name/resume     resume the paused task
+//	POST   /tasks/:name/trigger    run the task immediately
+//	PUT    /tasks/:name/spec       change the time spec of the task, body {"timeSpec": "@every 1m"}
+//	DELETE /tasks/:name            delete the task
+func RegisterRoutes(r gin.IRouter) {
+	r.GET("/tasks", listTasksHandler)
+	r.GET("/tasks/:name/history", getHistoryHandler)
+	r.POST("/tasks/:name/pause", pauseTaskHandler)
+	r.POST("/tasks/:name/resume", resumeTaskHandler)
+	r.POST("/tasks/:name/trigger", triggerTaskHandler)
+	r.PUT("/tasks/:name/spec", rescheduleTaskHandler)
+	r.DELETE("/tasks/:name", deleteTaskHandler)
+}
+
+func listTasksHandler(c *gin.Context) {
+	response.Success(c, gin.H{"tasks": ListTasks(c.Request.Context())})
+}
+
+func getHistoryHandler(c *gin.Context) {
+	name := c.Param("name")
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	records, err := GetHistory(c.Request.Context(), name, limit)
+	if err != nil {
+		response.Error(c, errcode.InternalServerError.WithDetails(err.Error()))
+		return
+	}
+	executions := make([]*ExecutionInfo, 0, len(records))
+	for _, record := range records {
+		executions = append(executions, newExecutionInfo(record))
+	}
+	response.Success(c, gin.H{"task": name, "executions": executions})
+}
+
+func pauseTaskHandler(c *gin.Context) {
+	outputResult(c, PauseTask(c.Param("name")))
+}
+
+func resumeTaskHandler(c *gin.Context) {
+	outputResult(c, ResumeTask(c.Param("name")))
+}
+
+func triggerTaskHandler(c *gin.Context) {
+	outputResult(c, TriggerTask(c.Param("name")))
+}
+
+func rescheduleTaskHandler(c *gin.Context) {
+	form := &RescheduleRequest{}
+	if err := c.ShouldBindJSON(form); err != nil {
+		response.Error(c, errcode.InvalidParams.WithDetails(err.Error()))
+		return
+	}
+	err := RescheduleTask(c.Param("name"), form.TimeSpec)
+	if err != nil && !errors.Is(err, ErrTaskNotFound) {
+		response.Error(c, errcode.InvalidParams.WithDetails(err.Error()))
+		return
+	}
+	outputResult(c, err)
+}
+
+func deleteTaskHandler(c *gin.Context) {
+	name := c.Param("name")
+	if _, err := loadTask(name); err != nil {
+		outputResult(c, err)
+		return
+	}
+	DeleteTask(name)
+	response.Success(c)
+}
+
+func outputResult(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		response.Success(c)
+	case errors.Is(err, ErrTaskNotFound):
+		response.Error(c, errcode.NotFound.WithDetails(err.Error()))
+	default:
+		response.Error(c, errcode.InternalServerError.WithDetails(err.Error()))
+	}
+}
@@ -0,0 +1,96 @@
+package gocron
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/errcode"
+)
+
+type ginResult struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+func doRequest(t *testing.T, r http.Handler, method, path string, body interface{}) *ginResult {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	result := &ginResult{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+	return result
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	err := Init(WithLog(zap.NewNop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+	err = Run(&Task{Name: "ginTask", TimeSpec: "@every 1h", Fn: func() {}})
+	assert.NoError(t, err)
+	defer DeleteTask("ginTask")
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	RegisterRoutes(r.Group("/admin/gocron"))
+
+	result := doRequest(t, r, http.MethodPost, "/admin/gocron/tasks/ginTask/pause", nil)
+	assert.Equal(t, 0, result.Code)
+	assert.True(t, IsPausedTask("ginTask"))
+
+	result = doRequest(t, r, http.MethodPut, "/admin/gocron/tasks/ginTask/spec", &RescheduleRequest{TimeSpec: "@every 2h"})
+	assert.Equal(t, 0, result.Code)
+	result = doRequest(t, r, http.MethodPut, "/admin/gocron/tasks/ginTask/spec", &RescheduleRequest{TimeSpec: "invalid"})
+	assert.Equal(t, errcode.InvalidParams.Code(), result.Code)
+	result = doRequest(t, r, http.MethodPut, "/admin/gocron/tasks/ginTask/spec", nil)
+	assert.Equal(t, errcode.InvalidParams.Code(), result.Code)
+
+	result = doRequest(t, r, http.MethodPost, "/admin/gocron/tasks/ginTask/resume", nil)
+	assert.Equal(t, 0, result.Code)
+	assert.False(t, IsPausedTask("ginTask"))
+
+	result = doRequest(t, r, http.MethodPost, "/admin/gocron/tasks/ginTask/trigger", nil)
+	assert.Equal(t, 0, result.Code)
+
+	result = doRequest(t, r, http.MethodGet, "/admin/gocron/tasks", nil)
+	assert.Equal(t, 0, result.Code)
+	tasks := struct {
+		Tasks []*TaskInfo `json:"tasks"`
+	}{}
+	assert.NoError(t, json.Unmarshal(result.Data, &tasks))
+	var found bool
+	for _, info := range tasks.Tasks {
+		if info.Name == "ginTask" {
+			found = true
+			assert.Equal(t, "@every 2h", info.TimeSpec)
+			assert.False(t, info.NextRun.IsZero())
+		}
+	}
+	assert.True(t, found)
+
+	result = doRequest(t, r, http.MethodGet, "/admin/gocron/tasks/ginTask/history?limit=1", nil)
+	assert.Equal(t, 0, result.Code)
+
+	result = doRequest(t, r, http.MethodDelete, "/admin/gocron/tasks/ginTask", nil)
+	assert.Equal(t, 0, result.Code)
+	result = doRequest(t, r, http.MethodDelete, "/admin/gocron/tasks/ginTask", nil)
+	assert.Equal(t, errcode.NotFound.Code(), result.Code)
+	result = doRequest(t, r, http.MethodPost, "/admin/gocron/tasks/ginTask/trigger", nil)
+	assert.Equal(t, errcode.NotFound.Code(), result.Code)
+}
@@ -0,0 +1,113 @@
+package gocron
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// TaskInfo is the information of a running task.
+type TaskInfo struct {
+	Name          string         `json:"name"`
+	TimeSpec      string         `json:"timeSpec"`
+	IsSingleton   bool           `json:"isSingleton"`
+	Paused        bool           `json:"paused"`
+	PrevRun       time.Time      `json:"prevRun"`       // zero if the task has not run on the current node or is paused
+	NextRun       time.Time      `json:"nextRun"`       // zero if the task is paused
+	LastExecution *ExecutionInfo `json:"lastExecution"` // nil if no execution history
+}
+
+// ExecutionInfo is the execution record with duration.
+type ExecutionInfo struct {
+	*Execution
+	Duration string `json:"duration"`
+}
+
+func newExecutionInfo(e *Execution) *ExecutionInfo {
+	return &ExecutionInfo{Execution: e, Duration: e.Duration().String()}
+}
+
+// ListTasks returns the information of the tasks, including the paused tasks, sorted by name.
+func ListTasks(ctx context.Context) []*TaskInfo {
+	var infos []*TaskInfo
+	manageMu.Lock()
+	nameTask.Range(func(key, value interface{}) bool {
+		task := value.(*managedTask)
+		info := &TaskInfo{
+			Name:        task.Name,
+			TimeSpec:    task.TimeSpec,
+			IsSingleton: task.IsSingleton,
+			Paused:      task.paused,
+		}
+		if id, ok := nameID.Load(task.Name); ok && c != nil {
+			entry := c.Entry(id.(cron.EntryID))
+			info.PrevRun, info.NextRun = entry.Prev, entry.Next
+		}
+		infos = append(infos, info)
+		return true
+	})
+	manageMu.Unlock()
+
+	for _, info := range infos {
+		if records, err := GetHistory(ctx, info.Name, 1); err == nil && len(records) > 0 {
+			info.LastExecution = newExecutionInfo(records[0])
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// GetHistory returns the last executions of the task, the newest first, limit <= 0 means all.
+func GetHistory(ctx context.Context, taskName string, limit int) ([]*Execution, error) {
+	if gOpts.history == nil {
+		return []*Execution{}, nil
+	}
+	return gOpts.history.List(ctx, taskName, limit)
+}
+
+// Handler returns a http handler to view the tasks and execution history in json format.
+//
+//	GET {path}                        list the running tasks, last and next run times, durations and errors
+//	GET {path}?task=name&limit=20     list the execution history of the task, the newest first
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		taskName := r.URL.Query().Get("task")
+		if taskName == "" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": ListTasks(r.Context())})
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 20
+		}
+		records, err := GetHistory(r.Context(), taskName, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		executions := make([]*ExecutionInfo, 0, len(records))
+		for _, record := range records {
+			executions = append(executions, newExecutionInfo(record))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"task": taskName, "executions": executions})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,78 @@
+package gocron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHandler(t *testing.T) {
+	err := Init(WithLog(zap.NewNop()), WithNodeID("node-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	err = Run(&Task{
+		Name:     "handlerTask",
+		TimeSpec: "@every 1s",
+		FnWithContext: func(ctx context.Context) error {
+			return errors.New("mock error")
+		},
+	})
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond * 1200)
+	defer DeleteTask("handlerTask")
+
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	// list tasks
+	resp, err := http.Get(ts.URL)
+	assert.NoError(t, err)
+	tasks := struct {
+		Tasks []*TaskInfo `json:"tasks"`
+	}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+	_ = resp.Body.Close()
+	var info *TaskInfo
+	for _, task := range tasks.Tasks {
+		if task.Name == "handlerTask" {
+			info = task
+		}
+	}
+	if assert.NotNil(t, info) {
+		assert.Equal(t, "@every 1s", info.TimeSpec)
+		assert.False(t, info.PrevRun.IsZero())
+		assert.True(t, info.NextRun.After(info.PrevRun))
+		if assert.NotNil(t, info.LastExecution) {
+			assert.Equal(t, "mock error", info.LastExecution.Error)
+			assert.Equal(t, "node-1", info.LastExecution.NodeID)
+			assert.NotEmpty(t, info.LastExecution.Duration)
+		}
+	}
+
+	// execution history of the task
+	resp, err = http.Get(ts.URL + "?task=handlerTask&limit=1")
+	assert.NoError(t, err)
+	history := struct {
+		Task       string           `json:"task"`
+		Executions []*ExecutionInfo `json:"executions"`
+	}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&history))
+	_ = resp.Body.Close()
+	assert.Equal(t, "handlerTask", history.Task)
+	assert.Len(t, history.Executions, 1)
+
+	resp, err = http.Post(ts.URL, "application/json", nil)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
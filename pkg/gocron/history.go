@@ -0,0 +1,80 @@
+package gocron
+
+import (
+	"context"
+	"sync"
+)
+
+// HistoryStore is the store of execution history.
+type HistoryStore interface {
+	// Save the execution record.
+	Save(ctx context.Context, e *Execution) error
+	// List the last executions of the task, the newest first, limit <= 0 means all.
+	List(ctx context.Context, taskName string, limit int) ([]*Execution, error)
+}
+
+// MemoryHistory is an in-memory history store, it keeps the last executions of each task in a ring buffer.
+type MemoryHistory struct {
+	mu    sync.RWMutex
+	size  int
+	rings map[string]*ring
+}
+
+type ring struct {
+	records []*Execution
+	next    int // index of the next record
+	full    bool
+}
+
+// NewMemoryHistory creates an in-memory history store, size is the max number of records of each task.
+func NewMemoryHistory(size int) *MemoryHistory {
+	if size < 1 {
+		size = 100
+	}
+	return &MemoryHistory{
+		size:  size,
+		rings: make(map[string]*ring),
+	}
+}
+
+// Save the execution record, the oldest record of the task is overwritten if the ring buffer is full.
+func (h *MemoryHistory) Save(_ context.Context, e *Execution) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[e.TaskName]
+	if !ok {
+		r = &ring{records: make([]*Execution, h.size)}
+		h.rings[e.TaskName] = r
+	}
+	r.records[r.next] = e
+	r.next = (r.next + 1) % h.size
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// List the last executions of the task, the newest first, limit <= 0 means all.
+func (h *MemoryHistory) List(_ context.Context, taskName string, limit int) ([]*Execution, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.rings[taskName]
+	if !ok {
+		return []*Execution{}, nil
+	}
+	count := r.next
+	if r.full {
+		count = h.size
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	records := make([]*Execution, 0, count)
+	for i := 1; i <= count; i++ {
+		records = append(records, r.records[(r.next-i+h.size)%h.size])
+	}
+	return records, nil
+}
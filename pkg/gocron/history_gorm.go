@@ -0,0 +1,79 @@
+package gocron
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExecutionRecord is the table of execution history saved by GormHistory.
+type ExecutionRecord struct {
+	ID        uint64    `gorm:"column:id;AUTO_INCREMENT;primary_key" json:"id"`
+	TaskName  string    `gorm:"column:task_name;type:varchar(128);index:idx_task_start" json:"taskName"`
+	NodeID    string    `gorm:"column:node_id;type:varchar(128)" json:"nodeID"`
+	StartTime time.Time `gorm:"column:start_time;index:idx_task_start" json:"startTime"`
+	EndTime   time.Time `gorm:"column:end_time" json:"endTime"`
+	Attempts  int       `gorm:"column:attempts" json:"attempts"`
+	Error     string    `gorm:"column:error;type:text" json:"error"`
+}
+
+// TableName table name
+func (r *ExecutionRecord) TableName() string {
+	return "gocron_execution"
+}
+
+// GormHistory is a history store backed by database, the executions of all the nodes are saved in the same table.
+type GormHistory struct {
+	db *gorm.DB
+}
+
+// NewGormHistory creates a history store backed by database, the table is created if it does not exist.
+func NewGormHistory(db *gorm.DB) (*GormHistory, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	if err := db.AutoMigrate(&ExecutionRecord{}); err != nil {
+		return nil, err
+	}
+	return &GormHistory{db: db}, nil
+}
+
+// Save the execution record.
+func (h *GormHistory) Save(ctx context.Context, e *Execution) error {
+	record := &ExecutionRecord{
+		TaskName:  e.TaskName,
+		NodeID:    e.NodeID,
+		StartTime: e.StartTime,
+		EndTime:   e.EndTime,
+		Attempts:  e.Attempts,
+		Error:     e.Error,
+	}
+	return h.db.WithContext(ctx).Create(record).Error
+}
+
+// List the last executions of the task, the newest first, limit <= 0 means all.
+func (h *GormHistory) List(ctx context.Context, taskName string, limit int) ([]*Execution, error) {
+	var records []*ExecutionRecord
+	db := h.db.WithContext(ctx).Where("task_name = ?", taskName).Order("start_time DESC, id DESC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	executions := make([]*Execution, 0, len(records))
+	for _, r := range records {
+		executions = append(executions, &Execution{
+			TaskName:  r.TaskName,
+			NodeID:    r.NodeID,
+			StartTime: r.StartTime,
+			EndTime:   r.EndTime,
+			Attempts:  r.Attempts,
+			Error:     r.Error,
+		})
+	}
+	return executions, nil
+}
@@ -0,0 +1,81 @@
+package gocron
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-dev-frame/sponge/pkg/sgorm/sqlite"
+)
+
+func TestMemoryHistory(t *testing.T) {
+	h := NewMemoryHistory(3)
+	ctx := context.Background()
+
+	records, err := h.List(ctx, "task", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	for i := 1; i <= 5; i++ {
+		err = h.Save(ctx, &Execution{TaskName: "task", Attempts: i})
+		assert.NoError(t, err)
+	}
+	_ = h.Save(ctx, &Execution{TaskName: "other", Attempts: 1})
+
+	records, err = h.List(ctx, "task", 0)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, 5, records[0].Attempts)
+	assert.Equal(t, 3, records[2].Attempts)
+
+	records, _ = h.List(ctx, "task", 2)
+	assert.Len(t, records, 2)
+	assert.Equal(t, 4, records[1].Attempts)
+
+	records, _ = h.List(ctx, "other", 10)
+	assert.Len(t, records, 1)
+}
+
+func TestGormHistory(t *testing.T) {
+	_, err := NewGormHistory(nil)
+	assert.Error(t, err)
+
+	db, err := sqlite.Init(filepath.Join(t.TempDir(), "gocron.db"))
+	if err != nil {
+		t.Logf("connect to sqlite failed, err=%v", err)
+		return
+	}
+	defer sqlite.Close(db)
+
+	h, err := NewGormHistory(db)
+	if err != nil {
+		t.Logf("create table failed, err=%v", err)
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		err = h.Save(ctx, &Execution{
+			TaskName:  "task",
+			NodeID:    "node-1",
+			StartTime: now.Add(time.Duration(i) * time.Second),
+			EndTime:   now.Add(time.Duration(i)*time.Second + time.Millisecond*100),
+			Attempts:  i,
+		})
+		assert.NoError(t, err)
+	}
+
+	records, err := h.List(ctx, "task", 2)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, 3, records[0].Attempts)
+	assert.Equal(t, "node-1", records[0].NodeID)
+	assert.Equal(t, time.Millisecond*100, records[0].Duration())
+
+	records, err = h.List(ctx, "task", 0)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+}
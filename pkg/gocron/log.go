@@ -1,8 +1,12 @@
 package gocron
 
 import (
+	"time"
+
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/dlock"
 )
 
 var (
@@ -17,6 +21,12 @@ type options struct {
 	isOnlyPrintError bool // default false
 
 	granularity int // 0: second, 1: minute
+
+	nodeID        string
+	newLocker     func(taskName string) (dlock.Locker, error)
+	renewInterval time.Duration
+	executionHook func(e *Execution)
+	history       HistoryStore
 }
 
 func defaultOptions() *options {
@@ -25,6 +35,10 @@ func defaultOptions() *options {
 		isOnlyPrintError: false,
 
 		granularity: SecondType,
+
+		nodeID:        defaultNodeID(),
+		renewInterval: time.Second * 3,
+		history:       NewMemoryHistory(100),
 	}
 }
 
@@ -0,0 +1,175 @@
+package gocron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrTaskNotFound the task is not found.
+var ErrTaskNotFound = errors.New("task not found")
+
+// protects the paused state and time spec of the managed tasks
+var manageMu = sync.Mutex{}
+
+// managedTask is the task with its job, the job is kept when the task is paused,
+// so it can be resumed or triggered without creating the distributed locker again.
+type managedTask struct {
+	*Task
+	job    cron.Job
+	ctx    *taskContext
+	paused bool
+}
+
+// taskContext is the parent context of the executions of a task, it is canceled to interrupt
+// the executions in progress when the task is paused, deleted or stopped.
+type taskContext struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newTaskContext() *taskContext {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &taskContext{ctx: ctx, cancel: cancel}
+}
+
+// get returns the parent context of a new execution.
+func (c *taskContext) get() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// interrupt cancels the executions in progress, the later executions are not affected.
+func (c *taskContext) interrupt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel()
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+}
+
+func loadTask(name string) (*managedTask, error) {
+	if c == nil {
+		return nil, errors.New("cron is not initialized")
+	}
+	v, ok := nameTask.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+	}
+	return v.(*managedTask), nil
+}
+
+func parseSpec(spec string) (cron.Schedule, error) {
+	if gOpts.granularity == SecondType {
+		return cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor).Parse(spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// removeEntry removes the task from the scheduler, the task is still managed.
+func removeEntry(name string) {
+	if id, ok := nameID.LoadAndDelete(name); ok {
+		entryID := id.(cron.EntryID)
+		c.Remove(entryID)
+		idName.Delete(entryID)
+	}
+}
+
+func addEntry(name string, schedule cron.Schedule, job cron.Job) {
+	id := c.Schedule(schedule, job)
+	idName.Store(id, name)
+	nameID.Store(name, id)
+}
+
+// IsPausedTask determine if the task is paused
+func IsPausedTask(name string) bool {
+	manageMu.Lock()
+	defer manageMu.Unlock()
+	t, err := loadTask(name)
+	return err == nil && t.paused
+}
+
+// PauseTask pause the specified task, the task is not scheduled until it is resumed, the jitter
+// and retry waits of the execution in progress are interrupted, and the context of FnWithContext is canceled.
+func PauseTask(name string) error {
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
+	t, err := loadTask(name)
+	if err != nil {
+		return err
+	}
+	if t.paused {
+		return nil
+	}
+	removeEntry(name)
+	t.ctx.interrupt()
+	releaseLocker(name) // let the other nodes run the singleton task
+	t.paused = true
+	cronLog.Info("pause", "task", name)
+	return nil
+}
+
+// ResumeTask resume the paused task, the next run is calculated from now.
+func ResumeTask(name string) error {
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
+	t, err := loadTask(name)
+	if err != nil {
+		return err
+	}
+	if !t.paused {
+		return nil
+	}
+	schedule, err := parseSpec(t.TimeSpec)
+	if err != nil {
+		return fmt.Errorf("resume task '%s' error: %v", name, err)
+	}
+	addEntry(name, schedule, t.job)
+	t.paused = false
+	cronLog.Info("resume", "task", name)
+	return nil
+}
+
+// RescheduleTask change the time spec of the specified task, the paused task
+// remains paused and uses the new time spec after it is resumed.
+func RescheduleTask(name string, timeSpec string) error {
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
+	t, err := loadTask(name)
+	if err != nil {
+		return err
+	}
+	schedule, err := parseSpec(timeSpec)
+	if err != nil {
+		return fmt.Errorf("reschedule task '%s' error: %v", name, err)
+	}
+	if !t.paused {
+		removeEntry(name)
+		addEntry(name, schedule, t.job)
+	}
+	t.TimeSpec = timeSpec
+	cronLog.Info("reschedule", "task", name, "timeSpec", timeSpec)
+	return nil
+}
+
+// TriggerTask run the specified task immediately in background, the paused task can also be triggered,
+// the schedule of the task is not changed.
+func TriggerTask(name string) error {
+	manageMu.Lock()
+	t, err := loadTask(name)
+	manageMu.Unlock()
+	if err != nil {
+		return err
+	}
+	cronLog.Info("trigger", "task", name)
+	// the job is not wrapped by the chain of the scheduler, recover the panic as the scheduled runs do
+	go cron.NewChain(cron.Recover(cronLog)).Then(t.job).Run()
+	return nil
+}
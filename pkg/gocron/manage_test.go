@@ -0,0 +1,149 @@
+package gocron
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestManageTask(t *testing.T) {
+	err := Init(WithLog(zap.NewNop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	var count int32
+	err = Run(&Task{
+		Name:     "manageTask",
+		TimeSpec: "@every 1h",
+		Fn: func() {
+			atomic.AddInt32(&count, 1)
+		},
+	})
+	assert.NoError(t, err)
+	defer DeleteTask("manageTask")
+
+	countReaches := func(n int32) func() bool {
+		return func() bool { return atomic.LoadInt32(&count) >= n }
+	}
+
+	// trigger now
+	assert.NoError(t, TriggerTask("manageTask"))
+	assert.Eventually(t, countReaches(1), time.Second, time.Millisecond*10)
+
+	// reschedule
+	assert.Error(t, RescheduleTask("manageTask", "invalid spec"))
+	assert.NoError(t, RescheduleTask("manageTask", "@every 1s"))
+	info := getTaskInfo(t, "manageTask")
+	assert.Equal(t, "@every 1s", info.TimeSpec)
+	assert.WithinDuration(t, time.Now().Add(time.Second), info.NextRun, time.Second)
+	assert.Eventually(t, countReaches(2), time.Second*3, time.Millisecond*10)
+
+	// pause, the paused task can't be run again and can be triggered
+	assert.NoError(t, PauseTask("manageTask"))
+	assert.NoError(t, PauseTask("manageTask"))
+	assert.True(t, IsPausedTask("manageTask"))
+	assert.False(t, IsRunningTask("manageTask"))
+	assert.Error(t, Run(&Task{Name: "manageTask", TimeSpec: "@every 1s", Fn: func() {}}))
+	info = getTaskInfo(t, "manageTask")
+	assert.True(t, info.Paused)
+	assert.True(t, info.NextRun.IsZero())
+	paused := atomic.LoadInt32(&count)
+	time.Sleep(time.Millisecond * 1200)
+	assert.Equal(t, paused, atomic.LoadInt32(&count))
+	assert.NoError(t, TriggerTask("manageTask"))
+	assert.Eventually(t, countReaches(paused+1), time.Second, time.Millisecond*10)
+	assert.NoError(t, RescheduleTask("manageTask", "@every 2s"))
+	assert.True(t, IsPausedTask("manageTask"))
+
+	// resume
+	assert.NoError(t, ResumeTask("manageTask"))
+	assert.NoError(t, ResumeTask("manageTask"))
+	assert.False(t, IsPausedTask("manageTask"))
+	assert.True(t, IsRunningTask("manageTask"))
+	info = getTaskInfo(t, "manageTask")
+	assert.False(t, info.NextRun.IsZero())
+	assert.Eventually(t, countReaches(paused+2), time.Second*4, time.Millisecond*10)
+
+	// delete the paused task
+	assert.NoError(t, PauseTask("manageTask"))
+	DeleteTask("manageTask")
+	assert.Nil(t, getTaskInfo(t, "manageTask"))
+
+	// not found
+	for _, fn := range []func(string) error{PauseTask, ResumeTask, TriggerTask} {
+		assert.True(t, errors.Is(fn("notFound"), ErrTaskNotFound))
+	}
+	assert.True(t, errors.Is(RescheduleTask("notFound", "@every 1s"), ErrTaskNotFound))
+}
+
+func TestPauseTask_Interrupt(t *testing.T) {
+	executions := make(chan *Execution, 1)
+	err := Init(WithLog(zap.NewNop()), WithExecutionHook(func(e *Execution) { executions <- e }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	// the timeout is not supported by Fn
+	err = Run(&Task{Name: "fnTimeoutTask", TimeSpec: "@every 1h", Fn: func() {}, Timeout: time.Second})
+	assert.Error(t, err)
+
+	err = Run(&Task{
+		Name:          "interruptTask",
+		TimeSpec:      "@every 1h",
+		Retries:       3,
+		RetryInterval: time.Hour,
+		FnWithContext: func(ctx context.Context) error {
+			return errors.New("mock error")
+		},
+	})
+	assert.NoError(t, err)
+	defer DeleteTask("interruptTask")
+
+	assert.NoError(t, TriggerTask("interruptTask"))
+	time.Sleep(time.Millisecond * 100)
+	assert.NoError(t, PauseTask("interruptTask"))
+	select {
+	case e := <-executions:
+		assert.Equal(t, 1, e.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("the retry wait is not interrupted")
+	}
+}
+
+func TestTriggerTask_Recover(t *testing.T) {
+	var count int32
+	err := Init(WithLog(zap.NewNop()), WithExecutionHook(func(e *Execution) {
+		atomic.AddInt32(&count, 1)
+		panic("mock hook panic")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Stop()
+
+	err = Run(&Task{Name: "panicTriggerTask", TimeSpec: "@every 1h", Fn: func() {}})
+	assert.NoError(t, err)
+	defer DeleteTask("panicTriggerTask")
+
+	// the process is not crashed by the panic of the triggered task
+	assert.NoError(t, TriggerTask("panicTriggerTask"))
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
+
+func getTaskInfo(t *testing.T, name string) *TaskInfo {
+	for _, info := range ListTasks(context.Background()) {
+		if info.Name == name {
+			return info
+		}
+	}
+	return nil
+}
@@ -26,6 +26,10 @@ type circuitBreakerOptions struct {
 
 	// degrade handler for unary server
 	unaryServerDegradeHandler func(ctx context.Context, req interface{}) (reply interface{}, err error)
+
+	// fallback functions, key is full method, "" is for all methods without their own fallback
+	unaryClientFallbacks map[string]UnaryClientFallback
+	unaryServerFallbacks map[string]UnaryServerFallback
 }
 
 func defaultCircuitBreakerOptions() *circuitBreakerOptions {
@@ -56,7 +60,8 @@ func WithGroup(g *group.Group) CircuitBreakerOption {
 	}
 }
 
-// WithBreakerOption set the circuit breaker options.
+// WithBreakerOption set the circuit breaker options, the SRE breaker is used by default,
+// add circuitbreaker.WithStateMachine() to use the state machine breaker.
 func WithBreakerOption(opts ...circuitbreaker.Option) CircuitBreakerOption {
 	return func(o *circuitBreakerOptions) {
 		if len(opts) > 0 {
@@ -83,30 +88,94 @@ func WithUnaryServerDegradeHandler(handler func(ctx context.Context, req interfa
 	}
 }
 
+// UnaryClientFallback is the fallback function of a client method, it is called when the breaker
+// rejects the call or the call fails with the valid code, err is the rejected or failed error.
+// The fallback can fill the reply (e.g. cached response or default value) and return nil,
+// or return a degraded error, e.g. errcode.StatusServiceUnavailable.ToRPCErr().
+type UnaryClientFallback func(ctx context.Context, method string, req, reply interface{}, err error) error
+
+// UnaryServerFallback is the fallback function of a server method, it is called when the breaker
+// rejects the request or the handler fails with the valid code, err is the rejected or failed error.
+type UnaryServerFallback func(ctx context.Context, req interface{}, err error) (reply interface{}, _ error)
+
+// WithUnaryClientFallback set the fallback function of the client method, fullMethod is e.g.
+// "/api.user.v1.User/GetByID", if fullMethod is empty, the fallback is used for all the methods
+// without their own fallback.
+func WithUnaryClientFallback(fullMethod string, fn UnaryClientFallback) CircuitBreakerOption {
+	return func(o *circuitBreakerOptions) {
+		if fn == nil {
+			return
+		}
+		if o.unaryClientFallbacks == nil {
+			o.unaryClientFallbacks = make(map[string]UnaryClientFallback)
+		}
+		o.unaryClientFallbacks[fullMethod] = fn
+	}
+}
+
+// WithUnaryServerFallback set the fallback function of the server method, fullMethod is e.g.
+// "/api.user.v1.User/GetByID", if fullMethod is empty, the fallback is used for all the methods
+// without their own fallback, it takes precedence over WithUnaryServerDegradeHandler.
+func WithUnaryServerFallback(fullMethod string, fn UnaryServerFallback) CircuitBreakerOption {
+	return func(o *circuitBreakerOptions) {
+		if fn == nil {
+			return
+		}
+		if o.unaryServerFallbacks == nil {
+			o.unaryServerFallbacks = make(map[string]UnaryServerFallback)
+		}
+		o.unaryServerFallbacks[fullMethod] = fn
+	}
+}
+
+func (o *circuitBreakerOptions) getUnaryClientFallback(method string) UnaryClientFallback {
+	if fn, ok := o.unaryClientFallbacks[method]; ok {
+		return fn
+	}
+	return o.unaryClientFallbacks[""]
+}
+
+func (o *circuitBreakerOptions) getUnaryServerFallback(method string) UnaryServerFallback {
+	if fn, ok := o.unaryServerFallbacks[method]; ok {
+		return fn
+	}
+	return o.unaryServerFallbacks[""]
+}
+
+// isFailure reports whether the error is a failure of the breaker.
+func (o *circuitBreakerOptions) isFailure(err error) bool {
+	s, ok := status.FromError(err)
+	_, isHit := o.validCodes[s.Code()]
+	return ok && isHit
+}
+
 // UnaryClientCircuitBreaker client-side unary circuit breaker interceptor
 func UnaryClientCircuitBreaker(opts ...CircuitBreakerOption) grpc.UnaryClientInterceptor {
 	o := defaultCircuitBreakerOptions()
 	o.apply(opts...)
 
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		fallback := o.getUnaryClientFallback(method)
 		breaker := o.group.Get(method).(circuitbreaker.CircuitBreaker)
-		if err := breaker.Allow(); err != nil {
-			// NOTE: when client reject request locally, keep adding counter let the drop ratio higher.
-			breaker.MarkFailed()
-			return errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
+		done, err := circuitbreaker.Acquire(breaker)
+		if err != nil {
+			rpcErr := errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
+			if fallback != nil {
+				return fallback(ctx, method, req, reply, rpcErr)
+			}
+			return rpcErr
 		}
 
-		err := invoker(ctx, method, req, reply, cc, opts...)
-		if err != nil {
-			// NOTE: need to check internal and service unavailable error
-			s, ok := status.FromError(err)
-			_, isHit := o.validCodes[s.Code()]
-			if ok && isHit {
-				breaker.MarkFailed()
-			} else {
-				breaker.MarkSuccess()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		// NOTE: need to check internal and service unavailable error
+		if o.isFailure(err) {
+			done(false)
+			if fallback != nil {
+				return fallback(ctx, method, req, reply, err)
 			}
+			return err
 		}
+		done(true)
 
 		return err
 	}
@@ -119,23 +188,14 @@ func StreamClientCircuitBreaker(opts ...CircuitBreakerOption) grpc.StreamClientI
 
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 		breaker := o.group.Get(method).(circuitbreaker.CircuitBreaker)
-		if err := breaker.Allow(); err != nil {
-			// NOTE: when client reject request locally, keep adding counter let the drop ratio higher.
-			breaker.MarkFailed()
+		done, err := circuitbreaker.Acquire(breaker)
+		if err != nil {
 			return nil, errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
 		}
 
 		clientStream, err := streamer(ctx, desc, cc, method, opts...)
-		if err != nil {
-			// NOTE: need to check internal and service unavailable error
-			s, ok := status.FromError(err)
-			_, isHit := o.validCodes[s.Code()]
-			if ok && isHit {
-				breaker.MarkFailed()
-			} else {
-				breaker.MarkSuccess()
-			}
-		}
+		// NOTE: need to check internal and service unavailable error
+		done(!o.isFailure(err))
 
 		return clientStream, err
 	}
@@ -147,28 +207,30 @@ func UnaryServerCircuitBreaker(opts ...CircuitBreakerOption) grpc.UnaryServerInt
 	o.apply(opts...)
 
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		fallback := o.getUnaryServerFallback(info.FullMethod)
 		breaker := o.group.Get(info.FullMethod).(circuitbreaker.CircuitBreaker)
-		if err := breaker.Allow(); err != nil {
-			// NOTE: when client reject request locally, keep adding let the drop ratio higher.
-			breaker.MarkFailed()
-
+		done, err := circuitbreaker.Acquire(breaker)
+		if err != nil {
+			rpcErr := errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
+			if fallback != nil {
+				return fallback(ctx, req, rpcErr)
+			}
 			if o.unaryServerDegradeHandler != nil {
 				return o.unaryServerDegradeHandler(ctx, req)
 			}
-			return nil, errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
+			return nil, rpcErr
 		}
 
 		reply, err := handler(ctx, req)
-		if err != nil {
-			// NOTE: need to check internal and service unavailable error
-			s, ok := status.FromError(err)
-			_, isHit := o.validCodes[s.Code()]
-			if ok && isHit {
-				breaker.MarkFailed()
-			} else {
-				breaker.MarkSuccess()
+		// NOTE: need to check internal and service unavailable error
+		if o.isFailure(err) {
+			done(false)
+			if fallback != nil {
+				return fallback(ctx, req, err)
 			}
+			return reply, err
 		}
+		done(true)
 
 		return reply, err
 	}
@@ -181,23 +243,14 @@ func StreamServerCircuitBreaker(opts ...CircuitBreakerOption) grpc.StreamServerI
 
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		breaker := o.group.Get(info.FullMethod).(circuitbreaker.CircuitBreaker)
-		if err := breaker.Allow(); err != nil {
-			// NOTE: when client reject request locally, keep adding counter let the drop ratio higher.
-			breaker.MarkFailed()
+		done, err := circuitbreaker.Acquire(breaker)
+		if err != nil {
 			return errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
 		}
 
-		err := handler(srv, ss)
-		if err != nil {
-			// NOTE: need to check internal and service unavailable error
-			s, ok := status.FromError(err)
-			_, isHit := o.validCodes[s.Code()]
-			if ok && isHit {
-				breaker.MarkFailed()
-			} else {
-				breaker.MarkSuccess()
-			}
-		}
+		err = handler(srv, ss)
+		// NOTE: need to check internal and service unavailable error
+		done(!o.isFailure(err))
 
 		return err
 	}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 
 	"github.com/go-dev-frame/sponge/pkg/container/group"
 	"github.com/go-dev-frame/sponge/pkg/errcode"
@@ -45,6 +46,29 @@ func TestUnaryClientCircuitBreaker(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestUnaryClientCircuitBreaker_StateMachine(t *testing.T) {
+	interceptor := UnaryClientCircuitBreaker(
+		WithBreakerOption(
+			circuitbreaker.WithStateMachine(),
+			circuitbreaker.WithRequest(10),
+			circuitbreaker.WithOpenTimeout(time.Hour),
+		),
+	)
+
+	var calls int
+	ivoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return errcode.StatusInternalServerError.ToRPCErr()
+	}
+	for i := 0; i < 10; i++ {
+		err := interceptor(context.Background(), "/test", nil, nil, nil, ivoker)
+		assert.Error(t, err)
+	}
+	err := interceptor(context.Background(), "/test", nil, nil, nil, ivoker)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 10, calls)
+}
+
 func TestSteamClientCircuitBreaker(t *testing.T) {
 	interceptor := StreamClientCircuitBreaker()
 	assert.NotNil(t, interceptor)
@@ -120,3 +144,88 @@ func TestSteamServerCircuitBreaker(t *testing.T) {
 	err := interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: "/test"}, handler)
 	assert.Error(t, err)
 }
+
+type rejectBreaker struct{}
+
+func (rejectBreaker) Allow() error { return circuitbreaker.ErrNotAllowed }
+func (rejectBreaker) MarkSuccess() {}
+func (rejectBreaker) MarkFailed()  {}
+
+func TestUnaryClientCircuitBreakerFallback(t *testing.T) {
+	fallback := func(ctx context.Context, method string, req, reply interface{}, err error) error {
+		*(reply.(*string)) = "cached " + method
+		return nil
+	}
+	defaultFallback := func(ctx context.Context, method string, req, reply interface{}, err error) error {
+		return errcode.StatusServiceUnavailable.ToRPCErr("degraded")
+	}
+	interceptor := UnaryClientCircuitBreaker(
+		WithUnaryClientFallback("/api.v1.User/GetByID", fallback),
+		WithUnaryClientFallback("", defaultFallback),
+		WithUnaryClientFallback("/api.v1.User/List", nil),
+	)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errcode.StatusInternalServerError.ToRPCErr()
+	}
+	reply := ""
+	err := interceptor(context.Background(), "/api.v1.User/GetByID", nil, &reply, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached /api.v1.User/GetByID", reply)
+
+	err = interceptor(context.Background(), "/api.v1.User/List", nil, &reply, nil, invoker)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// not the valid code, no fallback
+	invoker = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errcode.StatusInvalidParams.ToRPCErr()
+	}
+	err = interceptor(context.Background(), "/api.v1.User/GetByID", nil, &reply, nil, invoker)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// rejected by breaker
+	interceptor = UnaryClientCircuitBreaker(
+		WithGroup(group.NewGroup(func() interface{} { return rejectBreaker{} })),
+		WithUnaryClientFallback("", func(ctx context.Context, method string, req, reply interface{}, err error) error {
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+			*(reply.(*string)) = "default"
+			return nil
+		}),
+	)
+	err = interceptor(context.Background(), "/api.v1.User/GetByID", nil, &reply, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", reply)
+}
+
+func TestUnaryServerCircuitBreakerFallback(t *testing.T) {
+	degradeHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "degrade", nil
+	}
+	fallback := func(ctx context.Context, req interface{}, err error) (interface{}, error) {
+		return "fallback", nil
+	}
+	interceptor := UnaryServerCircuitBreaker(
+		WithGroup(group.NewGroup(func() interface{} { return rejectBreaker{} })),
+		WithUnaryServerDegradeHandler(degradeHandler),
+		WithUnaryServerFallback("/api.v1.User/GetByID", fallback),
+	)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	reply, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/GetByID"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", reply)
+	reply, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/List"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "degrade", reply)
+
+	// failed by handler
+	interceptor = UnaryServerCircuitBreaker(WithUnaryServerFallback("", fallback))
+	handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errcode.StatusServiceUnavailable.ToRPCErr()
+	}
+	reply, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/List"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", reply)
+}
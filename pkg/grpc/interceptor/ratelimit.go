@@ -2,9 +2,14 @@ package interceptor
 
 import (
 	"context"
+	"net"
 	"time"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
 	"github.com/go-dev-frame/sponge/pkg/errcode"
 	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
@@ -24,6 +29,15 @@ type ratelimitOptions struct {
 	bucket       int
 	cpuThreshold int64
 	cpuQuota     float64
+
+	limiter       rl.Limiter
+	keyLimiter    rl.KeyLimiter
+	keyFn         func(ctx context.Context, fullMethod string) string
+	keyFailClosed bool
+	keyLimiterLog *zap.Logger
+
+	methodPriorities map[string]rl.Priority
+	priorityKey      string
 }
 
 func defaultRatelimitOptions() *ratelimitOptions {
@@ -40,6 +54,19 @@ func (o *ratelimitOptions) apply(opts ...RatelimitOption) {
 	}
 }
 
+// getLimiter returns the limiter set by WithLimiter, or a new adaptive rate limiter based on CPU usage.
+func (o *ratelimitOptions) getLimiter() rl.Limiter {
+	if o.limiter != nil {
+		return o.limiter
+	}
+	return rl.NewLimiter(
+		rl.WithWindow(o.window),
+		rl.WithBucket(o.bucket),
+		rl.WithCPUThreshold(o.cpuThreshold),
+		rl.WithCPUQuota(o.cpuQuota),
+	)
+}
+
 // WithWindow with window size.
 func WithWindow(d time.Duration) RatelimitOption {
 	return func(o *ratelimitOptions) {
@@ -68,16 +95,164 @@ func WithCPUQuota(quota float64) RatelimitOption {
 	}
 }
 
+// WithLimiter use the limiter instead of the adaptive rate limiter based on CPU usage, e.g. the adaptive
+// concurrency limiter rl.NewVegasLimiter or rl.NewGradientLimiter which is suitable for IO-bound services.
+func WithLimiter(limiter rl.Limiter) RatelimitOption {
+	return func(o *ratelimitOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithMethodPriority set the priority of the methods, e.g. "/api.user.v1.User/Login", when overloaded,
+// the requests of lower priority are shed first, the priority of method takes precedence over the
+// priority in the metadata.
+func WithMethodPriority(priority rl.Priority, fullMethods ...string) RatelimitOption {
+	return func(o *ratelimitOptions) {
+		if o.methodPriorities == nil {
+			o.methodPriorities = make(map[string]rl.Priority)
+		}
+		for _, fullMethod := range fullMethods {
+			o.methodPriorities[fullMethod] = priority
+		}
+	}
+}
+
+// WithPriorityMetadata set the metadata key of priority, the value is "critical", "default" or "sheddable",
+// e.g. "x-priority", when overloaded, the requests of lower priority are shed first.
+func WithPriorityMetadata(key string) RatelimitOption {
+	return func(o *ratelimitOptions) {
+		o.priorityKey = key
+	}
+}
+
+// WithKeyLimiter use a rate limiter keyed by the request instead of the adaptive rate limiter, e.g. the
+// distributed limiter rl.NewRedisLimiter, keyFn returns the key of the request, e.g. API key in metadata,
+// default is the client IP, the request with empty key is not limited.
+func WithKeyLimiter(limiter rl.KeyLimiter, keyFn func(ctx context.Context, fullMethod string) string) RatelimitOption {
+	return func(o *ratelimitOptions) {
+		o.keyLimiter = limiter
+		o.keyFn = keyFn
+	}
+}
+
+// WithKeyLimiterFailClosed reject the requests when the key limiter is unavailable, e.g. redis is down,
+// default is fail open, the requests are allowed.
+func WithKeyLimiterFailClosed() RatelimitOption {
+	return func(o *ratelimitOptions) {
+		o.keyFailClosed = true
+	}
+}
+
+// WithKeyLimiterLog set the logger of the key limiter errors, the errors are logged at most
+// once every 10 seconds, default is zap.NewProduction.
+func WithKeyLimiterLog(log *zap.Logger) RatelimitOption {
+	return func(o *ratelimitOptions) {
+		if log != nil {
+			o.keyLimiterLog = log
+		}
+	}
+}
+
+// keyLimiterErrorLog returns the logger of the key limiter errors, the same message is logged
+// at most once every 10 seconds, so that the errors of an unavailable limiter do not flood the log.
+func (o *ratelimitOptions) keyLimiterErrorLog() *zap.Logger {
+	log := o.keyLimiterLog
+	if log == nil {
+		log, _ = zap.NewProduction()
+	}
+	return log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, 10*time.Second, 1, 0)
+	}))
+}
+
+func peerIP(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (o *ratelimitOptions) enablePriority() bool {
+	return len(o.methodPriorities) > 0 || o.priorityKey != ""
+}
+
+func (o *ratelimitOptions) getPriority(ctx context.Context, fullMethod string) rl.Priority {
+	if p, ok := o.methodPriorities[fullMethod]; ok {
+		return p
+	}
+	if o.priorityKey != "" {
+		if values := metadata.ValueFromIncomingContext(ctx, o.priorityKey); len(values) > 0 {
+			p, _ := rl.ParsePriority(values[0])
+			return p
+		}
+	}
+	return rl.PriorityDefault
+}
+
+// allowKey checks the rate limit of the request key, the rate limit headers are returned in metadata,
+// the request is allowed if the key is empty or the limiter is unavailable, unless WithKeyLimiterFailClosed is set.
+func allowKey(ctx context.Context, fullMethod string, o *ratelimitOptions, errLog *zap.Logger, setHeader func(metadata.MD) error) error {
+	keyFn := o.keyFn
+	if keyFn == nil {
+		keyFn = peerIP
+	}
+	key := keyFn(ctx, fullMethod)
+	if key == "" {
+		return nil
+	}
+	result, err := o.keyLimiter.AllowKey(ctx, key)
+	if err != nil {
+		errLog.Error("key rate limiter error", zap.Error(err), zap.String("method", fullMethod), zap.Bool("failClosed", o.keyFailClosed))
+		if o.keyFailClosed {
+			return errcode.StatusServiceUnavailable.ToRPCErr(err.Error())
+		}
+		return nil
+	}
+
+	md := metadata.MD{}
+	for k, v := range result.Headers() {
+		md.Set(k, v)
+	}
+	_ = setHeader(md)
+	if !result.Allowed {
+		return errcode.StatusLimitExceed.ToRPCErr(ErrLimitExceed.Error())
+	}
+	return nil
+}
+
 // UnaryServerRateLimit server-side unary circuit breaker interceptor
 func UnaryServerRateLimit(opts ...RatelimitOption) grpc.UnaryServerInterceptor {
 	o := defaultRatelimitOptions()
 	o.apply(opts...)
-	limiter := rl.NewLimiter(
-		rl.WithWindow(o.window),
-		rl.WithBucket(o.bucket),
-		rl.WithCPUThreshold(o.cpuThreshold),
-		rl.WithCPUQuota(o.cpuQuota),
-	)
+	if o.keyLimiter != nil {
+		errLog := o.keyLimiterErrorLog()
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			setHeader := func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }
+			if err = allowKey(ctx, info.FullMethod, o, errLog, setHeader); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+	limiter := o.getLimiter()
+	if o.enablePriority() {
+		shedder := rl.NewShedder(limiter, rl.WithShedderName("grpc"))
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			done, err := shedder.AllowPriority(o.getPriority(ctx, info.FullMethod))
+			if err != nil {
+				return nil, errcode.StatusLimitExceed.ToRPCErr(err.Error())
+			}
+
+			reply, err := handler(ctx, req)
+			done(rl.DoneInfo{Err: err})
+			return reply, err
+		}
+	}
 
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		done, err := limiter.Allow()
@@ -95,12 +270,29 @@ func UnaryServerRateLimit(opts ...RatelimitOption) grpc.UnaryServerInterceptor {
 func StreamServerRateLimit(opts ...RatelimitOption) grpc.StreamServerInterceptor {
 	o := defaultRatelimitOptions()
 	o.apply(opts...)
-	limiter := rl.NewLimiter(
-		rl.WithWindow(o.window),
-		rl.WithBucket(o.bucket),
-		rl.WithCPUThreshold(o.cpuThreshold),
-		rl.WithCPUQuota(o.cpuQuota),
-	)
+	if o.keyLimiter != nil {
+		errLog := o.keyLimiterErrorLog()
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := allowKey(ss.Context(), info.FullMethod, o, errLog, ss.SetHeader); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}
+	}
+	limiter := o.getLimiter()
+	if o.enablePriority() {
+		shedder := rl.NewShedder(limiter, rl.WithShedderName("grpc"))
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			done, err := shedder.AllowPriority(o.getPriority(ss.Context(), info.FullMethod))
+			if err != nil {
+				return errcode.StatusLimitExceed.ToRPCErr(err.Error())
+			}
+
+			err = handler(srv, ss)
+			done(rl.DoneInfo{Err: err})
+			return err
+		}
+	}
 
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		done, err := limiter.Allow()
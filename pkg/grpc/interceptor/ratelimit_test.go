@@ -2,11 +2,21 @@ package interceptor
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
 )
 
 func TestUnaryServerRateLimit(t *testing.T) {
@@ -35,3 +45,177 @@ func TestStreamServerRateLimit(t *testing.T) {
 	err := interceptor(nil, nil, nil, handler)
 	assert.NoError(t, err)
 }
+
+type headerTransportStream struct {
+	header metadata.MD
+}
+
+func (s *headerTransportStream) Method() string { return "/test" }
+
+func (s *headerTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerTransportStream) SendHeader(md metadata.MD) error { return nil }
+
+func (s *headerTransportStream) SetTrailer(md metadata.MD) error { return nil }
+
+func TestUnaryServerRateLimitWithKeyLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	interceptor := UnaryServerRateLimit(WithKeyLimiter(rl.NewRedisLimiter(client, 2, time.Minute), nil))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.user.v1.User/GetByID"}
+	newCtx := func(ip string) (context.Context, *headerTransportStream) {
+		stream := &headerTransportStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}})
+		return ctx, stream
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, stream := newCtx("10.0.0.1")
+		_, err := interceptor(ctx, nil, info, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2"}, stream.header.Get(rl.HeaderLimit))
+	}
+	ctx, stream := newCtx("10.0.0.1")
+	_, err := interceptor(ctx, nil, info, handler)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"30"}, stream.header.Get(rl.HeaderRetryAfter))
+
+	ctx, _ = newCtx("10.0.0.2")
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+
+	// no peer, not limited
+	_, err = interceptor(context.Background(), nil, info, handler)
+	assert.NoError(t, err)
+
+	// redis is unavailable, fail open
+	mr.Close()
+	ctx, _ = newCtx("10.0.0.1")
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.NoError(t, err)
+}
+
+func TestStreamServerRateLimitWithKeyLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	keyFn := func(ctx context.Context, fullMethod string) string {
+		return fullMethod
+	}
+	interceptor := StreamServerRateLimit(WithKeyLimiter(rl.NewRedisLimiter(client, 1, time.Minute), keyFn))
+	ss := streamServer{ctx: context.Background()}
+	assert.NoError(t, interceptor(nil, ss, streamServerInfo, streamServerHandler))
+	assert.Error(t, interceptor(nil, ss, streamServerInfo, streamServerHandler))
+}
+
+func TestServerRateLimitWithKeyLimiterError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	mr.Close() // redis is unavailable
+
+	keyFn := func(ctx context.Context, fullMethod string) string {
+		return fullMethod
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.user.v1.User/GetByID"}
+
+	// fail open, the errors are logged once in 10 seconds
+	core, logs := observer.New(zapcore.InfoLevel)
+	limiter := rl.NewRedisLimiter(client, 2, time.Minute)
+	interceptor := UnaryServerRateLimit(WithKeyLimiter(limiter, keyFn), WithKeyLimiterLog(zap.New(core)))
+	for i := 0; i < 3; i++ {
+		_, err := interceptor(context.Background(), nil, info, handler)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, logs.FilterMessage("key rate limiter error").Len())
+
+	// fail closed
+	interceptor = UnaryServerRateLimit(WithKeyLimiter(limiter, keyFn), WithKeyLimiterFailClosed())
+	_, err := interceptor(context.Background(), nil, info, handler)
+	assert.Error(t, err)
+	streamInterceptor := StreamServerRateLimit(WithKeyLimiter(limiter, keyFn), WithKeyLimiterFailClosed())
+	ss := streamServer{ctx: context.Background()}
+	assert.Error(t, streamInterceptor(nil, ss, streamServerInfo, streamServerHandler))
+}
+
+func TestServerRateLimitWithLimiter(t *testing.T) {
+	limiter := rl.NewVegasLimiter(rl.WithInitialLimit(1))
+	unaryInterceptor := UnaryServerRateLimit(WithLimiter(limiter))
+	streamInterceptor := StreamServerRateLimit(WithLimiter(limiter))
+
+	var nestedErr, nestedStreamErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		// the limit of in-flight requests is reached
+		_, nestedErr = unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		nestedStreamErr = streamInterceptor(nil, nil, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+		return "ok", nil
+	}
+	reply, err := unaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", reply)
+	assert.Error(t, nestedErr)
+	assert.Error(t, nestedStreamErr)
+	assert.Equal(t, int64(0), limiter.InFlight())
+}
+
+func TestServerRateLimitWithPriority(t *testing.T) {
+	limiter := rl.NewVegasLimiter(rl.WithInitialLimit(1))
+	opts := []RatelimitOption{
+		WithLimiter(limiter),
+		WithMethodPriority(rl.PriorityCritical, "/api.v1.User/Login"),
+		WithPriorityMetadata("x-priority"),
+	}
+	unaryInterceptor := UnaryServerRateLimit(opts...)
+	streamInterceptor := StreamServerRateLimit(opts...)
+	withPriority := func(priority string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-priority", priority))
+	}
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	var nestedErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		// the limit of in-flight requests is reached
+		_, nestedErr = unaryInterceptor(withPriority("default"), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/GetByID"}, unaryHandler)
+		return "ok", nil
+	}
+	_, err := unaryInterceptor(withPriority("sheddable"), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/Login"}, handler)
+	assert.NoError(t, err)
+	assert.Error(t, nestedErr)
+
+	// the default request was rejected, the sheddable requests are shed
+	_, err = unaryInterceptor(withPriority("sheddable"), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/GetByID"}, unaryHandler)
+	assert.Error(t, err)
+	_, err = unaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.v1.User/GetByID"}, unaryHandler)
+	assert.NoError(t, err)
+
+	var nestedStreamErr error
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		nestedStreamErr = streamInterceptor(nil, newStreamServer(withPriority("default")), streamServerInfo, streamServerHandler)
+		return nil
+	}
+	err = streamInterceptor(nil, newStreamServer(context.Background()), &grpc.StreamServerInfo{FullMethod: "/api.v1.User/Login"}, streamHandler)
+	assert.NoError(t, err)
+	assert.Error(t, nestedStreamErr)
+	err = streamInterceptor(nil, newStreamServer(withPriority("sheddable")), streamServerInfo, streamServerHandler)
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), limiter.InFlight())
+}
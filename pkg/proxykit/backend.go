@@ -1,6 +1,9 @@
 package proxykit
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -34,6 +37,10 @@ type Backend struct {
 	URL             *url.URL
 	isHealthy       atomic.Bool
 	activeConns     atomic.Int64
+	weight          atomic.Int64
+	latency         atomic.Uint64 // EWMA of response latency in nanoseconds, stored as float64 bits
+	ejectedUntil    atomic.Int64  // unix nano, set by outlier detection
+	outlier         outlierState
 	proxy           *httputil.ReverseProxy
 	stopHealthCheck chan struct{} // Used to stop the health check goroutine
 	stopOnce        sync.Once     // Ensures stop is called only once
@@ -66,6 +73,13 @@ func NewBackend(prefixPath string, u *url.URL) *Backend {
 		req.Header.Set("X-Forwarded-Host", req.Host)
 		req.Header.Set("X-Origin-Host", u.Host)
 	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		// the request canceled by the client or by a hedged request is not an error of the backend
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("[Proxy] backend %s error: %v", u, err)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
 
 	b := &Backend{
 		URL:             u,
@@ -74,6 +88,7 @@ func NewBackend(prefixPath string, u *url.URL) *Backend {
 	}
 
 	b.isHealthy.Store(true) // initialize as healthy by default
+	b.weight.Store(1)
 	return b
 }
 
@@ -88,8 +103,15 @@ func (b *Backend) SetHealthy(healthy bool) {
 	b.isHealthy.Store(healthy)
 }
 
+// IsHealthy reports whether the backend passes the active health check and is not ejected by outlier detection.
 func (b *Backend) IsHealthy() bool {
-	return b.isHealthy.Load()
+	return b.isHealthy.Load() && !b.IsEjected()
+}
+
+// IsEjected reports whether the backend is ejected by outlier detection.
+func (b *Backend) IsEjected() bool {
+	until := b.ejectedUntil.Load()
+	return until != 0 && time.Now().UnixNano() < until
 }
 
 func (b *Backend) GetActiveConns() int64 {
@@ -103,3 +125,40 @@ func (b *Backend) IncrementActiveConns() {
 func (b *Backend) DecrementActiveConns() {
 	b.activeConns.Add(-1)
 }
+
+// SetWeight sets the weight used by weighted balancers, values less than 1 are ignored.
+func (b *Backend) SetWeight(weight int) {
+	if weight > 0 {
+		b.weight.Store(int64(weight))
+	}
+}
+
+// GetWeight returns the weight of the backend, default is 1.
+func (b *Backend) GetWeight() int {
+	return int(b.weight.Load())
+}
+
+// latencyDecay is the smoothing factor of the latency EWMA, the larger the value,
+// the more weight is given to the latest sample.
+const latencyDecay = 0.3
+
+// ObserveLatency records a response latency sample into the EWMA of the backend.
+func (b *Backend) ObserveLatency(d time.Duration) {
+	sample := float64(d)
+	for {
+		old := b.latency.Load()
+		prev := math.Float64frombits(old)
+		next := sample
+		if old != 0 {
+			next = prev + latencyDecay*(sample-prev)
+		}
+		if b.latency.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// GetLatency returns the EWMA of response latency, zero means no sample has been recorded.
+func (b *Backend) GetLatency() time.Duration {
+	return time.Duration(math.Float64frombits(b.latency.Load()))
+}
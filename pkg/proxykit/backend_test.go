@@ -245,3 +245,28 @@ func TestBackend_StopHealthCheck(t *testing.T) {
 	// Calling it again should not panic (thanks to sync.Once)
 	b.StopHealthCheck()
 }
+
+func TestBackendWeightAndLatency(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8080")
+	b := NewBackend("/", u)
+	if b.GetWeight() != 1 {
+		t.Errorf("expected default weight 1, got %d", b.GetWeight())
+	}
+	b.SetWeight(3)
+	b.SetWeight(0) // ignored
+	if b.GetWeight() != 3 {
+		t.Errorf("expected weight 3, got %d", b.GetWeight())
+	}
+
+	if b.GetLatency() != 0 {
+		t.Errorf("expected zero latency, got %v", b.GetLatency())
+	}
+	b.ObserveLatency(100 * time.Millisecond)
+	if b.GetLatency() != 100*time.Millisecond {
+		t.Errorf("expected 100ms, got %v", b.GetLatency())
+	}
+	b.ObserveLatency(200 * time.Millisecond)
+	if b.GetLatency() != 130*time.Millisecond {
+		t.Errorf("expected 130ms, got %v", b.GetLatency())
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"hash/crc32"
 	"math"
+	"math/rand"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -203,6 +206,313 @@ func (h *IPHash) RemoveBackend(b *Backend) {
 	}
 }
 
+// --- WeightedRoundRobin ---
+
+// WeightedRoundRobin is a smooth weighted round-robin balancer (same as nginx), the backends
+// are selected in proportion to their weights, and the selections are interleaved evenly
+// instead of in bursts, e.g. weights 5:1:1 give the sequence a a b a c a a.
+type WeightedRoundRobin struct {
+	backends []*Backend
+	current  map[*Backend]int64
+	mu       sync.Mutex
+}
+
+func NewWeightedRoundRobin(backends []*Backend) *WeightedRoundRobin {
+	return &WeightedRoundRobin{
+		backends: backends,
+		current:  make(map[*Backend]int64),
+	}
+}
+
+func (w *WeightedRoundRobin) Next(_ *http.Request) (*Backend, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var best *Backend
+	var total int64
+	for _, b := range w.backends {
+		if !b.IsHealthy() {
+			continue
+		}
+		weight := int64(b.GetWeight())
+		w.current[b] += weight
+		total += weight
+		if best == nil || w.current[b] > w.current[best] {
+			best = b
+		}
+	}
+	if best == nil {
+		return nil, ErrNoHealthyBackends
+	}
+	w.current[best] -= total
+	return best, nil
+}
+
+func (w *WeightedRoundRobin) GetBackends() []*Backend {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	copied := make([]*Backend, len(w.backends))
+	copy(copied, w.backends)
+	return copied
+}
+
+func (w *WeightedRoundRobin) AddBackend(b *Backend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.backends = append(w.backends, b)
+}
+
+func (w *WeightedRoundRobin) RemoveBackend(b *Backend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, backend := range w.backends {
+		if backend.URL.String() == b.URL.String() {
+			w.backends = append(w.backends[:i], w.backends[i+1:]...)
+			delete(w.current, backend)
+			return
+		}
+	}
+}
+
+// --- ConsistentHash ---
+
+// HashKeyFunc returns the key of the request used by ConsistentHash.
+type HashKeyFunc func(r *http.Request) string
+
+// HashKeyHeader uses the value of the request header as the hash key.
+func HashKeyHeader(name string) HashKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// HashKeyCookie uses the value of the request cookie as the hash key.
+func HashKeyCookie(name string) HashKeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// HashKeyPath uses the request path as the hash key.
+func HashKeyPath() HashKeyFunc {
+	return func(r *http.Request) string {
+		return r.URL.Path
+	}
+}
+
+// ConsistentHashOption set options for ConsistentHash.
+type ConsistentHashOption func(*consistentHashOptions)
+
+type consistentHashOptions struct {
+	keyFn        HashKeyFunc
+	virtualNodes int
+}
+
+func defaultConsistentHashOptions() *consistentHashOptions {
+	return &consistentHashOptions{
+		keyFn:        getClientIP,
+		virtualNodes: 160,
+	}
+}
+
+func (o *consistentHashOptions) apply(opts ...ConsistentHashOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithHashKey sets the function to get the hash key of the request, default is the client IP,
+// if the key is empty, the client IP is used.
+func WithHashKey(fn HashKeyFunc) ConsistentHashOption {
+	return func(o *consistentHashOptions) {
+		if fn != nil {
+			o.keyFn = fn
+		}
+	}
+}
+
+// WithVirtualNodes sets the number of virtual nodes per unit of weight on the hash ring, default 160.
+func WithVirtualNodes(n int) ConsistentHashOption {
+	return func(o *consistentHashOptions) {
+		if n > 0 {
+			o.virtualNodes = n
+		}
+	}
+}
+
+type ringNode struct {
+	hash    uint32
+	backend *Backend
+}
+
+// ConsistentHash maps the requests to the backends by a hash ring with virtual nodes, the same
+// key always goes to the same backend, and adding or removing a backend only remaps the keys
+// of that backend. If the selected backend is unhealthy, the next healthy one on the ring is used.
+type ConsistentHash struct {
+	backends     []*Backend
+	ring         []ringNode
+	keyFn        HashKeyFunc
+	virtualNodes int
+	mu           sync.RWMutex
+}
+
+func NewConsistentHash(backends []*Backend, opts ...ConsistentHashOption) *ConsistentHash {
+	o := defaultConsistentHashOptions()
+	o.apply(opts...)
+	h := &ConsistentHash{
+		backends:     backends,
+		keyFn:        o.keyFn,
+		virtualNodes: o.virtualNodes,
+	}
+	h.buildRing()
+	return h
+}
+
+// buildRing must be called with the write lock held.
+func (h *ConsistentHash) buildRing() {
+	ring := make([]ringNode, 0, len(h.backends)*h.virtualNodes)
+	for _, b := range h.backends {
+		addr := b.URL.String()
+		replicas := h.virtualNodes * b.GetWeight()
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(addr + "#" + strconv.Itoa(i)))
+			ring = append(ring, ringNode{hash: hash, backend: b})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	h.ring = ring
+}
+
+func (h *ConsistentHash) Next(r *http.Request) (*Backend, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.ring) == 0 {
+		return nil, ErrNoHealthyBackends
+	}
+	key := h.keyFn(r)
+	if key == "" {
+		key = getClientIP(r)
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(h.ring), func(i int) bool { return h.ring[i].hash >= hash })
+	for i := 0; i < len(h.ring); i++ {
+		node := h.ring[(start+i)%len(h.ring)]
+		if node.backend.IsHealthy() {
+			return node.backend, nil
+		}
+	}
+	return nil, ErrNoHealthyBackends
+}
+
+func (h *ConsistentHash) GetBackends() []*Backend {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	copied := make([]*Backend, len(h.backends))
+	copy(copied, h.backends)
+	return copied
+}
+
+func (h *ConsistentHash) AddBackend(b *Backend) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backends = append(h.backends, b)
+	h.buildRing()
+}
+
+func (h *ConsistentHash) RemoveBackend(b *Backend) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, backend := range h.backends {
+		if backend.URL.String() == b.URL.String() {
+			h.backends = append(h.backends[:i], h.backends[i+1:]...)
+			h.buildRing()
+			return
+		}
+	}
+}
+
+// --- P2C ---
+
+// P2C is a power-of-two-choices balancer, it picks two healthy backends at random and selects
+// the one with the lower load, the load is the latency EWMA multiplied by the number of
+// in-flight requests plus one, so slow or busy backends receive less traffic.
+type P2C struct {
+	backends []*Backend
+	mu       sync.RWMutex
+}
+
+func NewP2C(backends []*Backend) *P2C {
+	return &P2C{backends: backends}
+}
+
+func (p *P2C) Next(_ *http.Request) (*Backend, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var healthy []*Backend
+	for _, b := range p.backends {
+		if b.IsHealthy() {
+			healthy = append(healthy, b)
+		}
+	}
+	switch len(healthy) {
+	case 0:
+		return nil, ErrNoHealthyBackends
+	case 1:
+		return healthy[0], nil
+	}
+
+	i := rand.Intn(len(healthy))     //nolint
+	j := rand.Intn(len(healthy) - 1) //nolint
+	if j >= i {
+		j++
+	}
+	a, b := healthy[i], healthy[j]
+	if p2cLoad(b) < p2cLoad(a) {
+		return b, nil
+	}
+	return a, nil
+}
+
+func p2cLoad(b *Backend) float64 {
+	// a backend without latency samples gets a minimal latency, so that it can be probed
+	latency := float64(b.GetLatency())
+	if latency <= 0 {
+		latency = 1
+	}
+	return latency * float64(b.GetActiveConns()+1)
+}
+
+func (p *P2C) GetBackends() []*Backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	copied := make([]*Backend, len(p.backends))
+	copy(copied, p.backends)
+	return copied
+}
+
+func (p *P2C) AddBackend(b *Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backends = append(p.backends, b)
+}
+
+func (p *P2C) RemoveBackend(b *Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, backend := range p.backends {
+		if backend.URL.String() == b.URL.String() {
+			p.backends = append(p.backends[:i], p.backends[i+1:]...)
+			return
+		}
+	}
+}
+
 func getClientIP(r *http.Request) string {
 	f := r.Header.Get("X-Forwarded-For")
 	if f != "" {
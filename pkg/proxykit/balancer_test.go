@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 // newTestBackend is a helper to create backends for balancer tests.
@@ -223,6 +224,196 @@ func TestIPHash(t *testing.T) {
 	})
 }
 
+func TestWeightedRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	a := newTestBackend(t, "http://a.com", true, 0)
+	b := newTestBackend(t, "http://b.com", true, 0)
+	c := newTestBackend(t, "http://c.com", true, 0)
+	d := newTestBackend(t, "http://d.com", false, 0) // Unhealthy
+	a.SetWeight(5)
+	d.SetWeight(10)
+
+	t.Run("Next - Smooth", func(t *testing.T) {
+		w := NewWeightedRoundRobin([]*Backend{a, b, c, d})
+		expected := []*Backend{a, a, b, a, c, a, a} // d is skipped
+		for round := 0; round < 2; round++ {
+			for i, exp := range expected {
+				next, err := w.Next(nil)
+				if err != nil {
+					t.Fatalf("test %d: Expected no error, got %v", i, err)
+				}
+				if next != exp {
+					t.Fatalf("round %d test %d: Expected backend %s, got %s", round, i, exp.URL, next.URL)
+				}
+			}
+		}
+	})
+
+	t.Run("Add/Remove/GetBackends", func(t *testing.T) {
+		w := NewWeightedRoundRobin(nil)
+		w.AddBackend(a)
+		w.AddBackend(b)
+		if len(w.GetBackends()) != 2 {
+			t.Error("expected 2 backends after add")
+		}
+		w.RemoveBackend(a)
+		next, err := w.Next(nil)
+		if err != nil || next != b {
+			t.Fatalf("expected b, got %v, %v", next, err)
+		}
+	})
+
+	t.Run("Next - No Healthy Backends", func(t *testing.T) {
+		w := NewWeightedRoundRobin([]*Backend{d})
+		_, err := w.Next(nil)
+		if err != ErrNoHealthyBackends {
+			t.Errorf("expected ErrNoHealthyBackends, got %v", err)
+		}
+	})
+}
+
+func TestConsistentHash(t *testing.T) {
+	t.Parallel()
+
+	var backends []*Backend
+	for i := 0; i < 5; i++ {
+		backends = append(backends, newTestBackend(t, "http://b"+strconv.Itoa(i)+".com", true, 0))
+	}
+	newReq := func(user string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/"+user, nil)
+		req.Header.Set("X-User-Id", user)
+		req.AddCookie(&http.Cookie{Name: "sid", Value: user})
+		return req
+	}
+
+	t.Run("Next - Same Key Same Backend", func(t *testing.T) {
+		keyFns := map[string]HashKeyFunc{
+			"header": HashKeyHeader("X-User-Id"),
+			"cookie": HashKeyCookie("sid"),
+			"path":   HashKeyPath(),
+		}
+		for name, fn := range keyFns {
+			h := NewConsistentHash(backends, WithHashKey(fn), WithVirtualNodes(50))
+			for i := 0; i < 20; i++ {
+				user := "user-" + strconv.Itoa(i)
+				first, err := h.Next(newReq(user))
+				if err != nil {
+					t.Fatal(err)
+				}
+				second, _ := h.Next(newReq(user))
+				if first != second {
+					t.Fatalf("%s: expected same backend for %s", name, user)
+				}
+			}
+		}
+	})
+
+	t.Run("Next - Minimal Remapping", func(t *testing.T) {
+		h := NewConsistentHash(append([]*Backend{}, backends...), WithHashKey(HashKeyHeader("X-User-Id")))
+		before := map[string]*Backend{}
+		for i := 0; i < 1000; i++ {
+			user := "user-" + strconv.Itoa(i)
+			before[user], _ = h.Next(newReq(user))
+		}
+
+		removed := backends[2]
+		h.RemoveBackend(removed)
+		for user, b := range before {
+			after, _ := h.Next(newReq(user))
+			if b != removed && after != b {
+				t.Fatalf("key %s moved from %s to %s", user, b.URL, after.URL)
+			}
+			if after == removed {
+				t.Fatalf("key %s still mapped to removed backend", user)
+			}
+		}
+	})
+
+	t.Run("Next - Skip Unhealthy", func(t *testing.T) {
+		b1 := newTestBackend(t, "http://u1.com", true, 0)
+		b2 := newTestBackend(t, "http://u2.com", true, 0)
+		h := NewConsistentHash([]*Backend{b1, b2}, WithHashKey(HashKeyHeader("X-User-Id")))
+		req := newReq("user-1")
+		first, _ := h.Next(req)
+		first.SetHealthy(false)
+		second, err := h.Next(req)
+		if err != nil || second == first {
+			t.Fatalf("expected fallback to another healthy backend, got %v, %v", second, err)
+		}
+		second.SetHealthy(false)
+		if _, err = h.Next(req); err != ErrNoHealthyBackends {
+			t.Errorf("expected ErrNoHealthyBackends, got %v", err)
+		}
+	})
+
+	t.Run("Next - Empty Key Uses Client IP", func(t *testing.T) {
+		h := NewConsistentHash(backends, WithHashKey(HashKeyHeader("X-Not-Exist")))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "1.1.1.1:12345"
+		first, _ := h.Next(req)
+		second, _ := h.Next(req)
+		if first != second {
+			t.Fatal("expected same backend for same client IP")
+		}
+	})
+
+	t.Run("Next - Nil Backends", func(t *testing.T) {
+		h := NewConsistentHash(nil)
+		_, err := h.Next(newReq("user-1"))
+		if err != ErrNoHealthyBackends {
+			t.Errorf("expected ErrNoHealthyBackends, got %v", err)
+		}
+	})
+}
+
+func TestP2C(t *testing.T) {
+	t.Parallel()
+
+	fast := newTestBackend(t, "http://fast.com", true, 0)
+	slow := newTestBackend(t, "http://slow.com", true, 0)
+	down := newTestBackend(t, "http://down.com", false, 0) // Unhealthy
+	fast.ObserveLatency(10 * time.Millisecond)
+	slow.ObserveLatency(500 * time.Millisecond)
+
+	t.Run("Next - Prefer Lower Load", func(t *testing.T) {
+		p := NewP2C([]*Backend{fast, slow, down})
+		for i := 0; i < 50; i++ {
+			next, err := p.Next(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != fast {
+				t.Fatalf("test %d: expected fast backend, got %s", i, next.URL)
+			}
+		}
+
+		// busy fast backend loses to idle slow backend
+		fast.activeConns.Store(100)
+		defer fast.activeConns.Store(0)
+		next, _ := p.Next(nil)
+		if next != slow {
+			t.Fatalf("expected slow backend, got %s", next.URL)
+		}
+	})
+
+	t.Run("Next - Single And No Healthy Backends", func(t *testing.T) {
+		p := NewP2C([]*Backend{down})
+		if _, err := p.Next(nil); err != ErrNoHealthyBackends {
+			t.Errorf("expected ErrNoHealthyBackends, got %v", err)
+		}
+		p.AddBackend(slow)
+		next, err := p.Next(nil)
+		if err != nil || next != slow {
+			t.Fatalf("expected slow backend, got %v, %v", next, err)
+		}
+		p.RemoveBackend(slow)
+		if len(p.GetBackends()) != 1 {
+			t.Error("expected 1 backend after remove")
+		}
+	})
+}
+
 func TestGetClientIP(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -289,6 +480,9 @@ func TestBalancerConcurrency(t *testing.T) {
 		"RoundRobin":       NewRoundRobin([]*Backend{b1, b2}),
 		"LeastConnections": NewLeastConnections([]*Backend{b1, b2}),
 		"IPHash":           NewIPHash([]*Backend{b1, b2}),
+		"WeightedRR":       NewWeightedRoundRobin([]*Backend{b1, b2}),
+		"ConsistentHash":   NewConsistentHash([]*Backend{b1, b2}),
+		"P2C":              NewP2C([]*Backend{b1, b2}),
 	}
 
 	for name, bal := range balancers {
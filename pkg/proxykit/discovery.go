@@ -0,0 +1,236 @@
+package proxykit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/servicerd/registry"
+)
+
+// DiscoveryOption set options for DiscoveryWatcher.
+type DiscoveryOption func(*discoveryOptions)
+
+type discoveryOptions struct {
+	scheme       string
+	healthCheck  HealthCheckConfig
+	drainTimeout time.Duration
+}
+
+func defaultDiscoveryOptions() *discoveryOptions {
+	return &discoveryOptions{
+		scheme:       "http",
+		drainTimeout: 30 * time.Second,
+	}
+}
+
+func (o *discoveryOptions) apply(opts ...DiscoveryOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithDiscoveryScheme sets the scheme of the service instance endpoints used as backends, default "http".
+func WithDiscoveryScheme(scheme string) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		if scheme != "" {
+			o.scheme = scheme
+		}
+	}
+}
+
+// WithDiscoveryHealthCheck sets the health check config of the discovered backends.
+func WithDiscoveryHealthCheck(config HealthCheckConfig) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		o.healthCheck = config
+	}
+}
+
+// WithDrainTimeout sets the max time to wait for the in-flight requests of the removed backend, default 30s.
+func WithDrainTimeout(d time.Duration) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		if d > 0 {
+			o.drainTimeout = d
+		}
+	}
+}
+
+// DiscoveryWatcher keeps the backends of a route in sync with the service instances of the
+// service discovery, the new instances are added to the balancer, and the disappeared instances
+// are removed from the balancer and drained. The static backends of the route are not affected.
+type DiscoveryWatcher struct {
+	route       *Route
+	serviceName string
+	opts        *discoveryOptions
+
+	watcher registry.Watcher
+	managed map[string]*Backend // backend url -> backend, only accessed by the watch goroutine
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewDiscoveryWatcher watches the service instances of the serviceName, and syncs them to the backends of the route.
+func NewDiscoveryWatcher(route *Route, discovery registry.Discovery, serviceName string, opts ...DiscoveryOption) (*DiscoveryWatcher, error) {
+	if route == nil || discovery == nil {
+		return nil, errors.New("route and discovery cannot be nil")
+	}
+	o := defaultDiscoveryOptions()
+	o.apply(opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher, err := discovery.Watch(ctx, serviceName)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w := &DiscoveryWatcher{
+		route:       route,
+		serviceName: serviceName,
+		opts:        o,
+		watcher:     watcher,
+		managed:     make(map[string]*Backend),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go w.watch()
+	return w, nil
+}
+
+func (w *DiscoveryWatcher) watch() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		default:
+		}
+
+		instances, err := w.watcher.Next()
+		if err != nil {
+			if errors.Is(err, context.Canceled) || w.ctx.Err() != nil {
+				return
+			}
+			log.Printf("[Discovery] failed to watch service '%s': %v", w.serviceName, err)
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		w.update(instances)
+	}
+}
+
+func (w *DiscoveryWatcher) update(instances []*registry.ServiceInstance) {
+	latest := make(map[string]int) // backend url -> weight
+	for _, ins := range instances {
+		target := w.parseEndpoint(ins.Endpoints)
+		if target == "" {
+			continue
+		}
+		weight, _ := strconv.Atoi(ins.Metadata["weight"])
+		latest[target] = weight
+	}
+	// same as the grpc resolver, an empty instance list is usually caused by the registry
+	// failure, keep the current backends instead of removing all of them.
+	if len(latest) == 0 {
+		return
+	}
+
+	route := w.route
+	route.mu.Lock()
+	defer route.mu.Unlock()
+
+	for target, weight := range latest {
+		if b, ok := w.managed[target]; ok {
+			b.SetWeight(weight)
+			continue
+		}
+		if containsTarget(route.Backends, target) {
+			continue // static backend
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			continue
+		}
+		backend := NewBackend(route.PrefixPath, u)
+		backend.SetWeight(weight)
+		route.Backends = append(route.Backends, backend)
+		route.Balancer.AddBackend(backend)
+		StartHealthChecks([]*Backend{backend}, w.opts.healthCheck)
+		w.managed[target] = backend
+		log.Printf("[Discovery] added backend '%s' to route '%s'", target, route.PrefixPath)
+	}
+
+	for target, backend := range w.managed {
+		if _, ok := latest[target]; ok {
+			continue
+		}
+		route.Balancer.RemoveBackend(backend)
+		route.Proxy.removeBreaker(backend)
+		route.Backends = removeBackend(route.Backends, backend)
+		delete(w.managed, target)
+		go drainBackend(backend, w.opts.drainTimeout)
+		log.Printf("[Discovery] removed backend '%s' from route '%s'", target, route.PrefixPath)
+	}
+}
+
+// parseEndpoint returns the backend url of the first endpoint matching the scheme,
+// e.g. http://127.0.0.1:8080?isSecure=true is converted to https://127.0.0.1:8080.
+func (w *DiscoveryWatcher) parseEndpoint(endpoints []string) string {
+	for _, e := range endpoints {
+		u, err := url.Parse(e)
+		if err != nil || u.Scheme != w.opts.scheme || u.Host == "" {
+			continue
+		}
+		scheme := "http"
+		if ok, _ := strconv.ParseBool(u.Query().Get("isSecure")); ok {
+			scheme = "https"
+		}
+		return scheme + "://" + u.Host
+	}
+	return ""
+}
+
+// Stop stops watching, the discovered backends are kept in the route.
+func (w *DiscoveryWatcher) Stop() error {
+	var err error
+	w.once.Do(func() {
+		w.cancel()
+		err = w.watcher.Stop()
+		<-w.done
+	})
+	return err
+}
+
+// drainBackend waits for the in-flight requests of the removed backend to complete,
+// then stops the health check and closes the idle connections.
+func drainBackend(b *Backend, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for b.GetActiveConns() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	b.StopHealthCheck()
+	if t, ok := b.proxy.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+func removeBackend(backends []*Backend, b *Backend) []*Backend {
+	result := make([]*Backend, 0, len(backends))
+	for _, backend := range backends {
+		if backend != b {
+			result = append(result, backend)
+		}
+	}
+	return result
+}
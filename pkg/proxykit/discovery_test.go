@@ -0,0 +1,134 @@
+package proxykit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/servicerd/registry"
+)
+
+type fakeDiscovery struct {
+	ch chan []*registry.ServiceInstance
+}
+
+func (d *fakeDiscovery) GetService(ctx context.Context, serviceName string) ([]*registry.ServiceInstance, error) {
+	return nil, nil
+}
+
+func (d *fakeDiscovery) Watch(ctx context.Context, serviceName string) (registry.Watcher, error) {
+	return &fakeWatcher{ctx: ctx, ch: d.ch}, nil
+}
+
+type fakeWatcher struct {
+	ctx context.Context
+	ch  chan []*registry.ServiceInstance
+}
+
+func (w *fakeWatcher) Next() ([]*registry.ServiceInstance, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, w.ctx.Err()
+	case ins := <-w.ch:
+		return ins, nil
+	}
+}
+
+func (w *fakeWatcher) Stop() error {
+	return nil
+}
+
+func newInstance(id string, endpoint string, weight string) *registry.ServiceInstance {
+	return registry.NewServiceInstance(id, "user", []string{"grpc://127.0.0.1:9090", endpoint},
+		registry.WithMetadata(map[string]string{"weight": weight}))
+}
+
+func waitBackends(t *testing.T, route *Route, n int) []*Backend {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if backends := route.Balancer.GetBackends(); len(backends) == n {
+			return backends
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d backends, got %d", n, len(route.Balancer.GetBackends()))
+	return nil
+}
+
+func TestDiscoveryWatcher(t *testing.T) {
+	static, _ := ParseBackends("/api/", []string{"http://10.0.0.1:8080"})
+	m := NewRouteManager()
+	route, err := m.AddRoute("/api/", NewRoundRobin(static))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &fakeDiscovery{ch: make(chan []*registry.ServiceInstance)}
+	w, err := NewDiscoveryWatcher(route, d, "user", WithDiscoveryHealthCheck(HealthCheckConfig{Interval: time.Hour}), WithDrainTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add instances, the static backend is kept
+	d.ch <- []*registry.ServiceInstance{
+		newInstance("1", "http://10.0.0.2:8080?isSecure=false", "3"),
+		newInstance("2", "http://10.0.0.3:8080?isSecure=true", ""),
+		newInstance("3", "grpc://10.0.0.4:9090", ""), // no http endpoint
+		newInstance("4", "http://10.0.0.1:8080", ""), // same as static backend
+	}
+	backends := waitBackends(t, route, 3)
+	urls := map[string]int{}
+	for _, b := range backends {
+		urls[b.URL.String()] = b.GetWeight()
+	}
+	if urls["http://10.0.0.2:8080"] != 3 || urls["https://10.0.0.3:8080"] != 1 || urls["http://10.0.0.1:8080"] != 1 {
+		t.Fatalf("unexpected backends: %v", urls)
+	}
+
+	// remove instance, in-flight request is drained
+	var removed *Backend
+	for _, b := range backends {
+		if b.URL.String() == "https://10.0.0.3:8080" {
+			removed = b
+		}
+	}
+	removed.IncrementActiveConns()
+	route.Proxy.breakers.Store(removed.URL.String(), &rejectBreaker{})
+	d.ch <- []*registry.ServiceInstance{newInstance("1", "http://10.0.0.2:8080", "3")}
+	waitBackends(t, route, 2)
+	route.mu.RLock()
+	if len(route.Backends) != 2 {
+		t.Errorf("expected 2 route backends, got %d", len(route.Backends))
+	}
+	route.mu.RUnlock()
+	if _, ok := route.Proxy.breakers.Load(removed.URL.String()); ok {
+		t.Error("expected breaker of removed backend deleted")
+	}
+	select {
+	case <-removed.stopHealthCheck:
+		t.Fatal("expected health check not stopped before draining")
+	case <-time.After(200 * time.Millisecond):
+	}
+	removed.DecrementActiveConns()
+	select {
+	case <-removed.stopHealthCheck:
+	case <-time.After(time.Second):
+		t.Fatal("expected health check stopped after draining")
+	}
+
+	// empty instances are ignored
+	d.ch <- []*registry.ServiceInstance{}
+	time.Sleep(50 * time.Millisecond)
+	waitBackends(t, route, 2)
+
+	if err = w.Stop(); err != nil {
+		t.Error(err)
+	}
+	_ = w.Stop()
+}
+
+func TestNewDiscoveryWatcherError(t *testing.T) {
+	if _, err := NewDiscoveryWatcher(nil, &fakeDiscovery{}, "user"); err == nil {
+		t.Error("expected error for nil route")
+	}
+}
@@ -46,7 +46,7 @@ func runHealthCheck(backend *Backend, config HealthCheckConfig) {
 					port = "80"
 				default:
 					log.Printf("[Health Check] Unsupported scheme '%s' for backend %s, marking as UNHEALTHY", backend.URL.Scheme, backend.URL)
-					if backend.IsHealthy() {
+					if backend.isHealthy.Load() {
 						backend.SetHealthy(false)
 					}
 					continue
@@ -56,7 +56,7 @@ func runHealthCheck(backend *Backend, config HealthCheckConfig) {
 			addressToDial := net.JoinHostPort(host, port)
 			conn, err := net.DialTimeout("tcp", addressToDial, config.Timeout)
 			if err != nil {
-				if backend.IsHealthy() {
+				if backend.isHealthy.Load() {
 					log.Printf("[Health Check] %s is now UNHEALTHY: failed to connect to %s - %v", backend.URL, addressToDial, err)
 					backend.SetHealthy(false)
 				}
@@ -64,7 +64,7 @@ func runHealthCheck(backend *Backend, config HealthCheckConfig) {
 			}
 			_ = conn.Close()
 
-			if !backend.IsHealthy() {
+			if !backend.isHealthy.Load() {
 				log.Printf("[Health Check] %s is now HEALTHY", backend.URL)
 				backend.SetHealthy(true)
 			}
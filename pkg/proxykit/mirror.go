@@ -0,0 +1,166 @@
+package proxykit
+
+import (
+	"context"
+	"hash"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// MirrorPolicy defined the policy for shadow traffic, a percentage of the requests are copied
+// to the canary backend, the responses of the canary backend are discarded, and the differences
+// of status code and body from the primary response are logged.
+type MirrorPolicy struct {
+	Target  string        `json:"target"`  // url of the canary backend, e.g. http://localhost:9090
+	Percent float64       `json:"percent"` // percentage of mirrored requests, 0~100
+	Timeout time.Duration `json:"timeout"` // timeout of mirrored request, default 5s
+}
+
+// MirrorStats is the statistics of mirrored requests.
+type MirrorStats struct {
+	Mirrored int64 `json:"mirrored"` // number of mirrored requests
+	Diffs    int64 `json:"diffs"`    // number of mirrored responses different from the primary responses
+}
+
+type mirror struct {
+	backend *Backend
+	percent float64
+	timeout time.Duration
+
+	mirrored atomic.Int64
+	diffs    atomic.Int64
+}
+
+func newMirror(prefixPath string, policy MirrorPolicy) (*mirror, error) {
+	u, err := url.Parse(policy.Target)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = 5 * time.Second
+	}
+	return &mirror{
+		backend: NewBackend(prefixPath, u),
+		percent: policy.Percent,
+		timeout: policy.Timeout,
+	}, nil
+}
+
+func (m *mirror) sampled() bool {
+	return m.percent > 0 && rand.Float64()*100 < m.percent //nolint
+}
+
+type responseSummary struct {
+	status int
+	size   int64
+	sum    uint64
+}
+
+// start sends a copy of the request to the canary backend, the returned writer records
+// the primary response, and finish must be called after the primary response is written.
+func (m *mirror) start(w http.ResponseWriter, r *http.Request, body []byte) *mirrorWriter {
+	m.mirrored.Add(1)
+	mw := &mirrorWriter{ResponseWriter: w, hash: fnv.New64a(), done: make(chan responseSummary, 1)}
+	req := cloneRequest(context.Background(), r, body)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		rec := &mirrorRecorder{header: make(http.Header), hash: fnv.New64a()}
+		m.backend.proxy.ServeHTTP(rec, req.WithContext(ctx))
+		shadow := rec.summary()
+
+		primary := <-mw.done
+		if primary != shadow {
+			m.diffs.Add(1)
+			log.Printf("[Mirror] response diff, method=%s, path=%s, primary(status=%d, size=%d), mirror(status=%d, size=%d)",
+				req.Method, req.URL.Path, primary.status, primary.size, shadow.status, shadow.size)
+		}
+	}()
+
+	return mw
+}
+
+// mirrorWriter records the status, size and hash of the primary response.
+type mirrorWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	hash   hash.Hash64
+	done   chan responseSummary
+}
+
+func (w *mirrorWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *mirrorWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	_, _ = w.hash.Write(b[:n])
+	return n, err
+}
+
+func (w *mirrorWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter, used by http.ResponseController.
+func (w *mirrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *mirrorWriter) finish() {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.done <- responseSummary{status: status, size: w.size, sum: w.hash.Sum64()}
+}
+
+// mirrorRecorder discards the response of the canary backend, only the summary is recorded.
+type mirrorRecorder struct {
+	header http.Header
+	status int
+	size   int64
+	hash   hash.Hash64
+}
+
+func (r *mirrorRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *mirrorRecorder) WriteHeader(code int) {
+	if r.status == 0 && code >= http.StatusOK {
+		r.status = code
+	}
+}
+
+func (r *mirrorRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.size += int64(len(b))
+	_, _ = r.hash.Write(b)
+	return len(b), nil
+}
+
+func (r *mirrorRecorder) summary() responseSummary {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return responseSummary{status: status, size: r.size, sum: r.hash.Sum64()}
+}
@@ -0,0 +1,154 @@
+package proxykit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OutlierDetectionConfig defined the configuration for passive health check, the backend
+// is ejected from load balancing after consecutive 5xx responses or connection errors, the
+// ejection time grows exponentially with the number of times the backend has been ejected.
+type OutlierDetectionConfig struct {
+	ConsecutiveErrors  int           `json:"consecutiveErrors"`  // default 5
+	BaseEjectionTime   time.Duration `json:"baseEjectionTime"`   // default 30s
+	MaxEjectionTime    time.Duration `json:"maxEjectionTime"`    // default 300s
+	MaxEjectionPercent int           `json:"maxEjectionPercent"` // max percentage of ejected backends, default 50
+}
+
+func (c *OutlierDetectionConfig) setDefaults() {
+	if c.ConsecutiveErrors <= 0 {
+		c.ConsecutiveErrors = 5
+	}
+	if c.BaseEjectionTime <= 0 {
+		c.BaseEjectionTime = 30 * time.Second
+	}
+	if c.MaxEjectionTime < c.BaseEjectionTime {
+		c.MaxEjectionTime = 10 * c.BaseEjectionTime
+	}
+	if c.MaxEjectionPercent <= 0 || c.MaxEjectionPercent > 100 {
+		c.MaxEjectionPercent = 50
+	}
+}
+
+// outlierState is the passive health state of a backend.
+type outlierState struct {
+	mu                sync.Mutex
+	consecutiveErrors int
+	ejectionCount     int
+	ejectedUntil      time.Time
+}
+
+// outlierDetector ejects the backends of a balancer according to the results of the proxied requests.
+type outlierDetector struct {
+	config   OutlierDetectionConfig
+	balancer Balancer
+	mu       sync.Mutex // serializes ejections, so that the max ejection percent is not exceeded
+}
+
+func newOutlierDetector(balancer Balancer, config OutlierDetectionConfig) *outlierDetector {
+	config.setDefaults()
+	return &outlierDetector{
+		config:   config,
+		balancer: balancer,
+	}
+}
+
+// isFailure reports whether the response status code is counted as a failure,
+// connection errors are reported by the reverse proxy as 502.
+func isFailure(code int) bool {
+	return code >= http.StatusInternalServerError
+}
+
+func (d *outlierDetector) report(b *Backend, code int) {
+	s := &b.outlier
+	now := time.Now()
+
+	s.mu.Lock()
+	if !isFailure(code) {
+		s.consecutiveErrors = 0
+		// forget the ejection history after the backend is stable for a max ejection time
+		if s.ejectionCount > 0 && now.Sub(s.ejectedUntil) > d.config.MaxEjectionTime {
+			s.ejectionCount = 0
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.consecutiveErrors++
+	if s.consecutiveErrors < d.config.ConsecutiveErrors || now.Before(s.ejectedUntil) {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	d.eject(b, now)
+}
+
+func (d *outlierDetector) eject(b *Backend, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	backends := d.balancer.GetBackends()
+	ejected := 0
+	for _, backend := range backends {
+		if backend.IsEjected() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > d.config.MaxEjectionPercent*len(backends) {
+		return
+	}
+
+	s := &b.outlier
+	s.mu.Lock()
+	duration := d.config.BaseEjectionTime << uint(s.ejectionCount)
+	if duration > d.config.MaxEjectionTime || duration <= 0 {
+		duration = d.config.MaxEjectionTime
+	} else {
+		s.ejectionCount++
+	}
+	s.ejectedUntil = now.Add(duration)
+	s.consecutiveErrors = 0
+	s.mu.Unlock()
+	b.ejectedUntil.Store(now.Add(duration).UnixNano())
+
+	log.Printf("[Outlier Detection] %s is ejected for %s", b.URL, duration)
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 && code >= http.StatusOK {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter, used by http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
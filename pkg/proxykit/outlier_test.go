@@ -0,0 +1,225 @@
+package proxykit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/shield/circuitbreaker"
+)
+
+func newStatusServer(t *testing.T, code int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newServerBackend(t *testing.T, rawURL string) *Backend {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+	return NewBackend("/", u)
+}
+
+func TestOutlierDetectionConfig(t *testing.T) {
+	c := OutlierDetectionConfig{}
+	c.setDefaults()
+	if c.ConsecutiveErrors != 5 || c.BaseEjectionTime != 30*time.Second ||
+		c.MaxEjectionTime != 300*time.Second || c.MaxEjectionPercent != 50 {
+		t.Errorf("unexpected defaults: %+v", c)
+	}
+}
+
+func TestProxy_OutlierDetection(t *testing.T) {
+	good := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+	bad := newServerBackend(t, newStatusServer(t, http.StatusInternalServerError).URL)
+
+	p, err := NewProxy(NewRoundRobin([]*Backend{good, bad}), WithOutlierDetection(OutlierDetectionConfig{
+		ConsecutiveErrors: 2,
+		BaseEjectionTime:  time.Minute,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if !bad.IsEjected() || bad.IsHealthy() {
+		t.Fatal("expected bad backend to be ejected")
+	}
+	if good.IsEjected() {
+		t.Fatal("expected good backend not to be ejected")
+	}
+
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	}
+}
+
+func TestOutlierDetector_Ejection(t *testing.T) {
+	t.Run("Exponential Ejection Time", func(t *testing.T) {
+		b1 := newTestBackend(t, "http://b1.com", true, 0)
+		b2 := newTestBackend(t, "http://b2.com", true, 0)
+		d := newOutlierDetector(NewRoundRobin([]*Backend{b1, b2}), OutlierDetectionConfig{
+			ConsecutiveErrors: 1,
+			BaseEjectionTime:  time.Second,
+			MaxEjectionTime:   3 * time.Second,
+		})
+
+		expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+		for i, exp := range expected {
+			b1.ejectedUntil.Store(0)
+			b1.outlier.ejectedUntil = time.Time{}
+			now := time.Now()
+			d.report(b1, http.StatusBadGateway)
+			got := time.Duration(b1.ejectedUntil.Load() - now.UnixNano())
+			if got < exp || got > exp+100*time.Millisecond {
+				t.Fatalf("test %d: expected ejection time %s, got %s", i, exp, got)
+			}
+		}
+	})
+
+	t.Run("Success Resets Consecutive Errors", func(t *testing.T) {
+		b1 := newTestBackend(t, "http://b1.com", true, 0)
+		b2 := newTestBackend(t, "http://b2.com", true, 0)
+		d := newOutlierDetector(NewRoundRobin([]*Backend{b1, b2}), OutlierDetectionConfig{ConsecutiveErrors: 2})
+		d.report(b1, http.StatusInternalServerError)
+		d.report(b1, http.StatusOK)
+		d.report(b1, http.StatusServiceUnavailable)
+		if b1.IsEjected() {
+			t.Fatal("expected backend not to be ejected")
+		}
+		d.report(b1, http.StatusServiceUnavailable)
+		if !b1.IsEjected() {
+			t.Fatal("expected backend to be ejected")
+		}
+	})
+
+	t.Run("Max Ejection Percent", func(t *testing.T) {
+		backends := []*Backend{
+			newTestBackend(t, "http://b1.com", true, 0),
+			newTestBackend(t, "http://b2.com", true, 0),
+			newTestBackend(t, "http://b3.com", true, 0),
+			newTestBackend(t, "http://b4.com", true, 0),
+		}
+		d := newOutlierDetector(NewRoundRobin(backends), OutlierDetectionConfig{ConsecutiveErrors: 1, MaxEjectionPercent: 50})
+		for _, b := range backends {
+			d.report(b, http.StatusInternalServerError)
+		}
+		ejected := 0
+		for _, b := range backends {
+			if b.IsEjected() {
+				ejected++
+			}
+		}
+		if ejected != 2 {
+			t.Fatalf("expected 2 ejected backends, got %d", ejected)
+		}
+
+		// the only backend is never ejected with default max ejection percent
+		single := newTestBackend(t, "http://single.com", true, 0)
+		d = newOutlierDetector(NewRoundRobin([]*Backend{single}), OutlierDetectionConfig{ConsecutiveErrors: 1})
+		d.report(single, http.StatusInternalServerError)
+		if single.IsEjected() {
+			t.Fatal("expected the only backend not to be ejected")
+		}
+	})
+}
+
+type rejectBreaker struct {
+	failed int
+}
+
+func (b *rejectBreaker) Allow() error { return circuitbreaker.ErrNotAllowed }
+func (b *rejectBreaker) MarkSuccess() {}
+func (b *rejectBreaker) MarkFailed()  { b.failed++ }
+
+func TestProxy_CircuitBreaker(t *testing.T) {
+	good := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+	open := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+
+	p, err := NewProxy(NewRoundRobin([]*Backend{open, good}), WithCircuitBreaker(circuitbreaker.WithRequest(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb := &rejectBreaker{}
+	p.breakers.Store(open.URL.String(), rb)
+
+	for i := 0; i < 4; i++ {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	}
+	// each request selects the open backend first, then retries the good one
+	if rb.failed != 4 {
+		t.Errorf("expected 4 rejected requests, got %d", rb.failed)
+	}
+
+	// all breakers are open
+	p.breakers.Store(good.URL.String(), rb)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rec.Code)
+	}
+}
+
+func TestProxy_CircuitBreakerConsistentHash(t *testing.T) {
+	b1 := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+	b2 := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+	b3 := newServerBackend(t, newStatusServer(t, http.StatusOK).URL)
+	balancers := map[string]Balancer{
+		"consistent_hash": NewConsistentHash([]*Backend{b1, b2, b3}),
+		"ip_hash":         NewIPHash([]*Backend{b1, b2, b3}),
+	}
+	for name, balancer := range balancers {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewProxy(balancer, WithCircuitBreaker())
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			selected, _ := balancer.Next(req)
+
+			// the backend selected by the hash is rejected, another backend is used
+			rb := &rejectBreaker{}
+			p.breakers.Store(selected.URL.String(), rb)
+			backend, _, err := p.selectBackend(req, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backend == selected {
+				t.Fatal("expected the rejected backend not to be selected")
+			}
+			if rb.failed != 1 {
+				t.Errorf("expected 1 rejected request, got %d", rb.failed)
+			}
+
+			// all breakers are open, each backend is tried once
+			for _, b := range []*Backend{b1, b2, b3} {
+				p.breakers.Store(b.URL.String(), rb)
+			}
+			rb.failed = 0
+			if _, _, err = p.selectBackend(req, nil); err == nil {
+				t.Fatal("expected error when all breakers are open")
+			}
+			if rb.failed != 3 {
+				t.Errorf("expected 3 rejected requests, got %d", rb.failed)
+			}
+		})
+	}
+}
@@ -2,39 +2,296 @@ package proxykit
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/shield/circuitbreaker"
 )
 
+// ProxyOption set options for Proxy.
+type ProxyOption func(*proxyOptions)
+
+type proxyOptions struct {
+	prefixPath       string
+	outlierDetection *OutlierDetectionConfig
+	breakerOpts      []circuitbreaker.Option
+	enableBreaker    bool
+	retry            *RetryPolicy
+	hedge            *HedgePolicy
+	mirror           *MirrorPolicy
+	rewrite          *RewriteConfig
+}
+
+func defaultProxyOptions() *proxyOptions {
+	return &proxyOptions{}
+}
+
+func (o *proxyOptions) apply(opts ...ProxyOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithOutlierDetection enable passive health check, backends with consecutive 5xx responses
+// or connection errors are ejected from load balancing for a while.
+func WithOutlierDetection(config OutlierDetectionConfig) ProxyOption {
+	return func(o *proxyOptions) {
+		o.outlierDetection = &config
+	}
+}
+
+// WithCircuitBreaker enable circuit breaker for each backend, the requests rejected by the
+// breaker of a backend are forwarded to other backends.
+func WithCircuitBreaker(opts ...circuitbreaker.Option) ProxyOption {
+	return func(o *proxyOptions) {
+		o.enableBreaker = true
+		o.breakerOpts = opts
+	}
+}
+
+// WithRetry enable retrying the idempotent requests on another backend when the response
+// status code is retryable, the retries are limited by the budget of the policy.
+func WithRetry(policy RetryPolicy) ProxyOption {
+	return func(o *proxyOptions) {
+		policy.setDefaults()
+		o.retry = &policy
+	}
+}
+
+// WithHedging enable hedged requests for the idempotent requests, if the backend does not
+// respond within the delay, the request is sent to another backend and the first response wins.
+func WithHedging(policy HedgePolicy) ProxyOption {
+	return func(o *proxyOptions) {
+		policy.setDefaults()
+		o.hedge = &policy
+	}
+}
+
+// WithMirror enable mirroring a percentage of the requests to the canary backend,
+// the responses of the canary backend are discarded.
+func WithMirror(policy MirrorPolicy) ProxyOption {
+	return func(o *proxyOptions) {
+		o.mirror = &policy
+	}
+}
+
+// WithRewrite sets the rewrite rules of path and headers, the route prefix is stripped
+// from the path by default.
+func WithRewrite(config RewriteConfig) ProxyOption {
+	return func(o *proxyOptions) {
+		o.rewrite = &config
+	}
+}
+
+// withPrefixPath sets the prefix path of the route, used by mirroring and rewriting.
+func withPrefixPath(prefixPath string) ProxyOption {
+	return func(o *proxyOptions) {
+		o.prefixPath = prefixPath
+	}
+}
+
 // Proxy is a reverse proxy that implements the http.Handler interface.
 type Proxy struct {
 	balancer Balancer
+
+	detector      *outlierDetector
+	enableBreaker bool
+	breakerOpts   []circuitbreaker.Option
+	breakers      sync.Map // backend url -> circuitbreaker.CircuitBreaker
+
+	retry   *RetryPolicy
+	hedge   *HedgePolicy
+	retryOn map[int]struct{}
+	budget  *retryBudget
+	mirror  *mirror
+
+	rewriter *rewriter
 }
 
 // NewProxy creates a new reverse proxy instance.
-func NewProxy(balancer Balancer) (*Proxy, error) {
+func NewProxy(balancer Balancer, opts ...ProxyOption) (*Proxy, error) {
 	if balancer == nil {
 		return nil, errors.New("balancer cannot be nil")
 	}
-	return &Proxy{
-		balancer: balancer,
-	}, nil
+	o := defaultProxyOptions()
+	o.apply(opts...)
+
+	p := &Proxy{
+		balancer:      balancer,
+		enableBreaker: o.enableBreaker,
+		breakerOpts:   o.breakerOpts,
+	}
+	if o.outlierDetection != nil {
+		p.detector = newOutlierDetector(balancer, *o.outlierDetection)
+	}
+	if o.retry != nil || o.hedge != nil {
+		retry := RetryPolicy{}
+		if o.retry != nil {
+			retry = *o.retry
+		}
+		retry.setDefaults()
+		p.retry, p.hedge = o.retry, o.hedge
+		p.budget = newRetryBudget(retry.BudgetRatio, retry.MinRetries)
+		p.retryOn = make(map[int]struct{}, len(retry.RetryOn))
+		for _, code := range retry.RetryOn {
+			p.retryOn[code] = struct{}{}
+		}
+	}
+	if o.mirror != nil {
+		m, err := newMirror(o.prefixPath, *o.mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror target: %w", err)
+		}
+		p.mirror = m
+	}
+	if o.rewrite != nil && !o.rewrite.isEmpty() {
+		p.rewriter = newRewriter(o.prefixPath, *o.rewrite)
+	}
+	return p, nil
+}
+
+// MirrorStats returns the statistics of mirrored requests.
+func (p *Proxy) MirrorStats() MirrorStats {
+	if p.mirror == nil {
+		return MirrorStats{}
+	}
+	return MirrorStats{
+		Mirrored: p.mirror.mirrored.Load(),
+		Diffs:    p.mirror.diffs.Load(),
+	}
 }
 
 // ServeHTTP handles incoming HTTP requests and forwards them to the backend
 // selected by the load balancer.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.rewriter != nil {
+		r = p.rewriter.rewriteRequest(r)
+		w = p.rewriter.wrapWriter(w)
+	}
+
+	needRetry := p.budget != nil && isIdempotent(r)
+	needMirror := p.mirror != nil && p.mirror.sampled()
+	if !needRetry && !needMirror {
+		p.serve(w, r)
+		return
+	}
+
+	body, ok := bufferBody(r)
+	if !ok {
+		p.serve(w, r)
+		return
+	}
+	if needMirror {
+		mw := p.mirror.start(w, r, body)
+		defer mw.finish()
+		w = mw
+	}
+	if needRetry {
+		p.serveWithRetry(w, r, body)
+		return
+	}
+	p.serve(w, r)
+}
+
+// serve forwards the request once.
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
 	// Select a healthy backend according to the load balancing strategy.
-	backend, err := p.balancer.Next(r)
+	backend, done, err := p.selectBackend(r, nil)
 	if err != nil {
 		log.Printf("[Proxy] error selecting backend: %v", err)
 		http.Error(w, "service not available", http.StatusServiceUnavailable)
 		return
 	}
+	p.forward(backend, done, w, r)
+}
 
+// forward forwards the request to the backend, and reports the result to the
+// outlier detection and circuit breaker, done is nil if the circuit breaker is disabled.
+func (p *Proxy) forward(backend *Backend, done circuitbreaker.DoneFunc, w http.ResponseWriter, r *http.Request) {
 	// Increase the active connection count, and ensure it is decremented
 	// when the request completes.
 	backend.IncrementActiveConns()
 	defer backend.DecrementActiveConns()
 
-	backend.proxy.ServeHTTP(w, r)
+	if p.detector == nil && done == nil {
+		start := time.Now()
+		backend.proxy.ServeHTTP(w, r)
+		backend.ObserveLatency(time.Since(start))
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	start := time.Now()
+	backend.proxy.ServeHTTP(rec, r)
+	backend.ObserveLatency(time.Since(start))
+
+	if r.Context().Err() != nil {
+		return // canceled by the client or by a hedged request
+	}
+	code := rec.statusCode()
+	if done != nil {
+		done(!isFailure(code))
+	}
+	if p.detector != nil {
+		p.detector.report(backend, code)
+	}
+}
+
+// selectBackend selects a backend not in exclude whose circuit breaker allows the request, the
+// backends rejected by their breakers are skipped, so the hash balancers that always return the
+// same backend for a request fall back to the other backends.
+func (p *Proxy) selectBackend(r *http.Request, exclude map[*Backend]struct{}) (*Backend, circuitbreaker.DoneFunc, error) {
+	skip := make(map[*Backend]struct{}, len(exclude))
+	for b := range exclude {
+		skip[b] = struct{}{}
+	}
+	backend, err := p.nextBackend(r, skip)
+	if err != nil || !p.enableBreaker {
+		return backend, nil, err
+	}
+
+	for {
+		done, err := circuitbreaker.Acquire(p.getBreaker(backend))
+		if err == nil {
+			return backend, done, nil
+		}
+		skip[backend] = struct{}{}
+		if backend, _ = p.nextBackend(r, skip); backend == nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// nextBackend returns the backend selected by the balancer, if it is skipped, the first
+// healthy backend not skipped is returned.
+func (p *Proxy) nextBackend(r *http.Request, skip map[*Backend]struct{}) (*Backend, error) {
+	backend, err := p.balancer.Next(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := skip[backend]; !ok {
+		return backend, nil
+	}
+	for _, b := range p.balancer.GetBackends() {
+		if _, ok := skip[b]; !ok && b.IsHealthy() {
+			return b, nil
+		}
+	}
+	return nil, ErrNoHealthyBackends
+}
+
+func (p *Proxy) getBreaker(b *Backend) circuitbreaker.CircuitBreaker {
+	key := b.URL.String()
+	if v, ok := p.breakers.Load(key); ok {
+		return v.(circuitbreaker.CircuitBreaker)
+	}
+	v, _ := p.breakers.LoadOrStore(key, circuitbreaker.NewBreaker(p.breakerOpts...))
+	return v.(circuitbreaker.CircuitBreaker)
+}
+
+// removeBreaker removes the circuit breaker of the backend removed from the balancer.
+func (p *Proxy) removeBreaker(b *Backend) {
+	p.breakers.Delete(b.URL.String())
 }
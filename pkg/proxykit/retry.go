@@ -0,0 +1,373 @@
+package proxykit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/shield/circuitbreaker"
+	"github.com/go-dev-frame/sponge/pkg/shield/window"
+)
+
+// maxReplayBodySize is the max size of the request body buffered for retry, hedging and mirroring,
+// the requests with larger body are forwarded once without these policies.
+const maxReplayBodySize = 1 << 20
+
+// RetryPolicy defined the policy for retrying idempotent requests on another backend.
+type RetryPolicy struct {
+	MaxAttempts int     `json:"maxAttempts"` // max attempts including the first one, default 2
+	RetryOn     []int   `json:"retryOn"`     // status codes to retry, default 502, 503, 504, connection errors are reported as 502
+	BudgetRatio float64 `json:"budgetRatio"` // max ratio of retries to requests in the last 10s, default 0.2
+	MinRetries  int     `json:"minRetries"`  // retries allowed in the last 10s regardless of the ratio, default 10
+}
+
+func (c *RetryPolicy) setDefaults() {
+	if c.MaxAttempts < 2 {
+		c.MaxAttempts = 2
+	}
+	if len(c.RetryOn) == 0 {
+		c.RetryOn = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	if c.BudgetRatio <= 0 {
+		c.BudgetRatio = 0.2
+	}
+	if c.MinRetries <= 0 {
+		c.MinRetries = 10
+	}
+}
+
+// HedgePolicy defined the policy for hedged requests, if the backend does not respond within
+// the delay, the same request is sent to another backend, and the first response wins.
+type HedgePolicy struct {
+	Delay     time.Duration `json:"delay"`     // default 100ms
+	MaxHedges int           `json:"maxHedges"` // max hedged requests, default 1
+}
+
+func (c *HedgePolicy) setDefaults() {
+	if c.Delay <= 0 {
+		c.Delay = 100 * time.Millisecond
+	}
+	if c.MaxHedges <= 0 {
+		c.MaxHedges = 1
+	}
+}
+
+// retryBudget limits the retries and hedged requests to a ratio of the requests, to avoid
+// retry storms when the backends are overloaded.
+type retryBudget struct {
+	requests   window.RollingCounter
+	retries    window.RollingCounter
+	ratio      float64
+	minRetries float64
+}
+
+func newRetryBudget(ratio float64, minRetries int) *retryBudget {
+	opts := window.RollingCounterOpts{Size: 10, BucketDuration: time.Second}
+	return &retryBudget{
+		requests:   window.NewRollingCounter(opts),
+		retries:    window.NewRollingCounter(opts),
+		ratio:      ratio,
+		minRetries: float64(minRetries),
+	}
+}
+
+func (b *retryBudget) recordRequest() {
+	b.requests.Add(1)
+}
+
+func (b *retryBudget) canRetry() bool {
+	limit := math.Max(b.minRetries, b.ratio*b.requests.Sum())
+	return b.retries.Sum() < limit
+}
+
+func (b *retryBudget) withdraw() bool {
+	if !b.canRetry() {
+		return false
+	}
+	b.retries.Add(1)
+	return true
+}
+
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// bufferBody reads the request body into memory so that it can be replayed, if the body
+// is too large, the body of the request is restored and false is returned.
+func bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > maxReplayBodySize {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReplayBodySize+1))
+	if err != nil || len(body) > maxReplayBodySize {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func cloneRequest(ctx context.Context, r *http.Request, body []byte) *http.Request {
+	req := r.Clone(ctx)
+	if body == nil {
+		req.Body = http.NoBody
+		return req
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req
+}
+
+// --------------------------------------------------------------------------------------
+
+// attemptGroup coordinates the attempts of a request, the first attempt writing a
+// response that is not discarded wins, and its response is written to the client.
+// If no attempt wins, e.g. the retry could not be started, the last discarded
+// response is written to the client.
+type attemptGroup struct {
+	w           http.ResponseWriter
+	retryOn     map[int]struct{}
+	budget      *retryBudget
+	mu          sync.Mutex
+	winner      *attemptWriter
+	failed      bool
+	last        *attemptWriter // the last finished attempt whose response was discarded
+	attempts    []*attemptWriter
+	inflight    int
+	retriesLeft int
+}
+
+// canDiscard reports whether the response of an attempt can be discarded, it must be called with the lock held.
+func (g *attemptGroup) canDiscard(code int) bool {
+	if _, ok := g.retryOn[code]; !ok {
+		return false
+	}
+	return g.inflight > 1 || (g.retriesLeft > 0 && g.budget.canRetry())
+}
+
+func (g *attemptGroup) hasResponse() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.winner != nil || g.failed
+}
+
+// fail writes the last discarded response, or an error response if there is none,
+// when no attempt has won.
+func (g *attemptGroup) fail() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.winner != nil || g.failed {
+		return
+	}
+	g.failed = true
+	if a := g.last; a != nil {
+		dst := g.w.Header()
+		for k, v := range a.header {
+			dst[k] = v
+		}
+		g.w.WriteHeader(a.status)
+		_, _ = g.w.Write(a.body.Bytes())
+		return
+	}
+	http.Error(g.w, "service not available", http.StatusBadGateway)
+}
+
+// attemptWriter is the http.ResponseWriter of an attempt.
+type attemptWriter struct {
+	g         *attemptGroup
+	header    http.Header
+	status    int
+	won       bool
+	discarded bool
+	body      bytes.Buffer // the body of the discarded response
+	cancel    context.CancelFunc
+}
+
+func (a *attemptWriter) Header() http.Header {
+	if a.won {
+		return a.g.w.Header()
+	}
+	return a.header
+}
+
+func (a *attemptWriter) WriteHeader(code int) {
+	if a.status != 0 || code < http.StatusOK {
+		return
+	}
+	a.status = code
+
+	g := a.g
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.winner != nil || g.failed {
+		return
+	}
+	if g.canDiscard(code) {
+		a.discarded = true
+		return
+	}
+
+	g.winner = a
+	a.won = true
+	dst := g.w.Header()
+	for k, v := range a.header {
+		dst[k] = v
+	}
+	g.w.WriteHeader(code)
+	for _, other := range g.attempts {
+		if other != a {
+			other.cancel()
+		}
+	}
+}
+
+func (a *attemptWriter) Write(b []byte) (int, error) {
+	if a.status == 0 {
+		a.WriteHeader(http.StatusOK)
+	}
+	if !a.won {
+		// keep the discarded response, it is written if the retry is not started
+		if a.discarded && a.body.Len() < maxReplayBodySize {
+			a.body.Write(b)
+		}
+		return len(b), nil
+	}
+	return a.g.w.Write(b)
+}
+
+func (a *attemptWriter) Flush() {
+	if !a.won {
+		return
+	}
+	if f, ok := a.g.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveWithRetry forwards the request with retry and hedging policies.
+func (p *Proxy) serveWithRetry(w http.ResponseWriter, r *http.Request, body []byte) {
+	p.budget.recordRequest()
+
+	g := &attemptGroup{w: w, retryOn: p.retryOn, budget: p.budget}
+	maxAttempts := 1
+	if p.retry != nil {
+		g.retriesLeft = p.retry.MaxAttempts - 1
+		maxAttempts += g.retriesLeft
+	}
+	hedgesLeft := 0
+	var hedgeTimer *time.Timer
+	var hedgeCh <-chan time.Time
+	if p.hedge != nil {
+		hedgesLeft = p.hedge.MaxHedges
+		maxAttempts += hedgesLeft
+		hedgeTimer = time.NewTimer(p.hedge.Delay)
+		defer hedgeTimer.Stop()
+		hedgeCh = hedgeTimer.C
+	}
+
+	results := make(chan *attemptWriter, maxAttempts)
+	tried := make(map[*Backend]struct{})
+	launch := func() bool {
+		backend, done, err := p.selectUntriedBackend(r, tried)
+		if err != nil {
+			log.Printf("[Proxy] error selecting backend: %v", err)
+			return false
+		}
+		tried[backend] = struct{}{}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		aw := &attemptWriter{g: g, header: make(http.Header), cancel: cancel}
+		g.mu.Lock()
+		g.attempts = append(g.attempts, aw)
+		g.inflight++
+		g.mu.Unlock()
+
+		req := cloneRequest(ctx, r, body)
+		go func() {
+			defer cancel()
+			p.forward(backend, done, aw, req)
+			if aw.status == 0 {
+				aw.WriteHeader(http.StatusOK)
+			}
+			g.mu.Lock()
+			g.inflight--
+			if aw.discarded {
+				g.last = aw
+			}
+			g.mu.Unlock()
+			results <- aw
+		}()
+		return true
+	}
+
+	if !launch() {
+		http.Error(w, "service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	for {
+		select {
+		case aw := <-results:
+			if aw.won {
+				return
+			}
+			g.mu.Lock()
+			decided := g.winner != nil
+			retry := !decided && g.retriesLeft > 0
+			if retry {
+				g.retriesLeft--
+			}
+			inflight := g.inflight
+			g.mu.Unlock()
+			if decided {
+				continue // wait for the winner to finish writing the response
+			}
+			if retry && p.budget.withdraw() && launch() {
+				continue
+			}
+			if inflight == 0 {
+				g.fail()
+				return
+			}
+
+		case <-hedgeCh:
+			hedgesLeft--
+			if hedgesLeft > 0 {
+				hedgeTimer.Reset(p.hedge.Delay)
+			} else {
+				hedgeCh = nil
+			}
+			if !g.hasResponse() && p.budget.withdraw() {
+				launch()
+			}
+		}
+	}
+}
+
+// selectUntriedBackend prefers the backends that have not been tried, if all the backends
+// have been tried or rejected, the backend selected by the balancer is returned.
+func (p *Proxy) selectUntriedBackend(r *http.Request, tried map[*Backend]struct{}) (*Backend, circuitbreaker.DoneFunc, error) {
+	backend, done, err := p.selectBackend(r, tried)
+	if err != nil && len(tried) > 0 {
+		return p.selectBackend(r, nil)
+	}
+	return backend, done, err
+}
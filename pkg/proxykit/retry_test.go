@@ -0,0 +1,230 @@
+package proxykit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountServer(t *testing.T, code int, delay time.Duration, body string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	count := &atomic.Int64{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		data, _ := io.ReadAll(r.Body)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("X-Backend", body)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body + string(data)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, count
+}
+
+func doRequest(p *Proxy, method string, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	p.ServeHTTP(rec, httptest.NewRequest(method, "/", r))
+	return rec
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	r := RetryPolicy{}
+	r.setDefaults()
+	if r.MaxAttempts != 2 || len(r.RetryOn) != 3 || r.BudgetRatio != 0.2 || r.MinRetries != 10 {
+		t.Errorf("unexpected defaults: %+v", r)
+	}
+	h := HedgePolicy{}
+	h.setDefaults()
+	if h.Delay != 100*time.Millisecond || h.MaxHedges != 1 {
+		t.Errorf("unexpected defaults: %+v", h)
+	}
+}
+
+func TestProxy_Retry(t *testing.T) {
+	badSrv, badCount := newCountServer(t, http.StatusServiceUnavailable, 0, "bad")
+	goodSrv, goodCount := newCountServer(t, http.StatusOK, 0, "good")
+	bad := newServerBackend(t, badSrv.URL)
+	good := newServerBackend(t, goodSrv.URL)
+
+	p, err := NewProxy(NewRoundRobin([]*Backend{bad, good}), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Retry Idempotent Request", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			rec := doRequest(p, http.MethodPut, "-body")
+			if rec.Code != http.StatusOK || rec.Body.String() != "good-body" {
+				t.Fatalf("test %d: expected good response, got %d %s", i, rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("X-Backend") != "good" {
+				t.Fatalf("test %d: unexpected header %v", i, rec.Header())
+			}
+		}
+		// the round-robin balancer selects the bad backend first for each request
+		if badCount.Load() != 4 || goodCount.Load() != 4 {
+			t.Errorf("expected bad=4, good=4, got bad=%d, good=%d", badCount.Load(), goodCount.Load())
+		}
+	})
+
+	t.Run("No Retry For Non-Idempotent Request", func(t *testing.T) {
+		codes := map[int]int{}
+		for i := 0; i < 4; i++ {
+			codes[doRequest(p, http.MethodPost, "").Code]++
+		}
+		if codes[http.StatusServiceUnavailable] != 2 || codes[http.StatusOK] != 2 {
+			t.Errorf("unexpected status codes: %v", codes)
+		}
+	})
+
+	t.Run("All Attempts Failed", func(t *testing.T) {
+		p, _ := NewProxy(NewRoundRobin([]*Backend{bad}), WithRetry(RetryPolicy{MaxAttempts: 3}))
+		before := badCount.Load()
+		rec := doRequest(p, http.MethodGet, "")
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "bad" {
+			t.Errorf("expected the last failed response, got %d %s", rec.Code, rec.Body.String())
+		}
+		if badCount.Load()-before != 3 {
+			t.Errorf("expected 3 attempts, got %d", badCount.Load()-before)
+		}
+	})
+
+	t.Run("Retry Not Started", func(t *testing.T) {
+		var backend *Backend
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			backend.SetHealthy(false) // no backend is available for the retry
+			w.Header().Set("X-Backend", "bad")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("bad"))
+		}))
+		defer srv.Close()
+		backend = newServerBackend(t, srv.URL)
+		p, _ := NewProxy(NewRoundRobin([]*Backend{backend}), WithRetry(RetryPolicy{MaxAttempts: 2}))
+		rec := doRequest(p, http.MethodGet, "")
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "bad" || rec.Header().Get("X-Backend") != "bad" {
+			t.Errorf("expected the discarded response, got %d %s %v", rec.Code, rec.Body.String(), rec.Header())
+		}
+	})
+
+	t.Run("Budget Exhausted", func(t *testing.T) {
+		p, _ := NewProxy(NewRoundRobin([]*Backend{bad, good}), WithRetry(RetryPolicy{MinRetries: 1, BudgetRatio: 0.01}))
+		codes := map[int]int{}
+		for i := 0; i < 6; i++ {
+			codes[doRequest(p, http.MethodGet, "").Code]++
+		}
+		// only 1 retry is allowed, the requests without retry alternate between bad and good backends
+		if codes[http.StatusServiceUnavailable] != 3 || codes[http.StatusOK] != 3 {
+			t.Errorf("unexpected status codes: %v", codes)
+		}
+	})
+}
+
+func TestProxy_Hedging(t *testing.T) {
+	slowSrv, slowCount := newCountServer(t, http.StatusOK, time.Second, "slow")
+	fastSrv, fastCount := newCountServer(t, http.StatusOK, 0, "fast")
+	slow := newServerBackend(t, slowSrv.URL)
+	fast := newServerBackend(t, fastSrv.URL)
+
+	p, err := NewProxy(NewRoundRobin([]*Backend{slow, fast}), WithHedging(HedgePolicy{Delay: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		rec := doRequest(p, http.MethodGet, "")
+		if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+			t.Fatalf("test %d: expected fast response, got %d %s", i, rec.Code, rec.Body.String())
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatalf("test %d: expected hedged request to return early", i)
+		}
+	}
+	// the round-robin balancer selects the slow backend first for each request
+	if slowCount.Load() != 4 || fastCount.Load() != 4 {
+		t.Errorf("expected slow=4, fast=4, got slow=%d, fast=%d", slowCount.Load(), fastCount.Load())
+	}
+}
+
+func TestBufferBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	body, ok := bufferBody(r)
+	if !ok || string(body) != "hello" {
+		t.Fatalf("expected buffered body, got %q %v", body, ok)
+	}
+	data, _ := io.ReadAll(r.Body)
+	if string(data) != "hello" {
+		t.Errorf("expected body restored, got %q", data)
+	}
+
+	large := strings.Repeat("a", maxReplayBodySize+10)
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(large))
+	r.ContentLength = -1
+	if _, ok = bufferBody(r); ok {
+		t.Fatal("expected large body not buffered")
+	}
+	data, _ = io.ReadAll(r.Body)
+	if len(data) != len(large) {
+		t.Errorf("expected body restored, got %d bytes", len(data))
+	}
+
+	if !isIdempotent(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("expected GET to be idempotent")
+	}
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	if isIdempotent(r) {
+		t.Error("expected POST not to be idempotent")
+	}
+	r.Header.Set("Idempotency-Key", "k1")
+	if !isIdempotent(r) {
+		t.Error("expected POST with Idempotency-Key to be idempotent")
+	}
+}
+
+func TestProxy_Mirror(t *testing.T) {
+	primarySrv, _ := newCountServer(t, http.StatusOK, 0, "v1")
+	canarySrv, canaryCount := newCountServer(t, http.StatusOK, 0, "v2")
+
+	p, err := NewProxy(NewRoundRobin([]*Backend{newServerBackend(t, primarySrv.URL)}),
+		WithMirror(MirrorPolicy{Target: canarySrv.URL, Percent: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := doRequest(p, http.MethodPost, "-data")
+		if rec.Code != http.StatusOK || rec.Body.String() != "v1-data" {
+			t.Fatalf("test %d: expected primary response, got %d %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	for i := 0; i < 100 && p.MirrorStats().Diffs < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := p.MirrorStats()
+	if stats.Mirrored != 3 || stats.Diffs != 3 || canaryCount.Load() != 3 {
+		t.Errorf("unexpected mirror stats: %+v, canary requests %d", stats, canaryCount.Load())
+	}
+
+	if _, err = NewProxy(&mockBalancer{}, WithMirror(MirrorPolicy{Target: "://invalid"})); err == nil {
+		t.Error("expected error for invalid mirror target")
+	}
+	p, _ = NewProxy(&mockBalancer{}, WithMirror(MirrorPolicy{Target: canarySrv.URL, Percent: 0}))
+	if p.mirror.sampled() {
+		t.Error("expected no request sampled")
+	}
+}
@@ -0,0 +1,112 @@
+package proxykit
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderRewrite defined the headers to set and remove.
+type HeaderRewrite struct {
+	Set    map[string]string `json:"set" yaml:"set"`       // header name -> value, overwrite the existing value
+	Remove []string          `json:"remove" yaml:"remove"` // header names
+}
+
+func (h *HeaderRewrite) isEmpty() bool {
+	return len(h.Set) == 0 && len(h.Remove) == 0
+}
+
+func (h *HeaderRewrite) apply(header http.Header) {
+	for _, name := range h.Remove {
+		header.Del(name)
+	}
+	for name, value := range h.Set {
+		header.Set(name, value)
+	}
+}
+
+// RewriteConfig defined the rewrite rules of path and headers, by default the route prefix
+// is stripped from the path of the request forwarded to the backend.
+type RewriteConfig struct {
+	KeepPrefix      bool          `json:"keepPrefix" yaml:"keepPrefix"`           // keep the route prefix in the path
+	AddPrefix       string        `json:"addPrefix" yaml:"addPrefix"`             // prefix added to the path after stripping, e.g. /api/v1
+	RequestHeaders  HeaderRewrite `json:"requestHeaders" yaml:"requestHeaders"`   // headers of the request forwarded to the backend
+	ResponseHeaders HeaderRewrite `json:"responseHeaders" yaml:"responseHeaders"` // headers of the response returned to the client
+}
+
+func (c *RewriteConfig) isEmpty() bool {
+	return !c.KeepPrefix && c.AddPrefix == "" && c.RequestHeaders.isEmpty() && c.ResponseHeaders.isEmpty()
+}
+
+// rewriter rewrites the request before it is forwarded, the backend strips the route prefix
+// from the path, so the path rewrite is relative to the route prefix.
+type rewriter struct {
+	prefix string // route prefix without the trailing slash
+	config RewriteConfig
+}
+
+func newRewriter(prefixPath string, config RewriteConfig) *rewriter {
+	if config.AddPrefix != "" {
+		if !strings.HasPrefix(config.AddPrefix, "/") {
+			config.AddPrefix = "/" + config.AddPrefix
+		}
+		config.AddPrefix = strings.TrimSuffix(config.AddPrefix, "/")
+	}
+	return &rewriter{
+		prefix: strings.TrimSuffix(prefixPath, "/"),
+		config: config,
+	}
+}
+
+func (rw *rewriter) rewriteRequest(r *http.Request) *http.Request {
+	req := r.Clone(r.Context())
+	if rw.config.KeepPrefix || rw.config.AddPrefix != "" {
+		rest := strings.TrimPrefix(req.URL.Path, rw.prefix)
+		if rw.config.KeepPrefix {
+			rest = rw.prefix + rest
+		}
+		req.URL.Path = rw.prefix + rw.config.AddPrefix + rest
+		req.URL.RawPath = ""
+	}
+	rw.config.RequestHeaders.apply(req.Header)
+	return req
+}
+
+func (rw *rewriter) wrapWriter(w http.ResponseWriter) http.ResponseWriter {
+	if rw.config.ResponseHeaders.isEmpty() {
+		return w
+	}
+	return &rewriteWriter{ResponseWriter: w, headers: &rw.config.ResponseHeaders}
+}
+
+// rewriteWriter rewrites the response headers before they are written.
+type rewriteWriter struct {
+	http.ResponseWriter
+	headers     *HeaderRewrite
+	wroteHeader bool
+}
+
+func (w *rewriteWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= http.StatusOK {
+		w.wroteHeader = true
+		w.headers.apply(w.ResponseWriter.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rewriteWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *rewriteWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter, used by http.ResponseController.
+func (w *rewriteWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
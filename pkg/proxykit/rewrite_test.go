@@ -0,0 +1,67 @@
+package proxykit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxy_Rewrite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Debug", "true")
+		w.Header().Set("X-Cookie", r.Header.Get("Cookie"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	requestHeaders := HeaderRewrite{Set: map[string]string{"x-token": "abc"}, Remove: []string{"Cookie"}}
+	responseHeaders := HeaderRewrite{Set: map[string]string{"X-Gateway": "sponge"}, Remove: []string{"X-Debug"}}
+
+	testData := []struct {
+		name     string
+		config   RewriteConfig
+		wantPath string
+	}{
+		{"Strip Prefix", RewriteConfig{RequestHeaders: requestHeaders}, "/users/1"},
+		{"Keep Prefix", RewriteConfig{KeepPrefix: true, RequestHeaders: requestHeaders}, "/api/users/1"},
+		{"Add Prefix", RewriteConfig{AddPrefix: "v1/", RequestHeaders: requestHeaders}, "/v1/users/1"},
+		{"Keep And Add Prefix", RewriteConfig{KeepPrefix: true, AddPrefix: "/v1", ResponseHeaders: responseHeaders}, "/v1/api/users/1"},
+	}
+
+	for _, tt := range testData {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewRouteManager()
+			backends, _ := ParseBackends("/api/", []string{srv.URL})
+			route, err := m.AddRoute("/api/", NewRoundRobin(backends), WithRewrite(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+			req.Header.Set("Cookie", "session=1")
+			rec := httptest.NewRecorder()
+			route.Proxy.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("X-Path"); got != tt.wantPath {
+				t.Errorf("expected path %s, got %s", tt.wantPath, got)
+			}
+			if req.URL.Path != "/api/users/1" || req.Header.Get("Cookie") == "" {
+				t.Error("expected original request not modified")
+			}
+			if tt.config.RequestHeaders.isEmpty() {
+				if rec.Header().Get("X-Debug") != "" || rec.Header().Get("X-Gateway") != "sponge" {
+					t.Errorf("unexpected response headers: %v", rec.Header())
+				}
+				return
+			}
+			if rec.Header().Get("X-Token") != "abc" || rec.Header().Get("X-Cookie") != "" {
+				t.Errorf("unexpected request headers forwarded: %v", rec.Header())
+			}
+			if rec.Header().Get("X-Debug") != "true" {
+				t.Errorf("expected response header kept: %v", rec.Header())
+			}
+		})
+	}
+}
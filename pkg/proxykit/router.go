@@ -14,6 +14,7 @@ type ManagementRequest struct {
 	PrefixPath  string            `json:"prefixPath"`
 	Targets     []string          `json:"targets"`
 	HealthCheck HealthCheckConfig `json:"healthCheck"`
+	Weights     map[string]int    `json:"weights"` // optional, target -> weight, used by weighted balancers
 }
 
 // Route holds all components for a specific routing rule.
@@ -39,13 +40,8 @@ func NewRouteManager() *RouteManager {
 }
 
 // AddRoute adds a new routing rule and configures its proxy to strip the given prefix.
-func (m *RouteManager) AddRoute(prefixPath string, balancer Balancer) (*Route, error) {
-	if !strings.HasPrefix(prefixPath, "/") {
-		prefixPath = "/" + prefixPath
-	}
-	if !strings.HasSuffix(prefixPath, "/") {
-		prefixPath = prefixPath + "/"
-	}
+func (m *RouteManager) AddRoute(prefixPath string, balancer Balancer, opts ...ProxyOption) (*Route, error) {
+	prefixPath = NormalizePrefixPath(prefixPath)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -54,21 +50,70 @@ func (m *RouteManager) AddRoute(prefixPath string, balancer Balancer) (*Route, e
 		return nil, fmt.Errorf("route for prefix '%s' already exists", prefixPath)
 	}
 
-	proxy, err := NewProxy(balancer)
+	route, err := NewRoute(prefixPath, balancer, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create proxy for '%s': %w", prefixPath, err)
+		return nil, err
+	}
+
+	m.routes[prefixPath] = route
+	log.Printf("[Manager] added new route for prefix: %s", prefixPath)
+	return route, nil
+}
+
+// SetRoute adds a routing rule or replaces the existing one, the replaced route is returned,
+// the caller is responsible for stopping the health checks of its backends.
+func (m *RouteManager) SetRoute(prefixPath string, balancer Balancer, opts ...ProxyOption) (route *Route, old *Route, err error) {
+	route, err = NewRoute(prefixPath, balancer, opts...)
+	if err != nil {
+		return nil, nil, err
 	}
+	return route, m.PutRoute(route), nil
+}
 
-	route := &Route{
+// PutRoute adds the route created by NewRoute or replaces the existing one, the replaced route
+// is returned, the caller is responsible for stopping the health checks of its backends.
+func (m *RouteManager) PutRoute(route *Route) *Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := m.routes[route.PrefixPath]
+	m.routes[route.PrefixPath] = route
+	if old != nil {
+		log.Printf("[Manager] replaced route for prefix: %s", route.PrefixPath)
+	} else {
+		log.Printf("[Manager] added new route for prefix: %s", route.PrefixPath)
+	}
+	return old
+}
+
+// RemoveRoute removes a routing rule, the removed route is returned, the caller is responsible
+// for stopping the health checks of its backends.
+func (m *RouteManager) RemoveRoute(prefixPath string) (*Route, bool) {
+	prefixPath = NormalizePrefixPath(prefixPath)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	route, exists := m.routes[prefixPath]
+	if exists {
+		delete(m.routes, prefixPath)
+		log.Printf("[Manager] removed route for prefix: %s", prefixPath)
+	}
+	return route, exists
+}
+
+// NewRoute creates a routing rule without adding it to the manager, it is added by PutRoute,
+// so that the routes can be created and validated before any of them is changed.
+func NewRoute(prefixPath string, balancer Balancer, opts ...ProxyOption) (*Route, error) {
+	prefixPath = NormalizePrefixPath(prefixPath)
+	proxy, err := NewProxy(balancer, append([]ProxyOption{withPrefixPath(prefixPath)}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create proxy for '%s': %w", prefixPath, err)
+	}
+	return &Route{
 		PrefixPath: prefixPath,
 		Backends:   balancer.GetBackends(),
 		Balancer:   balancer,
 		Proxy:      proxy,
-	}
-
-	m.routes[prefixPath] = route
-	log.Printf("[Manager] added new route for prefix: %s", prefixPath)
-	return route, nil
+	}, nil
 }
 
 // GetRoute safely retrieves a route.
@@ -109,6 +154,7 @@ func (m *RouteManager) HandleAddBackends(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 		backend := NewBackend(req.PrefixPath, targetURL)
+		backend.SetWeight(req.Weights[targetStr])
 		route.Backends = append(route.Backends, backend)
 		route.Balancer.AddBackend(backend)
 		StartHealthChecks([]*Backend{backend}, req.HealthCheck)
@@ -143,6 +189,7 @@ func (m *RouteManager) HandleRemoveBackends(w http.ResponseWriter, r *http.Reque
 		if containsString(req.Targets, backend.URL.String()) {
 			backend.StopHealthCheck()
 			route.Balancer.RemoveBackend(backend)
+			route.Proxy.removeBreaker(backend)
 			removedCount++
 			log.Printf("[Manager] removed backend '%s' from route '%s'", backend.URL.String(), route.PrefixPath)
 		} else {
@@ -171,7 +218,7 @@ func (m *RouteManager) HandleGetBackend(w http.ResponseWriter, r *http.Request)
 	defer route.mu.RUnlock()
 	for _, b := range route.Backends {
 		if b.URL.String() == target {
-			_ = json.NewEncoder(w).Encode(map[string]interface{}{"target": target, "healthy": b.IsHealthy()})
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"target": target, "healthy": b.IsHealthy(), "ejected": b.IsEjected()})
 			return
 		}
 	}
@@ -196,10 +243,11 @@ func (m *RouteManager) HandleListBackends(w http.ResponseWriter, r *http.Request
 	type targetStatus struct {
 		Target  string `json:"target"`
 		Healthy bool   `json:"healthy"`
+		Ejected bool   `json:"ejected"`
 	}
 	var statuses []targetStatus
 	for _, b := range route.Backends {
-		statuses = append(statuses, targetStatus{Target: b.URL.String(), Healthy: b.IsHealthy()})
+		statuses = append(statuses, targetStatus{Target: b.URL.String(), Healthy: b.IsHealthy(), Ejected: b.IsEjected()})
 	}
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{"prefixPath": prefixPath, "targets": statuses})
 }
@@ -223,11 +271,16 @@ func containsString(slice []string, str string) bool {
 }
 
 func AnyRelativePath(prefixPath string) string {
+	return NormalizePrefixPath(prefixPath) + "*path"
+}
+
+// NormalizePrefixPath returns the prefix path with the leading and trailing slashes.
+func NormalizePrefixPath(prefixPath string) string {
 	if !strings.HasPrefix(prefixPath, "/") {
 		prefixPath = "/" + prefixPath
 	}
 	if !strings.HasSuffix(prefixPath, "/") {
 		prefixPath = prefixPath + "/"
 	}
-	return prefixPath + "*path"
+	return prefixPath
 }
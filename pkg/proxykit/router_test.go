@@ -125,6 +125,55 @@ func TestRouteManager_AddRoute(t *testing.T) {
 	})
 }
 
+func TestRouteManager_SetRemoveRoute(t *testing.T) {
+	m := NewRouteManager()
+	b1 := newMockRouterBalancer()
+	route, old, err := m.SetRoute("/api", b1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != nil || route.PrefixPath != "/api/" {
+		t.Fatalf("unexpected route: %v, old: %v", route, old)
+	}
+
+	b2 := newMockRouterBalancer()
+	route2, old, err := m.SetRoute("/api/", b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != route || route2.Balancer != b2 {
+		t.Fatal("expected route replaced")
+	}
+	if r, _ := m.GetRoute("/api/"); r != route2 {
+		t.Fatal("expected new route")
+	}
+
+	if _, _, err = m.SetRoute("/nil-b", nil); err == nil {
+		t.Fatal("expected an error for nil balancer, got nil")
+	}
+
+	// the route created by NewRoute is not added until PutRoute
+	route3, err := NewRoute("api", newMockRouterBalancer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := m.GetRoute("/api/"); r != route2 {
+		t.Fatal("expected route not replaced before PutRoute")
+	}
+	if old = m.PutRoute(route3); old != route2 {
+		t.Fatal("expected route replaced by PutRoute")
+	}
+	route2 = route3
+
+	removed, ok := m.RemoveRoute("api")
+	if !ok || removed != route2 {
+		t.Fatal("expected route removed")
+	}
+	if _, ok = m.RemoveRoute("/api/"); ok {
+		t.Fatal("expected route not exist")
+	}
+}
+
 func TestRouteManager_GetRoute(t *testing.T) {
 	t.Parallel()
 	m := NewRouteManager()
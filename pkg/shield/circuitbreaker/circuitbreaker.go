@@ -14,3 +14,33 @@ type CircuitBreaker interface {
 	MarkSuccess()
 	MarkFailed()
 }
+
+// DoneFunc reports the result of the request allowed by the circuit breaker.
+type DoneFunc func(success bool)
+
+// doneBreaker is the circuit breaker which reports the result of each allowed request
+// by the done function, e.g. StateMachineBreaker.
+type doneBreaker interface {
+	AllowDone() (DoneFunc, error)
+}
+
+// Acquire asks the circuit breaker whether the request is allowed, the result of the allowed
+// request is reported by calling done once. If the breaker does not report the result per request,
+// e.g. the sre breaker, the rejected request is marked failed to keep the drop ratio higher.
+func Acquire(b CircuitBreaker) (done DoneFunc, err error) {
+	if db, ok := b.(doneBreaker); ok {
+		return db.AllowDone()
+	}
+	if err = b.Allow(); err != nil {
+		// NOTE: when client reject request locally, keep adding counter let the drop ratio higher.
+		b.MarkFailed()
+		return nil, err
+	}
+	return func(success bool) {
+		if success {
+			b.MarkSuccess()
+		} else {
+			b.MarkFailed()
+		}
+	}, nil
+}
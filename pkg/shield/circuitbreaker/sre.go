@@ -34,6 +34,26 @@ type options struct {
 	request int64
 	bucket  int
 	window  time.Duration
+
+	// options of state machine breaker
+	name           string
+	openTimeout    time.Duration
+	halfOpenProbes int
+	probeTimeout   time.Duration
+	stateMachine   bool
+	onStateChange  func(name string, from int32, to int32)
+}
+
+func defaultOptions() options {
+	return options{
+		success:        0.6,
+		request:        100,
+		bucket:         10,
+		window:         3 * time.Second,
+		openTimeout:    5 * time.Second,
+		halfOpenProbes: 5,
+		probeTimeout:   10 * time.Second,
+	}
 }
 
 // WithSuccess with the K = 1 / Success value of sre breaker, default success is 0.5
@@ -81,17 +101,15 @@ type Breaker struct {
 	state int32
 }
 
-// NewBreaker return a sreBresker with options
+// NewBreaker return a sreBresker with options, or a StateMachineBreaker if WithStateMachine is set.
 func NewBreaker(opts ...Option) CircuitBreaker {
-	opt := options{
-		success: 0.6,
-		request: 100,
-		bucket:  10,
-		window:  3 * time.Second,
-	}
+	opt := defaultOptions()
 	for _, o := range opts {
 		o(&opt)
 	}
+	if opt.stateMachine {
+		return NewStateMachineBreaker(opts...)
+	}
 	counterOpts := window.RollingCounterOpts{
 		Size:           opt.bucket,
 		BucketDuration: time.Duration(int64(opt.window) / int64(opt.bucket)),
@@ -107,7 +125,11 @@ func NewBreaker(opts ...Option) CircuitBreaker {
 }
 
 func (b *Breaker) summary() (success int64, total int64) {
-	b.stat.Reduce(func(iterator window.Iterator) float64 {
+	return summary(b.stat)
+}
+
+func summary(stat window.RollingCounter) (success int64, total int64) {
+	stat.Reduce(func(iterator window.Iterator) float64 {
 		for iterator.Next() {
 			bucket := iterator.Bucket()
 			total += bucket.Count
@@ -140,6 +162,12 @@ func (b *Breaker) Allow() error {
 	return nil
 }
 
+// State returns the current state, StateClosed or StateOpen, the state is open when
+// the breaker starts to drop requests.
+func (b *Breaker) State() int32 {
+	return atomic.LoadInt32(&b.state)
+}
+
 // MarkSuccess mark request is success.
 func (b *Breaker) MarkSuccess() {
 	b.stat.Add(1)
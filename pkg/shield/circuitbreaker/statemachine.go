@@ -0,0 +1,289 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/go-dev-frame/sponge/pkg/shield/window"
+)
+
+// StateHalfOpen when circuit breaker half-open, a limited number of probe requests are allowed,
+// if all the probe requests succeed, the state is reset to closed, if any probe request fails,
+// the state is reset to open.
+const StateHalfOpen int32 = 2
+
+var (
+	_ CircuitBreaker = &StateMachineBreaker{}
+
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Current state of the circuit breaker, the value of the current state is 1, the others are 0.",
+	}, []string{"name", "state"})
+	registerOnce sync.Once
+)
+
+// StateName returns the name of the state, "open", "closed" or "half-open".
+func StateName(state int32) string {
+	switch state {
+	case StateOpen:
+		return "open"
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// WithName set the name of the breaker, e.g. the name of the downstream service, the name is used
+// as the label of Prometheus gauge "circuit_breaker_state", the gauge is reported only if the name
+// is not empty, only used by StateMachineBreaker.
+func WithName(name string) Option {
+	return func(c *options) {
+		c.name = name
+	}
+}
+
+// WithOpenTimeout set the duration of the open state before changing to half-open,
+// default 5s, only used by StateMachineBreaker.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(c *options) {
+		if d > 0 {
+			c.openTimeout = d
+		}
+	}
+}
+
+// WithHalfOpenProbes set the number of probe requests allowed in half-open state, the state
+// changes to closed after all the probe requests succeed, default 5, only used by StateMachineBreaker.
+func WithHalfOpenProbes(n int) Option {
+	return func(c *options) {
+		if n > 0 {
+			c.halfOpenProbes = n
+		}
+	}
+}
+
+// WithProbeTimeout set the max duration of a batch of probe requests in half-open state, if the
+// results of the probe requests are not reported within the duration, e.g. the requests are lost,
+// they are ignored and a new batch of probe requests is allowed, default 10s, only used by StateMachineBreaker.
+func WithProbeTimeout(d time.Duration) Option {
+	return func(c *options) {
+		if d > 0 {
+			c.probeTimeout = d
+		}
+	}
+}
+
+// WithStateMachine make NewBreaker return a StateMachineBreaker instead of the sre breaker, so that
+// the state machine breaker can be used by the gin middleware and grpc interceptors via options.
+func WithStateMachine() Option {
+	return func(c *options) {
+		c.stateMachine = true
+	}
+}
+
+// WithStateChange set the callback called when the state changes, only used by StateMachineBreaker.
+func WithStateChange(fn func(name string, from int32, to int32)) Option {
+	return func(c *options) {
+		c.onStateChange = fn
+	}
+}
+
+// StateMachineBreaker is a circuit breaker with explicit closed, open and half-open states.
+// In closed state, the breaker opens if the number of requests in the window reaches the minimum
+// and the success ratio is lower than the setting. In open state, all requests are rejected until
+// the open timeout elapses, then a limited number of probe requests are allowed in half-open state.
+//
+// The result of a request should be reported by the done function returned by AllowDone, which is
+// used by Acquire, the results of the requests allowed in a previous state or a timed out batch of
+// probes are ignored. MarkSuccess and MarkFailed can't tell which request the result belongs to,
+// the MarkFailed in half-open state is ignored only if there is no probe request in flight.
+type StateMachineBreaker struct {
+	mu           sync.Mutex
+	opts         options
+	counterOpts  window.RollingCounterOpts
+	stat         window.RollingCounter
+	state        int32
+	generation   uint64 // increased when the state changes or a new batch of probes starts
+	openedAt     time.Time
+	probeStartAt time.Time // start time of the batch of probe requests
+	probes       int       // probe requests allowed in half-open state
+	probeSuccess int       // succeeded probe requests in half-open state
+}
+
+// NewStateMachineBreaker returns a state machine breaker with options, the options WithSuccess,
+// WithRequest, WithWindow and WithBucket have the same meaning as the sre breaker.
+func NewStateMachineBreaker(opts ...Option) *StateMachineBreaker {
+	opt := defaultOptions()
+	for _, o := range opts {
+		o(&opt)
+	}
+	counterOpts := window.RollingCounterOpts{
+		Size:           opt.bucket,
+		BucketDuration: time.Duration(int64(opt.window) / int64(opt.bucket)),
+	}
+	b := &StateMachineBreaker{
+		opts:        opt,
+		counterOpts: counterOpts,
+		stat:        window.NewRollingCounter(counterOpts),
+		state:       StateClosed,
+	}
+	if opt.name != "" {
+		registerOnce.Do(func() {
+			_ = prometheus.Register(stateGauge)
+		})
+		b.reportState()
+	}
+	return b
+}
+
+// State returns the current state, StateClosed, StateOpen or StateHalfOpen.
+func (b *StateMachineBreaker) State() int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.opts.openTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow request if error returns nil.
+func (b *StateMachineBreaker) Allow() error {
+	_, err := b.allow()
+	return err
+}
+
+// AllowDone allows the request if error returns nil, the result of the allowed request is
+// reported by calling done once, the rejected request does not need to be reported.
+func (b *StateMachineBreaker) AllowDone() (DoneFunc, error) {
+	generation, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(success bool) {
+		b.mark(generation, success, true)
+	}, nil
+}
+
+// allow returns the generation of the state in which the request is allowed.
+func (b *StateMachineBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.opts.openTimeout {
+		b.setState(StateHalfOpen)
+	}
+
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = ErrNotAllowed
+	case StateHalfOpen:
+		if b.probes >= b.opts.halfOpenProbes && time.Since(b.probeStartAt) >= b.opts.probeTimeout {
+			b.startProbes() // the results of the previous probes are lost
+		}
+		if b.probes < b.opts.halfOpenProbes {
+			b.probes++
+		} else {
+			err = ErrNotAllowed
+		}
+	}
+	generation, to := b.generation, b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return generation, err
+}
+
+// MarkSuccess mark request is success.
+func (b *StateMachineBreaker) MarkSuccess() {
+	b.mark(0, true, false)
+}
+
+// MarkFailed mark request is failed, in half-open state, it is ignored if there is no probe
+// request in flight, e.g. called after the request is rejected by Allow.
+func (b *StateMachineBreaker) MarkFailed() {
+	b.mark(0, false, false)
+}
+
+// mark reports the result of a request, if checkGeneration is true, the result of the request
+// allowed in another generation is ignored.
+func (b *StateMachineBreaker) mark(generation uint64, success bool, checkGeneration bool) {
+	b.mu.Lock()
+	if checkGeneration && generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	from := b.state
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.stat.Add(1)
+			break
+		}
+		b.stat.Add(0)
+		accepts, total := summary(b.stat)
+		if total >= b.opts.request && float64(accepts) < float64(total)*b.opts.success {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if success {
+			b.probeSuccess++
+			if b.probeSuccess >= b.opts.halfOpenProbes {
+				b.setState(StateClosed)
+			}
+		} else if b.probes > b.probeSuccess {
+			b.setState(StateOpen)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// startProbes starts a new batch of probe requests, it must be called with the lock held.
+func (b *StateMachineBreaker) startProbes() {
+	b.generation++
+	b.probes, b.probeSuccess = 0, 0
+	b.probeStartAt = time.Now()
+}
+
+// setState changes the state, it must be called with the lock held.
+func (b *StateMachineBreaker) setState(state int32) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	b.generation++
+	switch state {
+	case StateOpen:
+		b.openedAt = time.Now()
+	case StateHalfOpen:
+		b.startProbes()
+	case StateClosed:
+		b.stat = window.NewRollingCounter(b.counterOpts)
+	}
+	if b.opts.name != "" {
+		b.reportState()
+	}
+}
+
+func (b *StateMachineBreaker) reportState() {
+	for _, s := range []int32{StateClosed, StateOpen, StateHalfOpen} {
+		value := 0.0
+		if s == b.state {
+			value = 1
+		}
+		stateGauge.WithLabelValues(b.opts.name, StateName(s)).Set(value)
+	}
+}
+
+func (b *StateMachineBreaker) notify(from int32, to int32) {
+	if from != to && b.opts.onStateChange != nil {
+		b.opts.onStateChange(b.opts.name, from, to)
+	}
+}
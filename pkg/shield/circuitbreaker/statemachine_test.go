@@ -0,0 +1,170 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateMachineBreaker(t *testing.T) {
+	type change struct{ from, to int32 }
+	var (
+		mu      sync.Mutex
+		changes []change
+	)
+	b := NewStateMachineBreaker(
+		WithName("user-service"),
+		WithRequest(10),
+		WithSuccess(0.5),
+		WithOpenTimeout(100*time.Millisecond),
+		WithHalfOpenProbes(2),
+		WithStateChange(func(name string, from int32, to int32) {
+			assert.Equal(t, "user-service", name)
+			mu.Lock()
+			changes = append(changes, change{from, to})
+			mu.Unlock()
+		}),
+	)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, 1.0, testutil.ToFloat64(stateGauge.WithLabelValues("user-service", "closed")))
+
+	// closed -> open
+	markSMSuccess(b, 4)
+	markSMFailed(b, 5)
+	assert.Equal(t, StateClosed, b.State()) // less than 10 requests
+	markSMFailed(b, 1)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, ErrNotAllowed, b.Allow())
+	b.MarkFailed() // ignored after rejected
+	assert.Equal(t, 1.0, testutil.ToFloat64(stateGauge.WithLabelValues("user-service", "open")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(stateGauge.WithLabelValues("user-service", "closed")))
+
+	// open -> half-open -> open
+	time.Sleep(120 * time.Millisecond)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.NoError(t, b.Allow())
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, ErrNotAllowed, b.Allow()) // only 2 probes
+	b.MarkFailed()                            // probe failed
+	assert.Equal(t, StateOpen, b.State())
+
+	// open -> half-open -> closed
+	time.Sleep(120 * time.Millisecond)
+	assert.NoError(t, b.Allow())
+	assert.NoError(t, b.Allow())
+	b.MarkSuccess()
+	assert.Equal(t, StateHalfOpen, b.State())
+	b.MarkSuccess()
+	assert.Equal(t, StateClosed, b.State())
+	assert.NoError(t, b.Allow())
+
+	// the statistics are reset after closed
+	markSMFailed(b, 9)
+	assert.Equal(t, StateClosed, b.State())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []change{
+		{StateClosed, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateClosed},
+	}, changes)
+}
+
+func TestStateMachineBreaker_AllowDone(t *testing.T) {
+	b := NewStateMachineBreaker(
+		WithRequest(2),
+		WithSuccess(0.5),
+		WithOpenTimeout(50*time.Millisecond),
+		WithHalfOpenProbes(2),
+		WithProbeTimeout(100*time.Millisecond),
+	)
+
+	// the requests allowed in closed state are reported after the breaker opens
+	done1, err := b.AllowDone()
+	assert.NoError(t, err)
+	done2, _ := b.AllowDone()
+	markSMFailed(b, 2)
+	assert.Equal(t, StateOpen, b.State())
+	_, err = b.AllowDone()
+	assert.Equal(t, ErrNotAllowed, err)
+	time.Sleep(60 * time.Millisecond)
+	probe1, err := b.AllowDone()
+	assert.NoError(t, err)
+	assert.Equal(t, StateHalfOpen, b.State())
+	done1(false) // ignored, allowed in closed state
+	assert.Equal(t, StateHalfOpen, b.State())
+	done2(true) // ignored
+	probe2, _ := b.AllowDone()
+	_, err = b.AllowDone()
+	assert.Equal(t, ErrNotAllowed, err) // only 2 probes
+	probe1(true)
+	probe2(true)
+	assert.Equal(t, StateClosed, b.State())
+
+	// the probes are lost, a new batch of probes is allowed after the probe timeout
+	markSMFailed(b, 2)
+	time.Sleep(60 * time.Millisecond)
+	lost, err := b.AllowDone()
+	assert.NoError(t, err)
+	_, _ = b.AllowDone()
+	_, err = b.AllowDone()
+	assert.Equal(t, ErrNotAllowed, err)
+	time.Sleep(110 * time.Millisecond)
+	probe1, err = b.AllowDone()
+	assert.NoError(t, err)
+	lost(false) // ignored, the batch of probes timed out
+	assert.Equal(t, StateHalfOpen, b.State())
+	probe1(false)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestAcquire(t *testing.T) {
+	// state machine breaker, the rejected request is not reported
+	b := NewBreaker(WithStateMachine(), WithRequest(1), WithOpenTimeout(time.Hour))
+	sm, ok := b.(*StateMachineBreaker)
+	assert.True(t, ok)
+	done, err := Acquire(b)
+	assert.NoError(t, err)
+	done(false)
+	assert.Equal(t, StateOpen, sm.State())
+	_, err = Acquire(b)
+	assert.Equal(t, ErrNotAllowed, err)
+
+	// sre breaker
+	b = NewBreaker(WithRequest(1))
+	done, err = Acquire(b)
+	assert.NoError(t, err)
+	done(true)
+	_, total := b.(*Breaker).summary()
+	assert.Equal(t, int64(1), total)
+}
+
+func TestStateName(t *testing.T) {
+	assert.Equal(t, "open", StateName(StateOpen))
+	assert.Equal(t, "closed", StateName(StateClosed))
+	assert.Equal(t, "half-open", StateName(StateHalfOpen))
+	assert.Equal(t, "unknown", StateName(-1))
+}
+
+func TestBreaker_State(t *testing.T) {
+	b := NewBreaker().(*Breaker)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func markSMSuccess(b *StateMachineBreaker, count int) {
+	for i := 0; i < count; i++ {
+		b.MarkSuccess()
+	}
+}
+
+func markSMFailed(b *StateMachineBreaker, count int) {
+	for i := 0; i < count; i++ {
+		b.MarkFailed()
+	}
+}
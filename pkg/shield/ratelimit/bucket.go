@@ -0,0 +1,243 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	_ Limiter    = (*KeyedLimiter)(nil)
+	_ KeyLimiter = (*KeyedLimiter)(nil)
+)
+
+// KeyedOption set the options of token bucket and leaky bucket limiters.
+type KeyedOption func(*keyedOptions)
+
+type keyedOptions struct {
+	maxKeys int
+}
+
+func defaultKeyedOptions() *keyedOptions {
+	return &keyedOptions{
+		maxKeys: 10000,
+	}
+}
+
+func (o *keyedOptions) apply(opts ...KeyedOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithMaxKeys set the max number of keys kept in memory, the least recently used keys
+// are evicted when the number of keys exceeds the limit, default 10000.
+func WithMaxKeys(n int) KeyedOption {
+	return func(o *keyedOptions) {
+		if n > 0 {
+			o.maxKeys = n
+		}
+	}
+}
+
+// bucket is the state of a key, the fields are used by both algorithms.
+type bucket struct {
+	key    string
+	tokens float64   // token bucket: available tokens
+	last   time.Time // token bucket: last refill time, leaky bucket: time of the last scheduled request
+}
+
+// KeyedLimiter is an in-memory rate limiter with a bucket for each key, it limits the requests
+// of a key in a single node, e.g. per user or per IP, the idle keys are evicted in LRU order.
+type KeyedLimiter struct {
+	leaky    bool
+	rate     float64       // requests per second
+	capacity int64         // token bucket: burst, leaky bucket: max waiting requests
+	interval time.Duration // time between two requests at the rate
+
+	mu      sync.Mutex
+	maxKeys int
+	keys    map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+// NewTokenBucket creates a token bucket limiter, each key has a bucket of burst tokens refilled at
+// rate tokens per second, a request takes a token, and is rejected if there is no token, the bursts
+// of requests up to burst are allowed.
+func NewTokenBucket(rate float64, burst int, opts ...KeyedOption) *KeyedLimiter {
+	return newKeyedLimiter(false, rate, burst, opts...)
+}
+
+// NewLeakyBucket creates a leaky bucket limiter, the requests of each key leak out of the bucket
+// at rate requests per second, that is, the requests are delayed to be spread evenly, at most
+// capacity requests are waiting in the bucket, the requests exceeding the capacity are rejected,
+// so a request waits at most capacity/rate seconds.
+func NewLeakyBucket(rate float64, capacity int, opts ...KeyedOption) *KeyedLimiter {
+	return newKeyedLimiter(true, rate, capacity, opts...)
+}
+
+func newKeyedLimiter(leaky bool, rate float64, capacity int, opts ...KeyedOption) *KeyedLimiter {
+	o := defaultKeyedOptions()
+	o.apply(opts...)
+	if rate <= 0 {
+		rate = 1
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &KeyedLimiter{
+		leaky:    leaky,
+		rate:     rate,
+		capacity: int64(capacity),
+		interval: time.Duration(float64(time.Second) / rate),
+		maxKeys:  o.maxKeys,
+		keys:     make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Allow checks the rate limit of the global key "", it implements the Limiter interface, all
+// the requests share a bucket, use Key to limit the requests of a key. The leaky bucket blocks
+// until the request leaks out of the bucket, that is, at most capacity/rate seconds, the requests
+// should be limited by AllowKey with a context if the wait can not be afforded.
+func (l *KeyedLimiter) Allow() (DoneFunc, error) {
+	return l.Key("").Allow()
+}
+
+// Key returns a Limiter of the key, the Allow of the leaky bucket blocks at most capacity/rate seconds.
+func (l *KeyedLimiter) Key(key string) Limiter {
+	return keyLimiter{l: l, key: key}
+}
+
+// AllowKey reports whether a request of the key is allowed, the leaky bucket waits until the
+// request leaks out of the bucket, the error is returned only when ctx is done while waiting,
+// and the slot of the request is returned to the bucket.
+func (l *KeyedLimiter) AllowKey(ctx context.Context, key string) (*Result, error) {
+	result, wait := l.reserve(key)
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			l.cancelLeaky(key)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of keys kept in memory.
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// reserve takes a token or a slot of the key, and returns the time to wait before the request is allowed.
+func (l *KeyedLimiter) reserve(key string) (*Result, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.getBucket(key, now)
+	if l.leaky {
+		return l.reserveLeaky(b, now)
+	}
+	return l.reserveToken(b, now), 0
+}
+
+func (l *KeyedLimiter) reserveToken(b *bucket, now time.Time) *Result {
+	capacity := float64(l.capacity)
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := &Result{Limit: l.capacity}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	result.Remaining = int64(b.tokens)
+	result.ResetAfter = time.Duration((capacity - b.tokens) / l.rate * float64(time.Second))
+	return result
+}
+
+func (l *KeyedLimiter) reserveLeaky(b *bucket, now time.Time) (*Result, time.Duration) {
+	next := b.last.Add(l.interval)
+	if next.Before(now) {
+		next = now
+	}
+	wait := next.Sub(now)
+	queued := int64(wait / l.interval) // requests waiting in the bucket
+
+	result := &Result{Limit: l.capacity}
+	if queued > l.capacity {
+		result.RetryAfter = wait - time.Duration(l.capacity)*l.interval
+		result.ResetAfter = b.last.Sub(now)
+		return result, 0
+	}
+	b.last = next
+	result.Allowed = true
+	result.Remaining = l.capacity - queued
+	result.ResetAfter = next.Sub(now)
+	return result, wait
+}
+
+// cancelLeaky returns a slot of the request which has not leaked out of the bucket,
+// the following requests are scheduled one interval earlier.
+func (l *KeyedLimiter) cancelLeaky(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.keys[key]
+	if !ok {
+		return // evicted
+	}
+	b := e.Value.(*bucket)
+	if b.last.After(l.now()) {
+		b.last = b.last.Add(-l.interval)
+	}
+}
+
+// getBucket returns the bucket of the key, a new key evicts the least recently used key if the
+// number of keys reaches the limit, it must be called with the lock held.
+func (l *KeyedLimiter) getBucket(key string, now time.Time) *bucket {
+	if e, ok := l.keys[key]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+
+	if l.lru.Len() >= l.maxKeys {
+		if e := l.lru.Back(); e != nil {
+			l.lru.Remove(e)
+			delete(l.keys, e.Value.(*bucket).key)
+		}
+	}
+	b := &bucket{key: key, tokens: float64(l.capacity), last: now.Add(-l.interval)}
+	l.keys[key] = l.lru.PushFront(b)
+	return b
+}
+
+// keyLimiter is the Limiter of a key.
+type keyLimiter struct {
+	l   *KeyedLimiter
+	key string
+}
+
+func (k keyLimiter) Allow() (DoneFunc, error) {
+	result, err := k.l.AllowKey(context.Background(), k.key)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Allowed {
+		return nil, ErrLimitExceed
+	}
+	return func(DoneInfo) {}, nil
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	l := NewTokenBucket(10, 3)
+	l.now = clock.now
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		r, err := l.AllowKey(ctx, "user1")
+		require.NoError(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, int64(2-i), r.Remaining)
+	}
+	r, _ := l.AllowKey(ctx, "user1")
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 100*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, int64(3), r.Limit)
+
+	// other key is not affected
+	r, _ = l.AllowKey(ctx, "user2")
+	assert.True(t, r.Allowed)
+
+	// a token is refilled every 100ms
+	clock.t = clock.t.Add(150 * time.Millisecond)
+	r, _ = l.AllowKey(ctx, "user1")
+	assert.True(t, r.Allowed)
+	r, _ = l.AllowKey(ctx, "user1")
+	assert.False(t, r.Allowed)
+
+	// the bucket is full after a while, at most burst tokens
+	clock.t = clock.t.Add(time.Hour)
+	for i := 0; i < 4; i++ {
+		r, _ = l.AllowKey(ctx, "user1")
+		assert.Equal(t, i < 3, r.Allowed)
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	l := NewLeakyBucket(100, 2)
+	l.now = clock.now
+
+	// the requests are spread evenly, 10ms between two requests
+	waits := []time.Duration{0, 10 * time.Millisecond, 20 * time.Millisecond}
+	for _, want := range waits {
+		r, wait := l.reserve("user1")
+		assert.True(t, r.Allowed)
+		assert.Equal(t, want, wait)
+	}
+	r, wait := l.reserve("user1")
+	assert.False(t, r.Allowed)
+	assert.Zero(t, wait)
+	assert.Equal(t, 10*time.Millisecond, r.RetryAfter)
+
+	clock.t = clock.t.Add(15 * time.Millisecond)
+	r, wait = l.reserve("user1")
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 15*time.Millisecond, wait)
+
+	// wait in AllowKey
+	l = NewLeakyBucket(20, 1)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		r, err := l.AllowKey(context.Background(), "user1")
+		require.NoError(t, err)
+		assert.True(t, r.Allowed)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := l.AllowKey(ctx, "user1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLeakyBucket_Cancel(t *testing.T) {
+	l := NewLeakyBucket(10, 2)
+	ctx := context.Background()
+	r, err := l.AllowKey(ctx, "user1")
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+
+	// the waiting requests are canceled, their slots are returned
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		_, err = l.AllowKey(ctx, "user1")
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	r, wait := l.reserve("user1")
+	assert.True(t, r.Allowed)
+	assert.LessOrEqual(t, wait, 100*time.Millisecond)
+}
+
+func TestKeyedLimiter_Limiter(t *testing.T) {
+	l := NewTokenBucket(0, 0) // default rate 1/s, burst 1
+	done, err := l.Allow()
+	require.NoError(t, err)
+	done(DoneInfo{})
+	_, err = l.Allow()
+	assert.ErrorIs(t, err, ErrLimitExceed)
+
+	_, err = l.Key("user1").Allow()
+	assert.NoError(t, err)
+	_, err = l.Key("user1").Allow()
+	assert.ErrorIs(t, err, ErrLimitExceed)
+}
+
+func TestKeyedLimiter_Eviction(t *testing.T) {
+	l := NewTokenBucket(1, 1, WithMaxKeys(3))
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, _ = l.AllowKey(ctx, "user"+strconv.Itoa(i))
+	}
+	assert.Equal(t, 3, l.Len())
+
+	// user2 is used recently, user3 is evicted
+	_, _ = l.AllowKey(ctx, "user2")
+	_, _ = l.AllowKey(ctx, "user5")
+	assert.Equal(t, 3, l.Len())
+	r, _ := l.AllowKey(ctx, "user2")
+	assert.False(t, r.Allowed)
+	r, _ = l.AllowKey(ctx, "user3")
+	assert.True(t, r.Allowed) // new bucket
+}
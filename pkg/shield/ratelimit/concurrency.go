@@ -0,0 +1,323 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-dev-frame/sponge/pkg/shield/window"
+)
+
+const (
+	// AlgorithmVegas is the TCP Vegas algorithm, it estimates the queue size from the difference
+	// between the minimum latency (no load) and the sampled latency, the limit is increased when
+	// the queue is small and decreased when the queue is large.
+	AlgorithmVegas = "vegas"
+	// AlgorithmGradient2 is the gradient algorithm, the limit is adjusted by the ratio of the long-term
+	// average latency to the sampled latency, it tolerates the latency drifting over time.
+	AlgorithmGradient2 = "gradient2"
+)
+
+var _ Limiter = (*ConcurrencyLimiter)(nil)
+
+// ConcurrencyOption set the options of the adaptive concurrency limiter.
+type ConcurrencyOption func(*concurrencyOptions)
+
+type concurrencyOptions struct {
+	initialLimit int
+	minLimit     int
+	maxLimit     int
+	window       time.Duration
+	bucket       int
+	smoothing    float64
+	tolerance    float64
+	queueSize    int
+}
+
+func defaultConcurrencyOptions() *concurrencyOptions {
+	return &concurrencyOptions{
+		initialLimit: 20,
+		minLimit:     1,
+		maxLimit:     1000,
+		window:       time.Second * 10,
+		bucket:       100,
+		smoothing:    0.2,
+		tolerance:    1.5,
+		queueSize:    4,
+	}
+}
+
+func (o *concurrencyOptions) apply(opts ...ConcurrencyOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithInitialLimit set the initial concurrency limit, default 20.
+func WithInitialLimit(n int) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if n > 0 {
+			o.initialLimit = n
+		}
+	}
+}
+
+// WithLimitRange set the min and max concurrency limit, default 1 and 1000.
+func WithLimitRange(minLimit int, maxLimit int) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if minLimit > 0 && maxLimit >= minLimit {
+			o.minLimit = minLimit
+			o.maxLimit = maxLimit
+		}
+	}
+}
+
+// WithRTTWindow set the rolling window of latency, default window 10s with 100 buckets, the limit
+// is updated once per bucket duration, the minimum latency (Vegas) and long-term average latency
+// (Gradient2) are calculated in the window.
+func WithRTTWindow(d time.Duration, bucket int) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if d > 0 && bucket > 0 && d/time.Duration(bucket) > 0 {
+			o.window = d
+			o.bucket = bucket
+		}
+	}
+}
+
+// WithSmoothing set the smoothing factor of the limit update in (0, 1], default 0.2,
+// only used by Gradient2, Vegas updates the limit without smoothing.
+func WithSmoothing(f float64) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if f > 0 && f <= 1 {
+			o.smoothing = f
+		}
+	}
+}
+
+// WithRTTTolerance set the tolerance of the sampled latency to the long-term average latency,
+// e.g. 1.5 means the latency can be up to 50% higher before the limit is decreased,
+// default 1.5, only used by Gradient2.
+func WithRTTTolerance(f float64) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if f >= 1 {
+			o.tolerance = f
+		}
+	}
+}
+
+// WithQueueSize set the number of requests allowed to queue when the latency is stable,
+// default 4, only used by Gradient2.
+func WithQueueSize(n int) ConcurrencyOption {
+	return func(o *concurrencyOptions) {
+		if n >= 0 {
+			o.queueSize = n
+		}
+	}
+}
+
+// ConcurrencyLimiter is an adaptive concurrency limiter, the limit of in-flight requests is
+// estimated from the observed latency instead of CPU usage, it is suitable for IO-bound services.
+type ConcurrencyLimiter struct {
+	algorithm string
+	opts      *concurrencyOptions
+	interval  time.Duration // sample interval, the limit is updated once per interval
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int64
+	rtStat   window.RollingCounter // latency in microseconds
+
+	// the sample of the current interval
+	sampleStart    time.Time
+	sampleRTT      int64 // sum of latency in microseconds
+	sampleCount    int64
+	sampleInFlight int64 // max in-flight requests
+	sampleDropped  bool
+}
+
+// NewVegasLimiter creates an adaptive concurrency limiter with the TCP Vegas algorithm.
+func NewVegasLimiter(opts ...ConcurrencyOption) *ConcurrencyLimiter {
+	return newConcurrencyLimiter(AlgorithmVegas, opts...)
+}
+
+// NewGradientLimiter creates an adaptive concurrency limiter with the Gradient2 algorithm.
+func NewGradientLimiter(opts ...ConcurrencyOption) *ConcurrencyLimiter {
+	return newConcurrencyLimiter(AlgorithmGradient2, opts...)
+}
+
+func newConcurrencyLimiter(algorithm string, opts ...ConcurrencyOption) *ConcurrencyLimiter {
+	o := defaultConcurrencyOptions()
+	o.apply(opts...)
+	limit := math.Min(math.Max(float64(o.initialLimit), float64(o.minLimit)), float64(o.maxLimit))
+	interval := o.window / time.Duration(o.bucket)
+
+	return &ConcurrencyLimiter{
+		algorithm:   algorithm,
+		opts:        o,
+		interval:    interval,
+		limit:       limit,
+		rtStat:      window.NewRollingCounter(window.RollingCounterOpts{Size: o.bucket, BucketDuration: interval}),
+		sampleStart: time.Now(),
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (l *ConcurrencyLimiter) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.limit)
+}
+
+// InFlight returns the number of in-flight requests.
+func (l *ConcurrencyLimiter) InFlight() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Allow checks the in-flight requests, ErrLimitExceed is returned if the number of in-flight
+// requests reaches the limit. The request is regarded as dropped if the error of DoneInfo is
+// context.DeadlineExceeded, and the limit is decreased.
+func (l *ConcurrencyLimiter) Allow() (DoneFunc, error) {
+	l.mu.Lock()
+	if l.inFlight >= int64(l.limit) {
+		l.mu.Unlock()
+		return nil, ErrLimitExceed
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	start := time.Now()
+	return func(info DoneInfo) {
+		l.record(time.Since(start), inFlight, errors.Is(info.Err, context.DeadlineExceeded))
+	}, nil
+}
+
+// record adds the latency of a request to the sample, and updates the limit if the sample interval elapsed.
+func (l *ConcurrencyLimiter) record(rtt time.Duration, inFlight int64, dropped bool) {
+	us := rtt.Microseconds()
+	if us <= 0 {
+		us = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if dropped {
+		l.sampleDropped = true
+	} else {
+		l.rtStat.Add(us)
+		l.sampleRTT += us
+		l.sampleCount++
+	}
+	if inFlight > l.sampleInFlight {
+		l.sampleInFlight = inFlight
+	}
+
+	now := time.Now()
+	if now.Sub(l.sampleStart) < l.interval {
+		return
+	}
+	if l.sampleCount > 0 || l.sampleDropped {
+		var avg float64
+		if l.sampleCount > 0 {
+			avg = float64(l.sampleRTT) / float64(l.sampleCount)
+		}
+		if l.algorithm == AlgorithmVegas {
+			l.limit = vegasLimit(l.limit, l.minRTT(), avg, l.sampleInFlight, l.sampleDropped)
+		} else {
+			l.limit = gradientLimit(l.limit, l.avgRTT(), avg, l.sampleInFlight, l.sampleDropped, l.opts)
+		}
+		l.limit = math.Min(math.Max(l.limit, float64(l.opts.minLimit)), float64(l.opts.maxLimit))
+	}
+	l.sampleStart = now
+	l.sampleRTT, l.sampleCount, l.sampleInFlight, l.sampleDropped = 0, 0, 0, false
+}
+
+// minRTT returns the minimum of the average latency of the buckets in the window.
+func (l *ConcurrencyLimiter) minRTT() float64 {
+	return l.rtStat.Reduce(func(iterator window.Iterator) float64 {
+		result := 0.0
+		for iterator.Next() {
+			bucket := iterator.Bucket()
+			if bucket.Count == 0 || len(bucket.Points) == 0 {
+				continue
+			}
+			avg := bucket.Points[0] / float64(bucket.Count)
+			if result == 0 || avg < result {
+				result = avg
+			}
+		}
+		return result
+	})
+}
+
+// avgRTT returns the average latency of the requests in the window.
+func (l *ConcurrencyLimiter) avgRTT() float64 {
+	return l.rtStat.Reduce(func(iterator window.Iterator) float64 {
+		var total, count float64
+		for iterator.Next() {
+			bucket := iterator.Bucket()
+			for _, p := range bucket.Points {
+				total += p
+			}
+			count += float64(bucket.Count)
+		}
+		if count == 0 {
+			return 0
+		}
+		return total / count
+	})
+}
+
+func log10(limit float64) float64 {
+	return math.Max(1, math.Log10(limit))
+}
+
+// vegasLimit returns the new limit of Vegas, queue = limit * (1 - minRTT/rtt),
+// the limit is increased by beta if queue <= log10(limit), increased by log10(limit) if
+// queue < alpha, decreased by log10(limit) if queue > beta, where alpha = 3*log10(limit)
+// and beta = 6*log10(limit).
+func vegasLimit(limit float64, minRTT float64, rtt float64, inFlight int64, dropped bool) float64 {
+	if dropped {
+		return limit - log10(limit)
+	}
+	// the limit is not reached, no evidence to increase the limit
+	if float64(inFlight)*2 < limit || minRTT <= 0 || rtt <= 0 {
+		return limit
+	}
+
+	queue := math.Ceil(limit * (1 - minRTT/rtt))
+	threshold := log10(limit)
+	alpha, beta := 3*threshold, 6*threshold
+	switch {
+	case queue <= threshold:
+		return limit + beta
+	case queue < alpha:
+		return limit + threshold
+	case queue > beta:
+		return limit - threshold
+	default:
+		return limit
+	}
+}
+
+// gradientLimit returns the new limit of Gradient2, gradient = tolerance * longRTT / rtt in [0.5, 1],
+// new limit = limit * gradient + queueSize, and it is smoothed with the current limit.
+func gradientLimit(limit float64, longRTT float64, rtt float64, inFlight int64, dropped bool, o *concurrencyOptions) float64 {
+	if dropped {
+		return limit * (1 - o.smoothing/2)
+	}
+	// the limit is not reached, no evidence to increase the limit
+	if float64(inFlight)*2 < limit || longRTT <= 0 || rtt <= 0 {
+		return limit
+	}
+
+	gradient := math.Max(0.5, math.Min(1, o.tolerance*longRTT/rtt))
+	newLimit := limit*gradient + float64(o.queueSize)
+	return limit*(1-o.smoothing) + newLimit*o.smoothing
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter_Allow(t *testing.T) {
+	limiter := NewVegasLimiter(WithInitialLimit(2), WithLimitRange(1, 10))
+	assert.Equal(t, int64(2), limiter.Limit())
+
+	done1, err := limiter.Allow()
+	assert.NoError(t, err)
+	done2, err := limiter.Allow()
+	assert.NoError(t, err)
+	_, err = limiter.Allow()
+	assert.ErrorIs(t, err, ErrLimitExceed)
+	assert.Equal(t, int64(2), limiter.InFlight())
+
+	done1(DoneInfo{})
+	done2(DoneInfo{})
+	assert.Equal(t, int64(0), limiter.InFlight())
+	_, err = limiter.Allow()
+	assert.NoError(t, err)
+
+	// invalid options are ignored
+	limiter = NewGradientLimiter(WithInitialLimit(0), WithLimitRange(10, 1), WithRTTWindow(0, 0),
+		WithSmoothing(2), WithRTTTolerance(0.5), WithQueueSize(-1))
+	assert.Equal(t, defaultConcurrencyOptions(), limiter.opts)
+	assert.Equal(t, int64(20), limiter.Limit())
+}
+
+func TestVegasLimit(t *testing.T) {
+	// no queue, increase by beta
+	assert.Equal(t, 112.0, vegasLimit(100, 10, 10, 100, false))
+	// small queue, increase by log10(limit)
+	assert.Equal(t, 102.0, vegasLimit(100, 10, 10.3, 100, false))
+	// large queue, decrease by log10(limit)
+	assert.Equal(t, 98.0, vegasLimit(100, 10, 20, 100, false))
+	// queue between alpha and beta, keep
+	assert.Equal(t, 100.0, vegasLimit(100, 10, 11, 100, false))
+	// not enough in-flight requests, keep
+	assert.Equal(t, 100.0, vegasLimit(100, 10, 10, 10, false))
+	// dropped, decrease
+	assert.Equal(t, 98.0, vegasLimit(100, 10, 10, 100, true))
+}
+
+func TestGradientLimit(t *testing.T) {
+	o := defaultConcurrencyOptions()
+	// stable latency, increase by queue size with smoothing
+	assert.InDelta(t, 100.8, gradientLimit(100, 10, 10, 100, false, o), 1e-9)
+	// latency doubled, gradient 0.75
+	assert.InDelta(t, 95.8, gradientLimit(100, 10, 20, 100, false, o), 1e-9)
+	// latency is very high, gradient is at least 0.5
+	assert.InDelta(t, 90.8, gradientLimit(100, 10, 100, 100, false, o), 1e-9)
+	// not enough in-flight requests, keep
+	assert.Equal(t, 100.0, gradientLimit(100, 10, 100, 10, false, o))
+	// dropped, decrease
+	assert.InDelta(t, 90.0, gradientLimit(100, 10, 10, 100, true, o), 1e-9)
+}
+
+func runConcurrency(limiter *ConcurrencyLimiter, latency time.Duration, d time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		done, err := limiter.Allow()
+		if err != nil {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(latency)
+			done(DoneInfo{})
+		}()
+	}
+}
+
+func TestConcurrencyLimiter_Adaptive(t *testing.T) {
+	for _, newLimiter := range []func(...ConcurrencyOption) *ConcurrencyLimiter{NewVegasLimiter, NewGradientLimiter} {
+		limiter := newLimiter(
+			WithInitialLimit(10),
+			WithLimitRange(2, 50),
+			WithRTTWindow(time.Second*2, 100),
+		)
+
+		// the latency is stable, the limit increases
+		runConcurrency(limiter, time.Millisecond*5, time.Millisecond*300)
+		high := limiter.Limit()
+		assert.Greater(t, high, int64(10), limiter.algorithm)
+
+		// the latency increases, the limit decreases
+		runConcurrency(limiter, time.Millisecond*50, time.Millisecond*500)
+		assert.Less(t, limiter.Limit(), high, limiter.algorithm)
+
+		// the requests time out, the limit decreases
+		limit := limiter.Limit()
+		done, err := limiter.Allow()
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 30)
+		done(DoneInfo{Err: context.DeadlineExceeded})
+		assert.Less(t, limiter.Limit(), limit, limiter.algorithm)
+	}
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority is the priority class of a request, the requests of lower priority are shed first when overloaded.
+type Priority int
+
+const (
+	// PrioritySheddable the requests can be dropped first, e.g. prefetch, batch and retry requests.
+	PrioritySheddable Priority = iota
+	// PriorityDefault the requests without priority.
+	PriorityDefault
+	// PriorityCritical the requests are dropped last, e.g. login and payment requests.
+	PriorityCritical
+)
+
+var (
+	_ Limiter = (*Shedder)(nil)
+
+	droppedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "load_shedding_dropped_total",
+		Help: "Total number of requests dropped by load shedding, partitioned by priority.",
+	}, []string{"name", "priority"})
+	droppedRegisterOnce sync.Once
+)
+
+// String returns the name of the priority, "sheddable", "default" or "critical".
+func (p Priority) String() string {
+	switch p {
+	case PrioritySheddable:
+		return "sheddable"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "default"
+	}
+}
+
+// ParsePriority parses the name of the priority, false is returned if the name is unknown.
+func ParsePriority(name string) (Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "sheddable":
+		return PrioritySheddable, true
+	case "default":
+		return PriorityDefault, true
+	case "critical":
+		return PriorityCritical, true
+	default:
+		return PriorityDefault, false
+	}
+}
+
+// ShedderOption set the options of shedder.
+type ShedderOption func(*shedderOptions)
+
+type shedderOptions struct {
+	name     string
+	cooldown time.Duration
+}
+
+func defaultShedderOptions() *shedderOptions {
+	return &shedderOptions{
+		cooldown: time.Second,
+	}
+}
+
+func (o *shedderOptions) apply(opts ...ShedderOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithShedderName set the name of the shedder, the name is used as the label of Prometheus counter
+// "load_shedding_dropped_total", the counter is reported only if the name is not empty.
+func WithShedderName(name string) ShedderOption {
+	return func(o *shedderOptions) {
+		o.name = name
+	}
+}
+
+// WithCooldown set the duration without overload before the shed priority is lowered by one class, default 1s.
+func WithCooldown(d time.Duration) ShedderOption {
+	return func(o *shedderOptions) {
+		if d > 0 {
+			o.cooldown = d
+		}
+	}
+}
+
+// Shedder sheds the requests by priority when the limiter is overloaded, if a request is rejected by
+// the limiter, the requests of lower priority than the rejected one are dropped immediately without
+// asking the limiter, the shed priority is lowered by one class after the cooldown without overload.
+type Shedder struct {
+	limiter Limiter
+	opts    *shedderOptions
+
+	mu         sync.Mutex
+	level      Priority // the requests of lower priority than level are dropped
+	lastReject time.Time
+
+	dropped [PriorityCritical + 1]int64
+}
+
+// NewShedder creates a shedder, limiter detects the overload, e.g. the adaptive rate limiter
+// NewLimiter or the adaptive concurrency limiter NewGradientLimiter.
+func NewShedder(limiter Limiter, opts ...ShedderOption) *Shedder {
+	o := defaultShedderOptions()
+	o.apply(opts...)
+	if o.name != "" {
+		droppedRegisterOnce.Do(func() {
+			_ = prometheus.Register(droppedCounter)
+		})
+	}
+
+	return &Shedder{
+		limiter: limiter,
+		opts:    o,
+		level:   PrioritySheddable,
+	}
+}
+
+// Allow checks the request of default priority, it implements the Limiter interface.
+func (s *Shedder) Allow() (DoneFunc, error) {
+	return s.AllowPriority(PriorityDefault)
+}
+
+// AllowPriority checks the request of the priority, ErrLimitExceed is returned if the request is dropped.
+func (s *Shedder) AllowPriority(p Priority) (DoneFunc, error) {
+	p = normalizePriority(p)
+	if p < s.Level() {
+		s.drop(p)
+		return nil, ErrLimitExceed
+	}
+
+	done, err := s.limiter.Allow()
+	if err != nil {
+		s.mu.Lock()
+		if p > s.level {
+			s.level = p
+		}
+		s.lastReject = time.Now()
+		s.mu.Unlock()
+		s.drop(p)
+		return nil, err
+	}
+	return done, nil
+}
+
+// Level returns the shed priority, the requests of lower priority are dropped,
+// PrioritySheddable means no request is shed by priority.
+func (s *Shedder) Level() Priority {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.level > PrioritySheddable {
+		if time.Since(s.lastReject) >= s.opts.cooldown {
+			s.level--
+			s.lastReject = time.Now()
+		}
+	}
+	return s.level
+}
+
+// Dropped returns the number of dropped requests of the priority.
+func (s *Shedder) Dropped(p Priority) int64 {
+	return atomic.LoadInt64(&s.dropped[normalizePriority(p)])
+}
+
+func (s *Shedder) drop(p Priority) {
+	atomic.AddInt64(&s.dropped[p], 1)
+	if s.opts.name != "" {
+		droppedCounter.WithLabelValues(s.opts.name, p.String()).Inc()
+	}
+}
+
+func normalizePriority(p Priority) Priority {
+	if p < PrioritySheddable || p > PriorityCritical {
+		return PriorityDefault
+	}
+	return p
+}
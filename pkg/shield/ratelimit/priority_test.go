@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type overloadLimiter struct {
+	overloaded int32
+}
+
+func (l *overloadLimiter) Allow() (DoneFunc, error) {
+	if atomic.LoadInt32(&l.overloaded) == 1 {
+		return nil, ErrLimitExceed
+	}
+	return func(DoneInfo) {}, nil
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PrioritySheddable, PriorityDefault, PriorityCritical} {
+		v, ok := ParsePriority(p.String())
+		assert.True(t, ok)
+		assert.Equal(t, p, v)
+	}
+	v, ok := ParsePriority(" Critical ")
+	assert.True(t, ok)
+	assert.Equal(t, PriorityCritical, v)
+	v, ok = ParsePriority("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, PriorityDefault, v)
+	assert.Equal(t, "default", Priority(10).String())
+}
+
+func TestShedder(t *testing.T) {
+	limiter := &overloadLimiter{}
+	shedder := NewShedder(limiter, WithShedderName("test"), WithCooldown(time.Millisecond*100))
+
+	for _, p := range []Priority{PrioritySheddable, PriorityDefault, PriorityCritical} {
+		done, err := shedder.AllowPriority(p)
+		assert.NoError(t, err)
+		done(DoneInfo{})
+	}
+	_, err := shedder.Allow()
+	assert.NoError(t, err)
+	assert.Equal(t, PrioritySheddable, shedder.Level())
+
+	// overloaded, the critical request is rejected, the lower priorities are shed
+	atomic.StoreInt32(&limiter.overloaded, 1)
+	_, err = shedder.AllowPriority(PriorityCritical)
+	assert.ErrorIs(t, err, ErrLimitExceed)
+	assert.Equal(t, PriorityCritical, shedder.Level())
+	atomic.StoreInt32(&limiter.overloaded, 0)
+
+	_, err = shedder.AllowPriority(PrioritySheddable)
+	assert.ErrorIs(t, err, ErrLimitExceed)
+	_, err = shedder.AllowPriority(PriorityDefault)
+	assert.ErrorIs(t, err, ErrLimitExceed)
+	_, err = shedder.AllowPriority(PriorityCritical)
+	assert.NoError(t, err)
+
+	// after the cooldown, the default requests are allowed, the sheddable requests are still shed
+	time.Sleep(time.Millisecond * 120)
+	_, err = shedder.AllowPriority(PriorityDefault)
+	assert.NoError(t, err)
+	_, err = shedder.AllowPriority(PrioritySheddable)
+	assert.ErrorIs(t, err, ErrLimitExceed)
+	time.Sleep(time.Millisecond * 120)
+	_, err = shedder.AllowPriority(PrioritySheddable)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(2), shedder.Dropped(PrioritySheddable))
+	assert.Equal(t, int64(1), shedder.Dropped(PriorityDefault))
+	assert.Equal(t, int64(1), shedder.Dropped(PriorityCritical))
+	assert.Equal(t, 2.0, testutil.ToFloat64(droppedCounter.WithLabelValues("test", "sheddable")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(droppedCounter.WithLabelValues("test", "critical")))
+
+	// the default request is rejected, only the sheddable requests are shed
+	atomic.StoreInt32(&limiter.overloaded, 1)
+	_, err = shedder.Allow()
+	assert.Error(t, err)
+	assert.Equal(t, PriorityDefault, shedder.Level())
+}
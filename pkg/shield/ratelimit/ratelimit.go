@@ -1,7 +1,11 @@
 package ratelimit
 
 import (
+	"context"
 	"errors"
+	"math"
+	"strconv"
+	"time"
 )
 
 var (
@@ -22,3 +26,43 @@ type DoneInfo struct {
 type Limiter interface {
 	Allow() (DoneFunc, error)
 }
+
+// Result is the result of a rate limit check of a key.
+type Result struct {
+	Allowed    bool
+	Limit      int64         // max requests in a period
+	Remaining  int64         // remaining requests that can be made immediately
+	RetryAfter time.Duration // time to wait before the next request is allowed, 0 if allowed
+	ResetAfter time.Duration // time until the limit is fully reset
+}
+
+// KeyLimiter is a rate limiter that limits the requests by key, e.g. user id, API key or client IP.
+type KeyLimiter interface {
+	AllowKey(ctx context.Context, key string) (*Result, error)
+}
+
+// Header names of the rate limit result, the values of X-RateLimit-Reset and Retry-After are in seconds.
+const (
+	HeaderLimit      = "X-RateLimit-Limit"
+	HeaderRemaining  = "X-RateLimit-Remaining"
+	HeaderReset      = "X-RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Headers returns the standard rate limit headers of the result, Retry-After is included
+// only when the request is rejected.
+func (r *Result) Headers() map[string]string {
+	headers := map[string]string{
+		HeaderLimit:     strconv.FormatInt(r.Limit, 10),
+		HeaderRemaining: strconv.FormatInt(r.Remaining, 10),
+		HeaderReset:     strconv.FormatInt(ceilSeconds(r.ResetAfter), 10),
+	}
+	if !r.Allowed {
+		headers[HeaderRetryAfter] = strconv.FormatInt(ceilSeconds(r.RetryAfter), 10)
+	}
+	return headers
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
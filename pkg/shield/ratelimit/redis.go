@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// AlgorithmGCRA is the generic cell rate algorithm, it is equivalent to a token bucket,
+	// the requests are spread evenly over the period, and a burst of requests is allowed.
+	AlgorithmGCRA = "gcra"
+	// AlgorithmSlidingWindow is the sliding window log algorithm, at most limit requests are
+	// allowed in any period, the timestamps of the requests are stored in a sorted set.
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+var _ KeyLimiter = (*RedisLimiter)(nil)
+
+// the time of redis is used by all replicas, the replication of the scripts is effects based.
+const scriptNow = `
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+`
+
+// KEYS[1] key, ARGV[1] limit, ARGV[2] period(us), ARGV[3] member
+// return {allowed, remaining, retry_after(us), reset_after(us)}
+var slidingWindowScript = redis.NewScript(scriptNow + `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - period)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], math.ceil(period / 1000))
+	return {1, limit - count - 1, 0, period}
+end
+
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local newest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+return {0, 0, tonumber(oldest[2]) + period - now, tonumber(newest[2]) + period - now}
+`)
+
+// KEYS[1] key, ARGV[1] emission interval(us), ARGV[2] burst
+// return {allowed, remaining, retry_after(us), reset_after(us)}
+var gcraScript = redis.NewScript(scriptNow + `
+local interval = tonumber(ARGV[1])
+local burst_offset = interval * tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local diff = now - (new_tat - burst_offset)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", KEYS[1], string.format("%d", new_tat), "PX", math.ceil(reset_after / 1000))
+return {1, math.floor(diff / interval), 0, reset_after}
+`)
+
+// RedisOption set the options of redis limiter.
+type RedisOption func(*redisOptions)
+
+type redisOptions struct {
+	algorithm string
+	burst     int
+	keyPrefix string
+}
+
+func defaultRedisOptions() *redisOptions {
+	return &redisOptions{
+		algorithm: AlgorithmGCRA,
+		keyPrefix: "ratelimit:",
+	}
+}
+
+func (o *redisOptions) apply(opts ...RedisOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithAlgorithm set the rate limit algorithm, AlgorithmGCRA (default) or AlgorithmSlidingWindow.
+func WithAlgorithm(algorithm string) RedisOption {
+	return func(o *redisOptions) {
+		if algorithm == AlgorithmGCRA || algorithm == AlgorithmSlidingWindow {
+			o.algorithm = algorithm
+		}
+	}
+}
+
+// WithBurst set the max burst of requests of the GCRA algorithm, default is the limit.
+func WithBurst(burst int) RedisOption {
+	return func(o *redisOptions) {
+		if burst > 0 {
+			o.burst = burst
+		}
+	}
+}
+
+// WithKeyPrefix set the prefix of the redis keys, default "ratelimit:".
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// RedisLimiter is a distributed rate limiter backed by redis, the requests of a key are
+// limited across all the replicas of the service.
+type RedisLimiter struct {
+	client    redis.Scripter
+	algorithm string
+	limit     int64
+	period    time.Duration
+	burst     int64
+	keyPrefix string
+}
+
+// NewRedisLimiter creates a redis limiter that allows limit requests per period for each key,
+// client is a *redis.Client, *redis.ClusterClient or redis.UniversalClient.
+func NewRedisLimiter(client redis.Scripter, limit int, period time.Duration, opts ...RedisOption) *RedisLimiter {
+	o := defaultRedisOptions()
+	o.apply(opts...)
+	if limit < 1 {
+		limit = 1
+	}
+	if period <= 0 {
+		period = time.Second
+	}
+	if o.burst == 0 {
+		o.burst = limit
+	}
+
+	return &RedisLimiter{
+		client:    client,
+		algorithm: o.algorithm,
+		limit:     int64(limit),
+		period:    period,
+		burst:     int64(o.burst),
+		keyPrefix: o.keyPrefix,
+	}
+}
+
+// AllowKey reports whether a request of the key is allowed, the error is returned only when
+// redis is unavailable.
+func (l *RedisLimiter) AllowKey(ctx context.Context, key string) (*Result, error) {
+	var (
+		values []int64
+		err    error
+	)
+	key = l.keyPrefix + key
+	if l.algorithm == AlgorithmSlidingWindow {
+		member := strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(rand.Int63(), 36) //nolint
+		values, err = slidingWindowScript.Run(ctx, l.client, []string{key}, l.limit, l.period.Microseconds(), member).Int64Slice()
+	} else {
+		interval := float64(l.period.Microseconds()) / float64(l.limit)
+		values, err = gcraScript.Run(ctx, l.client, []string{key}, math.Max(interval, 1), l.burst).Int64Slice()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, errors.New("unexpected result of rate limit script")
+	}
+
+	limit := l.limit
+	if l.algorithm == AlgorithmGCRA {
+		limit = l.burst
+	}
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Now())
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRedisLimiter_GCRA(t *testing.T) {
+	mr, client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisLimiter(client, 10, time.Second, WithBurst(3), WithKeyPrefix("test:"))
+
+	for i := 0; i < 3; i++ {
+		r, err := l.AllowKey(ctx, "user1")
+		require.NoError(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, int64(3), r.Limit)
+		assert.Equal(t, int64(2-i), r.Remaining)
+	}
+	r, err := l.AllowKey(ctx, "user1")
+	require.NoError(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 100*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, "1", r.Headers()[HeaderRetryAfter])
+
+	// other key is not affected
+	r, err = l.AllowKey(ctx, "user2")
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.True(t, mr.Exists("test:user2"))
+
+	// a token is refilled every 100ms
+	mr.SetTime(time.Now().Add(150 * time.Millisecond))
+	r, err = l.AllowKey(ctx, "user1")
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, int64(0), r.Remaining)
+}
+
+func TestRedisLimiter_SlidingWindow(t *testing.T) {
+	mr, client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisLimiter(client, 3, time.Second, WithAlgorithm(AlgorithmSlidingWindow))
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		mr.SetTime(now.Add(time.Duration(i) * 100 * time.Millisecond))
+		r, err := l.AllowKey(ctx, "user1")
+		require.NoError(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, int64(2-i), r.Remaining)
+	}
+	r, err := l.AllowKey(ctx, "user1")
+	require.NoError(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 800*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, time.Second, r.ResetAfter)
+
+	// the first request slides out of the window
+	mr.SetTime(now.Add(time.Second + time.Millisecond))
+	r, err = l.AllowKey(ctx, "user1")
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, int64(0), r.Remaining)
+}
+
+func TestRedisLimiter_Error(t *testing.T) {
+	mr, client := newRedisClient(t)
+	l := NewRedisLimiter(client, 0, 0, WithAlgorithm("unknown"), WithBurst(-1))
+	assert.Equal(t, AlgorithmGCRA, l.algorithm)
+	assert.Equal(t, int64(1), l.limit)
+	assert.Equal(t, time.Second, l.period)
+
+	mr.Close()
+	_, err := l.AllowKey(context.Background(), "user1")
+	assert.Error(t, err)
+}
+
+func TestResult_Headers(t *testing.T) {
+	r := &Result{Allowed: true, Limit: 10, Remaining: 5, ResetAfter: 1500 * time.Millisecond}
+	headers := r.Headers()
+	assert.Equal(t, "10", headers[HeaderLimit])
+	assert.Equal(t, "5", headers[HeaderRemaining])
+	assert.Equal(t, "2", headers[HeaderReset])
+	assert.NotContains(t, headers, HeaderRetryAfter)
+}
@@ -0,0 +1,222 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// BroadcastMessage user events distributed between hub nodes
+type BroadcastMessage struct {
+	ID      string   `json:"id"`                // message id, used for deduplication
+	NodeID  string   `json:"nodeID"`            // the node that published the message
+	UIDs    []string `json:"uids"`              // if empty and channel is empty, push to all online users
+	Channel string   `json:"channel,omitempty"` // if not empty, push to users who subscribed the channel
+	Events  []*Event `json:"events"`
+}
+
+// Broadcaster distributes events pushed on any node to all hub nodes, and tracks
+// which nodes the users are connected to.
+type Broadcaster interface {
+	// Publish message to all nodes
+	Publish(ctx context.Context, msg *BroadcastMessage) error
+	// Subscribe messages published by all nodes, non-blocking, the subscription is
+	// cancelled when ctx is done, handler is called serially.
+	Subscribe(ctx context.Context, nodeID string, handler func(msg *BroadcastMessage)) error
+	// Online mark the user as connected to the node
+	Online(ctx context.Context, nodeID string, uid string) error
+	// Offline mark the user as disconnected from the node
+	Offline(ctx context.Context, nodeID string, uid string) error
+	// Nodes list the alive nodes the user is connected to
+	Nodes(ctx context.Context, uid string) ([]string, error)
+}
+
+var errBroadcasterClosed = errors.New("broadcaster is closed")
+
+func defaultNodeID() string {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "node"
+	}
+	return hostname + "-" + newStringID()
+}
+
+// ------------------------------------------------------------------------------------------
+
+// MemoryBroadcaster in-process broadcaster, hubs in the same process share one instance
+// to simulate multiple nodes, it is usually used for testing.
+type MemoryBroadcaster struct {
+	mu       sync.RWMutex
+	subs     map[string]*memorySubscriber   // nodeID -> subscriber
+	presence map[string]map[string]struct{} // uid -> nodeIDs
+	closed   bool
+}
+
+type memorySubscriber struct {
+	ch   chan *BroadcastMessage
+	done chan struct{} // closed when the subscription is cancelled
+}
+
+// NewMemoryBroadcaster create an in-process broadcaster
+func NewMemoryBroadcaster() *MemoryBroadcaster {
+	return &MemoryBroadcaster{
+		subs:     make(map[string]*memorySubscriber),
+		presence: make(map[string]map[string]struct{}),
+	}
+}
+
+// Publish message to all subscribed nodes, the lock is not held while sending, so a
+// subscriber cancelled during publishing does not block the others.
+func (b *MemoryBroadcaster) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return errBroadcasterClosed
+	}
+	subs := make([]*memorySubscriber, 0, len(b.subs))
+	for _, sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- msg:
+		case <-sub.done: // the subscriber has left
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe messages published by all nodes
+func (b *MemoryBroadcaster) Subscribe(ctx context.Context, nodeID string, handler func(msg *BroadcastMessage)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errBroadcasterClosed
+	}
+	if _, ok := b.subs[nodeID]; ok {
+		return errors.New("node " + nodeID + " has already subscribed")
+	}
+
+	sub := &memorySubscriber{ch: make(chan *BroadcastMessage, 1000), done: make(chan struct{})}
+	b.subs[nodeID] = sub
+
+	go func() {
+		for {
+			select {
+			case msg := <-sub.ch:
+				handler(msg)
+			case <-ctx.Done():
+				b.leave(nodeID)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// leave remove the subscription and presence of the node
+func (b *MemoryBroadcaster) leave(nodeID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sub, ok := b.subs[nodeID]; ok {
+		close(sub.done)
+		delete(b.subs, nodeID)
+	}
+	for uid, nodes := range b.presence {
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(b.presence, uid)
+		}
+	}
+}
+
+// Online mark the user as connected to the node
+func (b *MemoryBroadcaster) Online(_ context.Context, nodeID string, uid string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	nodes, ok := b.presence[uid]
+	if !ok {
+		nodes = make(map[string]struct{})
+		b.presence[uid] = nodes
+	}
+	nodes[nodeID] = struct{}{}
+	return nil
+}
+
+// Offline mark the user as disconnected from the node
+func (b *MemoryBroadcaster) Offline(_ context.Context, nodeID string, uid string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if nodes, ok := b.presence[uid]; ok {
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(b.presence, uid)
+		}
+	}
+	return nil
+}
+
+// Nodes list the nodes the user is connected to
+func (b *MemoryBroadcaster) Nodes(_ context.Context, uid string) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var nodeIDs []string
+	for nodeID := range b.presence[uid] {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	return nodeIDs, nil
+}
+
+// Close the broadcaster, publish and subscribe are not allowed after closing
+func (b *MemoryBroadcaster) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+// dedupCache remembers the most recent message ids, used to make sure that
+// each message is delivered only once on a node.
+type dedupCache struct {
+	mu   sync.Mutex
+	ids  map[string]struct{}
+	ring []string
+	pos  int
+}
+
+func newDedupCache(size int) *dedupCache {
+	return &dedupCache{
+		ids:  make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// seen returns true if the id has been seen, otherwise remember it and return false
+func (c *dedupCache) seen(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.ids[id]; ok {
+		return true
+	}
+
+	if old := c.ring[c.pos]; old != "" {
+		delete(c.ids, old)
+	}
+	c.ring[c.pos] = id
+	c.ids[id] = struct{}{}
+	c.pos = (c.pos + 1) % len(c.ring)
+	return false
+}
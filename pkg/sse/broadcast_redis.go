@@ -0,0 +1,229 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RedisBroadcasterOption redis broadcaster option
+type RedisBroadcasterOption func(*redisBroadcasterOptions)
+
+type redisBroadcasterOptions struct {
+	channel   string
+	keyPrefix string
+	// the node is considered dead if no heartbeat is received within nodeTTL
+	nodeTTL time.Duration
+	logger  *zap.Logger
+}
+
+func defaultRedisBroadcasterOptions() *redisBroadcasterOptions {
+	zapLogger, _ := zap.NewProduction()
+	return &redisBroadcasterOptions{
+		channel:   "sse:broadcast",
+		keyPrefix: "sse:presence:",
+		nodeTTL:   30 * time.Second,
+		logger:    zapLogger,
+	}
+}
+
+func (o *redisBroadcasterOptions) apply(opts ...RedisBroadcasterOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithBroadcastChannel set redis pub/sub channel name, default is sse:broadcast
+func WithBroadcastChannel(channel string) RedisBroadcasterOption {
+	return func(o *redisBroadcasterOptions) {
+		if channel == "" {
+			return
+		}
+		o.channel = channel
+	}
+}
+
+// WithPresenceKeyPrefix set key prefix of presence, default is sse:presence:
+func WithPresenceKeyPrefix(prefix string) RedisBroadcasterOption {
+	return func(o *redisBroadcasterOptions) {
+		if prefix == "" {
+			return
+		}
+		o.keyPrefix = prefix
+	}
+}
+
+// WithNodeTTL set node heartbeat ttl, default is 30s
+func WithNodeTTL(d time.Duration) RedisBroadcasterOption {
+	return func(o *redisBroadcasterOptions) {
+		if d <= 0 {
+			return
+		}
+		o.nodeTTL = d
+	}
+}
+
+// WithBroadcasterLogger set logger
+func WithBroadcasterLogger(logger *zap.Logger) RedisBroadcasterOption {
+	return func(o *redisBroadcasterOptions) {
+		if logger == nil {
+			return
+		}
+		o.logger = logger
+	}
+}
+
+// ------------------------------------------------------------------------------------------
+
+// RedisBroadcaster broadcaster based on redis pub/sub, presence of users is stored in redis hashes,
+// alive nodes keep a heartbeat key so that users of crashed nodes are not reported as online.
+type RedisBroadcaster struct {
+	client    *redis.Client
+	channel   string
+	keyPrefix string
+	nodeTTL   time.Duration
+	zapLogger *zap.Logger
+}
+
+// NewRedisBroadcaster create a redis pub/sub broadcaster
+func NewRedisBroadcaster(client *redis.Client, opts ...RedisBroadcasterOption) (*RedisBroadcaster, error) {
+	if client == nil {
+		return nil, errors.New("redis client can not be nil")
+	}
+
+	o := defaultRedisBroadcasterOptions()
+	o.apply(opts...)
+
+	return &RedisBroadcaster{
+		client:    client,
+		channel:   o.channel,
+		keyPrefix: o.keyPrefix,
+		nodeTTL:   o.nodeTTL,
+		zapLogger: o.logger,
+	}, nil
+}
+
+func (b *RedisBroadcaster) userKey(uid string) string {
+	return b.keyPrefix + "user:" + uid
+}
+
+func (b *RedisBroadcaster) nodeKey(nodeID string) string {
+	return b.keyPrefix + "node:" + nodeID
+}
+
+// Publish message to all nodes
+func (b *RedisBroadcaster) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %v", err)
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe messages published by all nodes, and keep the node heartbeat until ctx is done
+func (b *RedisBroadcaster) Subscribe(ctx context.Context, nodeID string, handler func(msg *BroadcastMessage)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	// wait for confirmation that subscription is created
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return fmt.Errorf("subscribe channel %s failed: %v", b.channel, err)
+	}
+
+	if err := b.heartbeat(ctx, nodeID); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(b.nodeTTL / 3)
+		defer ticker.Stop()
+		ch := pubsub.Channel()
+
+		for {
+			select {
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				msg := &BroadcastMessage{}
+				if err := json.Unmarshal([]byte(m.Payload), msg); err != nil {
+					b.zapLogger.Warn("[sse] unmarshal broadcast message failed", zap.Error(err))
+					continue
+				}
+				handler(msg)
+
+			case <-ticker.C:
+				if err := b.heartbeat(ctx, nodeID); err != nil && ctx.Err() == nil {
+					b.zapLogger.Warn("[sse] node heartbeat failed", zap.Error(err), zap.String("node_id", nodeID))
+				}
+
+			case <-ctx.Done():
+				_ = pubsub.Close()
+				cleanupCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+				_ = b.client.Del(cleanupCtx, b.nodeKey(nodeID)).Err()
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (b *RedisBroadcaster) heartbeat(ctx context.Context, nodeID string) error {
+	return b.client.Set(ctx, b.nodeKey(nodeID), time.Now().Unix(), b.nodeTTL).Err()
+}
+
+// Online mark the user as connected to the node
+func (b *RedisBroadcaster) Online(ctx context.Context, nodeID string, uid string) error {
+	pipe := b.client.TxPipeline()
+	pipe.HSet(ctx, b.userKey(uid), nodeID, time.Now().Unix())
+	// avoid keeping the presence of users forever if the nodes crashed
+	pipe.Expire(ctx, b.userKey(uid), 24*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Offline mark the user as disconnected from the node
+func (b *RedisBroadcaster) Offline(ctx context.Context, nodeID string, uid string) error {
+	return b.client.HDel(ctx, b.userKey(uid), nodeID).Err()
+}
+
+// Nodes list the alive nodes the user is connected to
+func (b *RedisBroadcaster) Nodes(ctx context.Context, uid string) ([]string, error) {
+	nodeIDs, err := b.client.HKeys(ctx, b.userKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeIDs) == 0 {
+		return nodeIDs, nil
+	}
+
+	keys := make([]string, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		keys = append(keys, b.nodeKey(nodeID))
+	}
+	values, err := b.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var aliveNodes, deadNodes []string
+	for i, v := range values {
+		if v == nil {
+			deadNodes = append(deadNodes, nodeIDs[i])
+			continue
+		}
+		aliveNodes = append(aliveNodes, nodeIDs[i])
+	}
+	if len(deadNodes) > 0 {
+		_ = b.client.HDel(ctx, b.userKey(uid), deadNodes...).Err()
+	}
+
+	return aliveNodes, nil
+}
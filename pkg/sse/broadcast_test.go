@@ -0,0 +1,191 @@
+package sse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testHubsFanOut(t *testing.T, b1 Broadcaster, b2 Broadcaster) {
+	hub1 := NewHub(WithBroadcaster(b1), WithNodeID("node1"), WithLogger(zap.NewNop()))
+	defer hub1.Close()
+	hub2 := NewHub(WithBroadcaster(b2), WithNodeID("node2"), WithLogger(zap.NewNop()))
+	defer hub2.Close()
+	assert.Equal(t, "node1", hub1.NodeID())
+
+	c1 := &UserClient{UID: "u1", Send: make(chan *Event, 10)}
+	c2 := &UserClient{UID: "u2", Send: make(chan *Event, 10)}
+	hub1.register <- c1
+	hub2.register <- c2
+	time.Sleep(100 * time.Millisecond)
+
+	ctx := context.Background()
+	nodes, err := hub1.OnlineNodes(ctx, "u2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node2"}, nodes)
+	online, err := hub2.IsOnline(ctx, "u3")
+	assert.NoError(t, err)
+	assert.False(t, online)
+
+	// push on node1, the user is connected to node2
+	err = hub1.Push([]string{"u2"}, &Event{ID: "e1", Event: "test", Data: "to u2"})
+	assert.NoError(t, err)
+	select {
+	case e := <-c2.Send:
+		assert.Equal(t, "e1", e.ID)
+		assert.Equal(t, "to u2", e.Data)
+	case <-time.After(time.Second):
+		t.Fatal("expected event pushed to client of other node but got timeout")
+	}
+
+	// broadcast on node2, every user receives the event only once
+	err = hub2.Push(nil, &Event{ID: "e2", Event: "test", Data: "to all"})
+	assert.NoError(t, err)
+	for _, cli := range []*UserClient{c1, c2} {
+		select {
+		case e := <-cli.Send:
+			assert.Equal(t, "e2", e.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("expected broadcast event to client %s, got timeout", cli.UID)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, c1.Send, 0)
+	assert.Len(t, c2.Send, 0)
+
+	hub2.unregister <- c2
+	time.Sleep(100 * time.Millisecond)
+	online, err = hub1.IsOnline(ctx, "u2")
+	assert.NoError(t, err)
+	assert.False(t, online)
+	hub1.unregister <- c1
+}
+
+func TestHubWithMemoryBroadcaster(t *testing.T) {
+	b := NewMemoryBroadcaster()
+	defer b.Close()
+	testHubsFanOut(t, b, b)
+}
+
+func TestHubWithRedisBroadcaster(t *testing.T) {
+	_, err := NewRedisBroadcaster(nil)
+	assert.Error(t, err)
+
+	client := newTestRedisClient(t)
+	b1, err := NewRedisBroadcaster(client, WithBroadcastChannel("test:broadcast"), WithNodeTTL(time.Second),
+		WithPresenceKeyPrefix("test:presence:"), WithBroadcasterLogger(zap.NewNop()))
+	require.NoError(t, err)
+	b2, err := NewRedisBroadcaster(client, WithBroadcastChannel("test:broadcast"), WithNodeTTL(time.Second),
+		WithPresenceKeyPrefix("test:presence:"), WithBroadcasterLogger(zap.NewNop()))
+	require.NoError(t, err)
+	testHubsFanOut(t, b1, b2)
+}
+
+func TestRedisBroadcasterDeadNode(t *testing.T) {
+	b, err := NewRedisBroadcaster(newTestRedisClient(t))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, b.Online(ctx, "dead-node", "u1"))
+	nodes, err := b.Nodes(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Empty(t, nodes)
+}
+
+func TestMemoryBroadcaster(t *testing.T) {
+	b := NewMemoryBroadcaster()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	received := make(chan *BroadcastMessage, 1)
+	err := b.Subscribe(ctx, "n1", func(msg *BroadcastMessage) { received <- msg })
+	assert.NoError(t, err)
+	err = b.Subscribe(ctx, "n1", func(msg *BroadcastMessage) {})
+	assert.Error(t, err)
+
+	assert.NoError(t, b.Online(ctx, "n1", "u1"))
+	assert.NoError(t, b.Publish(ctx, &BroadcastMessage{ID: "m1"}))
+	select {
+	case msg := <-received:
+		assert.Equal(t, "m1", msg.ID)
+	case <-time.After(time.Second):
+		t.Fatal("expected message but got timeout")
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	nodes, _ := b.Nodes(ctx, "u1")
+	assert.Empty(t, nodes)
+
+	assert.NoError(t, b.Close())
+	assert.Error(t, b.Publish(context.Background(), &BroadcastMessage{ID: "m2"}))
+}
+
+func TestDedupCache(t *testing.T) {
+	c := newDedupCache(2)
+	assert.False(t, c.seen("a"))
+	assert.True(t, c.seen("a"))
+	assert.False(t, c.seen("b"))
+	assert.False(t, c.seen("c")) // evict a
+	assert.False(t, c.seen("a"))
+	assert.True(t, c.seen("c"))
+}
+
+func TestMemoryBroadcasterPublishToLeftSubscriber(t *testing.T) {
+	b := NewMemoryBroadcaster()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the handler is blocked until the subscription is cancelled, so the buffer is full
+	err := b.Subscribe(ctx, "n1", func(msg *BroadcastMessage) { <-ctx.Done() })
+	require.NoError(t, err)
+	for i := 0; i < 1001; i++ {
+		require.NoError(t, b.Publish(context.Background(), &BroadcastMessage{ID: "m"}))
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.Publish(context.Background(), &BroadcastMessage{ID: "blocked"}) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("publish is blocked after the subscriber left")
+	}
+}
+
+// blockingBroadcaster blocks the presence updates
+type blockingBroadcaster struct {
+	*MemoryBroadcaster
+	release chan struct{}
+}
+
+func (b *blockingBroadcaster) Online(ctx context.Context, _ string, _ string) error {
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestHubSlowPresence(t *testing.T) {
+	b := &blockingBroadcaster{MemoryBroadcaster: NewMemoryBroadcaster(), release: make(chan struct{})}
+	defer close(b.release)
+	hub := NewHub(WithBroadcaster(b), WithPushBufferSize(1), WithLogger(zap.NewNop()))
+	defer hub.Close()
+
+	// the run loop is not blocked by the slow presence updates
+	for i := 0; i < 5; i++ {
+		select {
+		case hub.register <- &UserClient{UID: "u" + string(rune('0'+i)), Send: make(chan *Event, 10)}:
+		case <-time.After(time.Second):
+			t.Fatal("register is blocked by presence updates")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 5, hub.OnlineClientsNum())
+}
@@ -0,0 +1,236 @@
+package sse
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Channel names are made up of segments separated by ":", e.g. order:123, tenant:acme:user.
+// When subscribing, two wildcards are supported:
+//   - "*" matches exactly one segment, e.g. order:* matches order:123, but not order:123:item
+//   - "**" matches one or more segments, only allowed as the last segment, e.g. tenant:acme:** matches tenant:acme:user:1
+const channelSeparator = ":"
+
+// ChannelAuthorizer check if the user is allowed to subscribe the channel, return nil if allowed,
+// the channel may contain wildcards, e.g. tenant:*, **, reject it if it is broader than allowed.
+type ChannelAuthorizer func(uid string, channel string) error
+
+// ChannelHook called after the user subscribes or unsubscribes the channel
+type ChannelHook func(uid string, channel string)
+
+type channelAuthorizer struct {
+	pattern string
+	fn      ChannelAuthorizer
+}
+
+// ErrChannelUnauthorized the user is not allowed to subscribe the channel
+var ErrChannelUnauthorized = errors.New("unauthorized channel")
+
+func isWildcardChannel(channel string) bool {
+	for _, seg := range strings.Split(channel, channelSeparator) {
+		if seg == "*" || seg == "**" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChannel check the channel name, wildcard is allowed only if allowWildcard is true
+func checkChannel(channel string, allowWildcard bool) error {
+	if channel == "" {
+		return errors.New("channel can not be empty")
+	}
+	segs := strings.Split(channel, channelSeparator)
+	for i, seg := range segs {
+		if seg == "" {
+			return errors.New("invalid channel " + channel + ", empty segment")
+		}
+		if seg == "*" || seg == "**" {
+			if !allowWildcard {
+				return errors.New("invalid channel " + channel + ", wildcard is not allowed")
+			}
+			if seg == "**" && i != len(segs)-1 {
+				return errors.New("invalid channel " + channel + ", ** must be the last segment")
+			}
+		}
+	}
+	return nil
+}
+
+// matchChannel check if the channel matches the pattern
+func matchChannel(pattern string, channel string) bool {
+	if pattern == channel {
+		return true
+	}
+
+	ps := strings.Split(pattern, channelSeparator)
+	cs := strings.Split(channel, channelSeparator)
+	for i, p := range ps {
+		if p == "**" {
+			return i == len(ps)-1 && len(cs) > i
+		}
+		if i >= len(cs) {
+			return false
+		}
+		if p != "*" && p != cs[i] {
+			return false
+		}
+	}
+	return len(ps) == len(cs)
+}
+
+// overlapChannel check if the two patterns can match a common channel, both may contain wildcards,
+// e.g. tenant:* and *:acme overlap on tenant:acme, ** overlaps with every pattern.
+func overlapChannel(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	as := strings.Split(a, channelSeparator)
+	bs := strings.Split(b, channelSeparator)
+	for i := 0; ; i++ {
+		switch {
+		case i < len(as) && as[i] == "**":
+			return len(bs) > i
+		case i < len(bs) && bs[i] == "**":
+			return len(as) > i
+		case i >= len(as) || i >= len(bs):
+			return len(as) == len(bs)
+		case as[i] != "*" && bs[i] != "*" && as[i] != bs[i]:
+			return false
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------
+
+// channelRegistry records the channels subscribed by users of the current node
+type channelRegistry struct {
+	mu       sync.RWMutex
+	exact    map[string]map[string]struct{} // channel -> uids
+	wildcard map[string]map[string]struct{} // pattern -> uids
+	users    map[string]map[string]struct{} // uid -> channels
+}
+
+func newChannelRegistry() *channelRegistry {
+	return &channelRegistry{
+		exact:    make(map[string]map[string]struct{}),
+		wildcard: make(map[string]map[string]struct{}),
+		users:    make(map[string]map[string]struct{}),
+	}
+}
+
+func (r *channelRegistry) index(channel string) map[string]map[string]struct{} {
+	if isWildcardChannel(channel) {
+		return r.wildcard
+	}
+	return r.exact
+}
+
+// add subscription, return false if it already exists
+func (r *channelRegistry) add(uid string, channel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channels, ok := r.users[uid]
+	if !ok {
+		channels = make(map[string]struct{})
+		r.users[uid] = channels
+	}
+	if _, ok = channels[channel]; ok {
+		return false
+	}
+	channels[channel] = struct{}{}
+
+	idx := r.index(channel)
+	uids, ok := idx[channel]
+	if !ok {
+		uids = make(map[string]struct{})
+		idx[channel] = uids
+	}
+	uids[uid] = struct{}{}
+	return true
+}
+
+// remove subscription, return false if it does not exist
+func (r *channelRegistry) remove(uid string, channel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removeLocked(uid, channel)
+}
+
+func (r *channelRegistry) removeLocked(uid string, channel string) bool {
+	channels, ok := r.users[uid]
+	if !ok {
+		return false
+	}
+	if _, ok = channels[channel]; !ok {
+		return false
+	}
+	delete(channels, channel)
+	if len(channels) == 0 {
+		delete(r.users, uid)
+	}
+
+	idx := r.index(channel)
+	if uids, ok := idx[channel]; ok {
+		delete(uids, uid)
+		if len(uids) == 0 {
+			delete(idx, channel)
+		}
+	}
+	return true
+}
+
+// removeUser remove all subscriptions of the user, return the removed channels
+func (r *channelRegistry) removeUser(uid string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channels := make([]string, 0, len(r.users[uid]))
+	for channel := range r.users[uid] {
+		channels = append(channels, channel)
+	}
+	for _, channel := range channels {
+		r.removeLocked(uid, channel)
+	}
+	return channels
+}
+
+// channels list the channels subscribed by the user
+func (r *channelRegistry) channels(uid string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	channels := make([]string, 0, len(r.users[uid]))
+	for channel := range r.users[uid] {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// match list the users who subscribed the channel directly or by wildcard, each user appears only once
+func (r *channelRegistry) match(channel string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := make(map[string]struct{})
+	for uid := range r.exact[channel] {
+		set[uid] = struct{}{}
+	}
+	for pattern, uids := range r.wildcard {
+		if !matchChannel(pattern, channel) {
+			continue
+		}
+		for uid := range uids {
+			set[uid] = struct{}{}
+		}
+	}
+
+	uids := make([]string, 0, len(set))
+	for uid := range set {
+		uids = append(uids, uid)
+	}
+	return uids
+}
@@ -0,0 +1,217 @@
+package sse
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMatchChannel(t *testing.T) {
+	tests := []struct {
+		pattern string
+		channel string
+		want    bool
+	}{
+		{"order:123", "order:123", true},
+		{"order:123", "order:124", false},
+		{"order:*", "order:123", true},
+		{"order:*", "order", false},
+		{"order:*", "order:123:item", false},
+		{"*:123", "order:123", true},
+		{"tenant:acme:**", "tenant:acme:user", true},
+		{"tenant:acme:**", "tenant:acme:user:1", true},
+		{"tenant:acme:**", "tenant:acme", false},
+		{"tenant:*:**", "tenant:acme:user:1", true},
+		{"**", "order:123", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchChannel(tt.pattern, tt.channel), "%s %s", tt.pattern, tt.channel)
+	}
+}
+
+func TestOverlapChannel(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want bool
+	}{
+		{"tenant:*", "tenant:acme", true},
+		{"tenant:*", "**", true},
+		{"tenant:*", "*:acme", true},
+		{"tenant:*", "*:*", true},
+		{"tenant:*", "*", false},
+		{"tenant:*", "tenant:acme:user", false},
+		{"tenant:*", "tenant:**", true},
+		{"tenant:*", "order:*", false},
+		{"tenant:*", "*:acme:**", false},
+		{"tenant:**", "*:acme:user", true},
+		{"admin:**", "admin", false},
+		{"**", "order", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, overlapChannel(tt.a, tt.b), "%s %s", tt.a, tt.b)
+		assert.Equal(t, tt.want, overlapChannel(tt.b, tt.a), "%s %s", tt.b, tt.a)
+	}
+}
+
+func TestCheckChannel(t *testing.T) {
+	assert.NoError(t, checkChannel("order:123", false))
+	assert.NoError(t, checkChannel("order:*", true))
+	assert.NoError(t, checkChannel("order:**", true))
+	assert.Error(t, checkChannel("", true))
+	assert.Error(t, checkChannel("order::1", true))
+	assert.Error(t, checkChannel("order:*", false))
+	assert.Error(t, checkChannel("order:**:1", true))
+}
+
+func TestChannelRegistry(t *testing.T) {
+	r := newChannelRegistry()
+	assert.True(t, r.add("u1", "order:1"))
+	assert.False(t, r.add("u1", "order:1"))
+	assert.True(t, r.add("u1", "order:*"))
+	assert.True(t, r.add("u2", "order:1"))
+
+	uids := r.match("order:1")
+	sort.Strings(uids)
+	assert.Equal(t, []string{"u1", "u2"}, uids)
+	assert.Equal(t, []string{"u1"}, r.match("order:2"))
+
+	assert.True(t, r.remove("u2", "order:1"))
+	assert.False(t, r.remove("u2", "order:1"))
+	assert.Equal(t, []string{"u1"}, r.match("order:1"))
+
+	channels := r.removeUser("u1")
+	assert.Len(t, channels, 2)
+	assert.Empty(t, r.match("order:1"))
+	assert.Empty(t, r.channels("u1"))
+}
+
+func TestHubPublish(t *testing.T) {
+	var mu sync.Mutex
+	subscribed := map[string]int{}
+	unsubscribed := map[string]int{}
+
+	hub := NewHub(
+		WithLogger(zap.NewNop()),
+		WithChannelAuthorizer("tenant:*", func(uid string, channel string) error {
+			if channel != "tenant:"+uid {
+				return errors.New("not a member of the tenant")
+			}
+			return nil
+		}),
+		WithSubscribeHook(func(uid string, channel string) {
+			mu.Lock()
+			subscribed[channel]++
+			mu.Unlock()
+		}),
+		WithUnsubscribeHook(func(uid string, channel string) {
+			mu.Lock()
+			unsubscribed[channel]++
+			mu.Unlock()
+		}),
+	)
+	defer hub.Close()
+
+	err := hub.Subscribe("u1", "order:1")
+	assert.Error(t, err) // user is not online
+
+	c1 := &UserClient{UID: "u1", Send: make(chan *Event, 10)}
+	c2 := &UserClient{UID: "u2", Send: make(chan *Event, 10)}
+	hub.register <- c1
+	hub.register <- c2
+	time.Sleep(20 * time.Millisecond)
+
+	assert.NoError(t, hub.Subscribe("u1", "order:1", "tenant:u1"))
+	assert.NoError(t, hub.Subscribe("u2", "order:*"))
+	assert.ErrorIs(t, hub.Subscribe("u2", "tenant:u1"), ErrChannelUnauthorized)
+	// the wildcard subscriptions broader than allowed are checked by the authorizer
+	assert.ErrorIs(t, hub.Subscribe("u2", "**"), ErrChannelUnauthorized)
+	assert.ErrorIs(t, hub.Subscribe("u2", "*:u1"), ErrChannelUnauthorized)
+	assert.ErrorIs(t, hub.Subscribe("u2", "tenant:*"), ErrChannelUnauthorized)
+	assert.Len(t, hub.Channels("u1"), 2)
+
+	assert.Error(t, hub.Publish("order:*", &Event{Event: "test", Data: "x"}))
+
+	assert.NoError(t, hub.Publish("order:1", &Event{ID: "e1", Event: "test", Data: "order"}))
+	for _, cli := range []*UserClient{c1, c2} {
+		select {
+		case e := <-cli.Send:
+			assert.Equal(t, "e1", e.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("expected event to client %s, got timeout", cli.UID)
+		}
+	}
+
+	// only u1 subscribed the tenant channel, the event is not broadcast to all users
+	assert.NoError(t, hub.Publish("tenant:u1", &Event{ID: "e2", Event: "test", Data: "tenant"}))
+	assert.NoError(t, hub.Publish("nobody", &Event{ID: "e3", Event: "test", Data: "nobody"}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, c1.Send, 1)
+	assert.Len(t, c2.Send, 0)
+
+	hub.Unsubscribe("u1", "order:1")
+	hub.unregister <- c2
+	hub.unregister <- c1
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, map[string]int{"order:1": 1, "tenant:u1": 1, "order:*": 1}, subscribed)
+	assert.Equal(t, map[string]int{"order:1": 1, "tenant:u1": 1, "order:*": 1}, unsubscribed)
+	mu.Unlock()
+}
+
+func TestHubPublishAcrossNodes(t *testing.T) {
+	b := NewMemoryBroadcaster()
+	hub1 := NewHub(WithBroadcaster(b), WithNodeID("node1"), WithLogger(zap.NewNop()))
+	defer hub1.Close()
+	hub2 := NewHub(WithBroadcaster(b), WithNodeID("node2"), WithLogger(zap.NewNop()))
+	defer hub2.Close()
+
+	c := &UserClient{UID: "u1", Send: make(chan *Event, 10)}
+	hub2.register <- c
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, hub2.Subscribe("u1", "order:**"))
+
+	assert.NoError(t, hub1.Publish("order:1:paid", &Event{ID: "e1", Event: "test", Data: "paid"}))
+	select {
+	case e := <-c.Send:
+		assert.Equal(t, "e1", e.ID)
+	case <-time.After(time.Second):
+		t.Fatal("expected event to client of other node, got timeout")
+	}
+	hub2.unregister <- c
+}
+
+func TestServeUnauthorizedChannel(t *testing.T) {
+	hub := NewHub(
+		WithLogger(zap.NewNop()),
+		WithChannelAuthorizer("admin:**", func(uid string, channel string) error {
+			return errors.New("forbidden")
+		}),
+	)
+	defer hub.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/events", func(c *gin.Context) {
+		hub.Serve(c, "u1", WithServeChannels("order:1"))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events?channels=admin:log", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/events?channels=order::1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
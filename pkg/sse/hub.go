@@ -26,6 +26,13 @@ type hubOptions struct {
 	pushBufferSize     int
 	pushFailedHandleFn func(uid string, event *Event)
 	workerNum          int
+
+	broadcaster Broadcaster
+	nodeID      string
+
+	channelAuthorizers []channelAuthorizer
+	onSubscribe        ChannelHook
+	onUnsubscribe      ChannelHook
 }
 
 func defaultHubOptions() *hubOptions {
@@ -38,6 +45,7 @@ func defaultHubOptions() *hubOptions {
 		logger:         zapLogger,
 		pushBufferSize: 1000,
 		workerNum:      10,
+		nodeID:         defaultNodeID(),
 	}
 }
 
@@ -110,6 +118,52 @@ func WithWorkerNum(num int) HubOption {
 	}
 }
 
+// WithBroadcaster set broadcaster, events pushed on any node are delivered to the
+// users wherever they are connected, and the presence of users is tracked.
+func WithBroadcaster(b Broadcaster) HubOption {
+	return func(h *hubOptions) {
+		h.broadcaster = b
+	}
+}
+
+// WithNodeID set the unique id of the current node, default is hostname with a random suffix
+func WithNodeID(id string) HubOption {
+	return func(h *hubOptions) {
+		if id == "" {
+			return
+		}
+		h.nodeID = id
+	}
+}
+
+// WithChannelAuthorizer set authorization callback for the channels matching the pattern,
+// pattern supports wildcards, e.g. tenant:*, order:**. The authorizer is called if the subscribed
+// channel can match a common channel with the pattern, e.g. tenant:* is checked for tenant:acme,
+// *:acme and **, so the subscribed channel passed to fn may contain wildcards. If multiple
+// authorizers match the channel, all of them must pass, if no authorizer matches, subscription is allowed.
+func WithChannelAuthorizer(pattern string, fn ChannelAuthorizer) HubOption {
+	return func(h *hubOptions) {
+		if pattern == "" || fn == nil {
+			return
+		}
+		h.channelAuthorizers = append(h.channelAuthorizers, channelAuthorizer{pattern: pattern, fn: fn})
+	}
+}
+
+// WithSubscribeHook set hook called after the user subscribes a channel
+func WithSubscribeHook(fn ChannelHook) HubOption {
+	return func(h *hubOptions) {
+		h.onSubscribe = fn
+	}
+}
+
+// WithUnsubscribeHook set hook called after the user unsubscribes a channel, including disconnection
+func WithUnsubscribeHook(fn ChannelHook) HubOption {
+	return func(h *hubOptions) {
+		h.onUnsubscribe = fn
+	}
+}
+
 // ------------------------------------------------------------------------------------------
 
 // UserEvent user event
@@ -135,11 +189,26 @@ type Hub struct {
 	pushBufferSize     int
 	pushFailedHandleFn func(uid string, event *Event)
 
+	broadcaster Broadcaster
+	nodeID      string
+	dedup       *dedupCache
+	presence    chan *presenceChange
+
+	channels           *channelRegistry
+	channelAuthorizers []channelAuthorizer
+	onSubscribe        ChannelHook
+	onUnsubscribe      ChannelHook
+
 	ctx       context.Context
 	cancel    context.CancelFunc
 	zapLogger *zap.Logger
 }
 
+type presenceChange struct {
+	uid    string
+	online bool
+}
+
 // NewHub create a new event center
 func NewHub(opts ...HubOption) *Hub {
 	o := defaultHubOptions()
@@ -161,7 +230,25 @@ func NewHub(opts ...HubOption) *Hub {
 		cancel:             o.cancel,
 		zapLogger:          o.logger,
 		enableResendEvents: o.enableResendEvents,
+
+		broadcaster: o.broadcaster,
+		nodeID:      o.nodeID,
+
+		channels:           newChannelRegistry(),
+		channelAuthorizers: o.channelAuthorizers,
+		onSubscribe:        o.onSubscribe,
+		onUnsubscribe:      o.onUnsubscribe,
+	}
+
+	if h.broadcaster != nil {
+		h.dedup = newDedupCache(10000)
+		h.presence = make(chan *presenceChange, o.pushBufferSize)
+		if err := h.broadcaster.Subscribe(h.ctx, h.nodeID, h.handleBroadcastMessage); err != nil {
+			h.zapLogger.Error("[sse] subscribe broadcast messages failed", zap.Error(err), zap.String("node_id", h.nodeID))
+		}
+		go h.runPresence()
 	}
+
 	go h.run()
 	return h
 }
@@ -171,12 +258,15 @@ func (h *Hub) run() {
 		select {
 		case cli := <-h.register:
 			h.clients.Set(cli.UID, cli)
+			h.changePresence(cli.UID, true)
 			h.zapLogger.Info("[sse] user connected", zap.String("uid", cli.UID))
 
 		case cli := <-h.unregister:
 			if h.clients.Has(cli.UID) {
 				h.clients.Delete(cli.UID)
 				close(cli.Send)
+				h.changePresence(cli.UID, false)
+				h.unsubscribeAll(cli.UID)
 				h.zapLogger.Info("[sse] user disconnected", zap.String("uid", cli.UID))
 			}
 
@@ -198,8 +288,22 @@ func (h *Hub) run() {
 	}
 }
 
-// Push to specified users or all online users
+// Push to specified users or all online users, if broadcaster is set,
+// users connected to other nodes also receive the events.
 func (h *Hub) Push(uids []string, events ...*Event) error {
+	return h.push(uids, "", true, events...)
+}
+
+// Publish events to the users who subscribed the channel directly or by wildcard,
+// wildcard is not allowed in the channel.
+func (h *Hub) Publish(channel string, events ...*Event) error {
+	if err := checkChannel(channel, false); err != nil {
+		return err
+	}
+	return h.push(nil, channel, true, events...)
+}
+
+func (h *Hub) push(uids []string, channel string, isBroadcast bool, events ...*Event) error {
 	if len(events) == 0 {
 		return errors.New("events can not be empty")
 	}
@@ -218,26 +322,103 @@ func (h *Hub) Push(uids []string, events ...*Event) error {
 			}
 		}
 
-		if len(uids) > 0 {
-			// push to specified users
-			for _, uid := range uids {
-				if !h.clients.Has(uid) {
-					continue
-				}
-				pushOne(h, &UserEvent{
-					UID:   uid,
-					Event: e,
-				})
-			}
-		} else {
-			// push to all online users
-			pushAll(h, e)
+		pushLocal(h, uids, channel, e)
+	}
+
+	if h.broadcaster != nil && isBroadcast {
+		msg := &BroadcastMessage{
+			ID:      newStringID(),
+			NodeID:  h.nodeID,
+			UIDs:    uids,
+			Channel: channel,
+			Events:  events,
+		}
+		h.dedup.seen(msg.ID)
+		if err := h.broadcaster.Publish(h.ctx, msg); err != nil {
+			return fmt.Errorf("publish events failed: %v", err)
 		}
 	}
 
 	return nil
 }
 
+// push events to users connected to the current node
+func pushLocal(h *Hub, uids []string, channel string, e *Event) {
+	if channel != "" {
+		// push to users who subscribed the channel, never fall back to all users
+		uids = h.channels.match(channel)
+		if len(uids) == 0 {
+			return
+		}
+	}
+
+	if len(uids) > 0 {
+		// push to specified users
+		for _, uid := range uids {
+			if !h.clients.Has(uid) {
+				continue
+			}
+			pushOne(h, &UserEvent{
+				UID:   uid,
+				Event: e,
+			})
+		}
+	} else {
+		// push to all online users
+		pushAll(h, e)
+	}
+}
+
+// handle the messages published by other nodes, the events have been saved by the publisher
+func (h *Hub) handleBroadcastMessage(msg *BroadcastMessage) {
+	if msg.NodeID == h.nodeID || h.dedup.seen(msg.ID) {
+		return
+	}
+	for _, e := range msg.Events {
+		if e == nil {
+			continue
+		}
+		pushLocal(h, msg.UIDs, msg.Channel, e)
+	}
+}
+
+func (h *Hub) changePresence(uid string, online bool) {
+	if h.broadcaster == nil {
+		return
+	}
+	// don't block the run loop if the presence worker is slow, e.g. redis is slow
+	select {
+	case h.presence <- &presenceChange{uid: uid, online: online}:
+	default:
+		h.zapLogger.Warn("[sse] presence buffer is full, drop the presence change",
+			zap.String("uid", uid), zap.Bool("online", online))
+	}
+}
+
+// update presence serially to keep the order of online and offline
+func (h *Hub) runPresence() {
+	for {
+		select {
+		case pc := <-h.presence:
+			ctx, cancel := context.WithTimeout(h.ctx, 5*time.Second)
+			var err error
+			if pc.online {
+				err = h.broadcaster.Online(ctx, h.nodeID, pc.uid)
+			} else {
+				err = h.broadcaster.Offline(ctx, h.nodeID, pc.uid)
+			}
+			cancel()
+			if err != nil {
+				h.zapLogger.Warn("[sse] update presence failed", zap.Error(err),
+					zap.String("uid", pc.uid), zap.Bool("online", pc.online))
+			}
+
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
 func pushOne(h *Hub, ue *UserEvent) {
 	h.PushStats.IncTotal()
 
@@ -366,11 +547,102 @@ func (h *Hub) PushHeartBeat(uid string) {
 	})
 }
 
-// OnlineClientsNum get online clients num
+// Subscribe the channels for the online user of the current node, the channels are checked by
+// the authorizers, wildcards are allowed. The subscriptions are removed when the user disconnects.
+func (h *Hub) Subscribe(uid string, channels ...string) error {
+	if !h.clients.Has(uid) {
+		return fmt.Errorf("user %s is not online", uid)
+	}
+	if err := h.authorizeChannels(uid, channels...); err != nil {
+		return err
+	}
+	h.subscribe(uid, channels...)
+	return nil
+}
+
+// Unsubscribe the channels for the user
+func (h *Hub) Unsubscribe(uid string, channels ...string) {
+	for _, channel := range channels {
+		if h.channels.remove(uid, channel) && h.onUnsubscribe != nil {
+			h.onUnsubscribe(uid, channel)
+		}
+	}
+}
+
+// Channels list the channels subscribed by the user
+func (h *Hub) Channels(uid string) []string {
+	return h.channels.channels(uid)
+}
+
+func (h *Hub) authorizeChannels(uid string, channels ...string) error {
+	for _, channel := range channels {
+		if err := checkChannel(channel, true); err != nil {
+			return err
+		}
+		// the subscription with wildcards, e.g. ** or *:acme, is checked by every authorizer
+		// whose pattern overlaps with it, so it can't bypass the authorizers
+		for _, a := range h.channelAuthorizers {
+			if !overlapChannel(a.pattern, channel) {
+				continue
+			}
+			if err := a.fn(uid, channel); err != nil {
+				return fmt.Errorf("%w: %s, %v", ErrChannelUnauthorized, channel, err)
+			}
+		}
+	}
+	return nil
+}
+
+// subscribe the channels without authorization
+func (h *Hub) subscribe(uid string, channels ...string) {
+	for _, channel := range channels {
+		if h.channels.add(uid, channel) && h.onSubscribe != nil {
+			h.onSubscribe(uid, channel)
+		}
+	}
+}
+
+func (h *Hub) unsubscribeAll(uid string) {
+	channels := h.channels.removeUser(uid)
+	if h.onUnsubscribe == nil {
+		return
+	}
+	for _, channel := range channels {
+		h.onUnsubscribe(uid, channel)
+	}
+}
+
+// OnlineClientsNum get online clients num of the current node
 func (h *Hub) OnlineClientsNum() int {
 	return h.clients.Len()
 }
 
+// NodeID get the id of the current node
+func (h *Hub) NodeID() string {
+	return h.nodeID
+}
+
+// IsOnline check if the user is connected to any node, if broadcaster is not set,
+// only the current node is checked.
+func (h *Hub) IsOnline(ctx context.Context, uid string) (bool, error) {
+	nodeIDs, err := h.OnlineNodes(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	return len(nodeIDs) > 0, nil
+}
+
+// OnlineNodes list the ids of the nodes the user is connected to
+func (h *Hub) OnlineNodes(ctx context.Context, uid string) ([]string, error) {
+	if h.broadcaster == nil {
+		if h.clients.Has(uid) {
+			return []string{h.nodeID}, nil
+		}
+		return nil, nil
+	}
+	return h.broadcaster.Nodes(ctx, uid)
+}
+
 // Close event center and stop all worker,
 // By default, send a shutdown event to the client. If you want the client
 // to automatically reconnect, please set the tryToReconnect parameter to false.
@@ -380,7 +652,7 @@ func (h *Hub) Close(tryToReconnect ...bool) {
 
 		noTryToReconnect := false
 		if len(tryToReconnect) == 0 || tryToReconnect[0] {
-			_ = h.Push(nil, CloseEvent())
+			_ = h.push(nil, "", false, CloseEvent()) // only close the clients of the current node
 			noTryToReconnect = true
 		}
 
@@ -2,9 +2,11 @@ package sse
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +19,7 @@ type ServeOption func(*serveOptions)
 
 type serveOptions struct {
 	extraHeaders map[string]string
+	channels     []string
 }
 
 func defaultServeOptions() *serveOptions {
@@ -36,6 +39,14 @@ func WithServeExtraHeaders(headers map[string]string) ServeOption {
 	}
 }
 
+// WithServeChannels sets the channels subscribed when the client connects, in addition to
+// the channels specified by the query parameter channels (comma separated).
+func WithServeChannels(channels ...string) ServeOption {
+	return func(o *serveOptions) {
+		o.channels = append(o.channels, channels...)
+	}
+}
+
 // -------------------------------------------------------------------------------------------
 
 // Serve serves a client connection
@@ -48,6 +59,23 @@ func (h *Hub) Serve(c *gin.Context, uid string, opts ...ServeOption) {
 	o := defaultServeOptions()
 	o.apply(opts...)
 
+	channels := o.channels
+	if v := c.Query("channels"); v != "" {
+		for _, channel := range strings.Split(v, ",") {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	if err := h.authorizeChannels(uid, channels...); err != nil {
+		if errors.Is(err, ErrChannelUnauthorized) {
+			responseCode403(c, err.Error())
+		} else {
+			responseCode400(c, err.Error())
+		}
+		return
+	}
+
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
@@ -73,6 +101,7 @@ func (h *Hub) Serve(c *gin.Context, uid string, opts ...ServeOption) {
 	defer func() {
 		h.unregister <- client
 	}()
+	h.subscribe(uid, channels...)
 
 	eventType := DefaultEventType
 	if et := c.Query("event_type"); et != "" {
@@ -142,10 +171,11 @@ func (h *Hub) ServeHandler(opts ...ServeOption) func(c *gin.Context) {
 	}
 }
 
-// PushRequest push request
+// PushRequest push request, if channel is not empty, publish events to the channel and uids are ignored
 type PushRequest struct {
-	UIDs   []string `json:"uids"`
-	Events []*Event `json:"events"`
+	UIDs    []string `json:"uids"`
+	Channel string   `json:"channel"`
+	Events  []*Event `json:"events"`
 }
 
 // PushEventHandler gin handler for push event request
@@ -157,7 +187,13 @@ func (h *Hub) PushEventHandler() func(c *gin.Context) {
 			return
 		}
 
-		if err := h.Push(req.UIDs, req.Events...); err != nil {
+		var err error
+		if req.Channel != "" {
+			err = h.Publish(req.Channel, req.Events...)
+		} else {
+			err = h.Push(req.UIDs, req.Events...)
+		}
+		if err != nil {
 			responseCode400(c, err.Error())
 			return
 		}
@@ -211,6 +247,10 @@ func responseCode400(c *gin.Context, msg string) {
 	c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "msg": msg, "data": struct{}{}})
 }
 
+func responseCode403(c *gin.Context, msg string) {
+	c.JSON(http.StatusForbidden, gin.H{"code": http.StatusForbidden, "msg": msg, "data": struct{}{}})
+}
+
 func responseCode200(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "ok", "data": struct{}{}})
 }
@@ -0,0 +1,166 @@
+package sse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StoreOption store option
+type StoreOption func(*storeOptions)
+
+type storeOptions struct {
+	// events older than maxAge are deleted, 0 means no limit
+	maxAge time.Duration
+	// the maximum number of events retained for each event type, 0 means no limit
+	maxEventsPerType int
+	// the interval at which the background pruner runs
+	pruneInterval time.Duration
+
+	tableName string // used by gorm store
+	keyPrefix string // used by redis store
+	logger    *zap.Logger
+}
+
+var (
+	defaultStoreLogger     *zap.Logger
+	defaultStoreLoggerOnce sync.Once
+)
+
+func defaultStoreOptions() *storeOptions {
+	return &storeOptions{
+		pruneInterval: time.Minute,
+		tableName:     "sse_event",
+		keyPrefix:     "sse:event:",
+	}
+}
+
+// apply the options, the default logger is created only once and only if no logger is set
+func (o *storeOptions) apply(opts ...StoreOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		defaultStoreLoggerOnce.Do(func() {
+			defaultStoreLogger, _ = zap.NewProduction()
+		})
+		o.logger = defaultStoreLogger
+	}
+}
+
+// WithStoreMaxAge set the maximum age of events, expired events are deleted by the pruner
+func WithStoreMaxAge(d time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		if d < 0 {
+			return
+		}
+		o.maxAge = d
+	}
+}
+
+// WithStoreMaxEventsPerType set the maximum number of events retained for each event type
+func WithStoreMaxEventsPerType(n int) StoreOption {
+	return func(o *storeOptions) {
+		if n < 0 {
+			return
+		}
+		o.maxEventsPerType = n
+	}
+}
+
+// WithStorePruneInterval set the interval of the background pruner, default is 1 minute
+func WithStorePruneInterval(d time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		if d <= 0 {
+			return
+		}
+		o.pruneInterval = d
+	}
+}
+
+// WithStoreTableName set table name of gorm store, default is sse_event
+func WithStoreTableName(name string) StoreOption {
+	return func(o *storeOptions) {
+		if name == "" {
+			return
+		}
+		o.tableName = name
+	}
+}
+
+// WithStoreKeyPrefix set key prefix of redis store, default is sse:event:
+func WithStoreKeyPrefix(prefix string) StoreOption {
+	return func(o *storeOptions) {
+		if prefix == "" {
+			return
+		}
+		o.keyPrefix = prefix
+	}
+}
+
+// WithStoreLogger set store logger
+func WithStoreLogger(logger *zap.Logger) StoreOption {
+	return func(o *storeOptions) {
+		if logger == nil {
+			return
+		}
+		o.logger = logger
+	}
+}
+
+func (o *storeOptions) hasRetention() bool {
+	return o.maxAge > 0 || o.maxEventsPerType > 0
+}
+
+// ------------------------------------------------------------------------------------------
+
+// pruner runs the prune function periodically until stopped
+type pruner struct {
+	interval time.Duration
+	pruneFn  func(ctx context.Context) error
+	logger   *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	done   chan struct{}
+}
+
+func newPruner(interval time.Duration, pruneFn func(ctx context.Context) error, logger *zap.Logger) *pruner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &pruner{
+		interval: interval,
+		pruneFn:  pruneFn,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *pruner) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.pruneFn(p.ctx); err != nil && p.ctx.Err() == nil {
+				p.logger.Warn("[sse] prune events failed", zap.Error(err))
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *pruner) stop() {
+	p.once.Do(func() {
+		p.cancel()
+		<-p.done
+	})
+}
@@ -0,0 +1,172 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	json "github.com/bytedance/sonic"
+
+	"github.com/go-dev-frame/sponge/pkg/sgorm"
+)
+
+// gormEvent table structure of the event, seq is used to keep the order of events
+type gormEvent struct {
+	Seq       uint64    `gorm:"column:seq;primaryKey;autoIncrement"`
+	EventID   string    `gorm:"column:event_id;type:varchar(64);index;not null"`
+	EventType string    `gorm:"column:event_type;type:varchar(128);index;not null"`
+	Data      string    `gorm:"column:data;type:text"`
+	CreatedAt time.Time `gorm:"column:created_at;index"`
+}
+
+// GormStore event store based on gorm, supports mysql, postgresql, sqlite and tidb
+type GormStore struct {
+	db        *sgorm.DB
+	tableName string
+
+	maxAge           time.Duration
+	maxEventsPerType int
+	pruner           *pruner
+}
+
+// NewGormStore create a gorm store, the table is created automatically if it does not exist.
+// if retention policies are set, a background pruner is started, call Close to stop it.
+func NewGormStore(db *sgorm.DB, opts ...StoreOption) (*GormStore, error) {
+	if db == nil {
+		return nil, errors.New("db can not be nil")
+	}
+
+	o := defaultStoreOptions()
+	o.apply(opts...)
+
+	s := &GormStore{
+		db:               db,
+		tableName:        o.tableName,
+		maxAge:           o.maxAge,
+		maxEventsPerType: o.maxEventsPerType,
+	}
+
+	if err := db.Table(s.tableName).AutoMigrate(&gormEvent{}); err != nil {
+		return nil, fmt.Errorf("auto migrate table %s failed: %v", s.tableName, err)
+	}
+
+	if o.hasRetention() {
+		s.pruner = newPruner(o.pruneInterval, s.Prune, o.logger)
+		go s.pruner.run()
+	}
+
+	return s, nil
+}
+
+// Save event to database
+func (s *GormStore) Save(ctx context.Context, e *Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %v", err)
+	}
+
+	record := &gormEvent{
+		EventID:   e.ID,
+		EventType: e.Event,
+		Data:      string(data),
+		CreatedAt: time.Now(),
+	}
+	return s.db.WithContext(ctx).Table(s.tableName).Create(record).Error
+}
+
+// ListByLastID list events after lastID by event type, if lastID is empty or does not exist
+// (e.g. it has been pruned), list from the oldest retained event.
+func (s *GormStore) ListByLastID(ctx context.Context, eventType string, lastID string, pageSize int) ([]*Event, string, error) {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	query := s.db.WithContext(ctx).Table(s.tableName).Where("event_type = ?", eventType)
+	if lastID != "" {
+		var seqs []uint64
+		err := s.db.WithContext(ctx).Table(s.tableName).
+			Where("event_type = ? AND event_id = ?", eventType, lastID).
+			Order("seq DESC").Limit(1).Pluck("seq", &seqs).Error
+		if err != nil {
+			return nil, "", err
+		}
+		if len(seqs) > 0 {
+			query = query.Where("seq > ?", seqs[0])
+		}
+	}
+
+	var records []*gormEvent
+	err := query.Order("seq ASC").Limit(pageSize + 1).Find(&records).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextID := ""
+	if len(records) > pageSize {
+		records = records[:pageSize]
+		nextID = records[pageSize-1].EventID
+	}
+
+	events := make([]*Event, 0, len(records))
+	for _, record := range records {
+		e := &Event{ID: record.EventID, Event: record.EventType}
+		if err = json.Unmarshal([]byte(record.Data), &e.Data); err != nil {
+			return nil, "", fmt.Errorf("json.Unmarshal error: %v, event_id=%s", err, record.EventID)
+		}
+		events = append(events, e)
+	}
+
+	return events, nextID, nil
+}
+
+// Prune delete events according to the retention policies, it is called periodically by the
+// background pruner, and can also be called manually.
+func (s *GormStore) Prune(ctx context.Context) error {
+	if s.maxAge > 0 {
+		err := s.db.WithContext(ctx).Table(s.tableName).
+			Where("created_at < ?", time.Now().Add(-s.maxAge)).
+			Delete(&gormEvent{}).Error
+		if err != nil {
+			return fmt.Errorf("delete expired events failed: %v", err)
+		}
+	}
+
+	if s.maxEventsPerType > 0 {
+		var eventTypes []string
+		err := s.db.WithContext(ctx).Table(s.tableName).Distinct("event_type").Pluck("event_type", &eventTypes).Error
+		if err != nil {
+			return fmt.Errorf("list event types failed: %v", err)
+		}
+
+		for _, eventType := range eventTypes {
+			// the seq of the oldest event to keep
+			var seqs []uint64
+			err = s.db.WithContext(ctx).Table(s.tableName).Where("event_type = ?", eventType).
+				Order("seq DESC").Offset(s.maxEventsPerType-1).Limit(1).Pluck("seq", &seqs).Error
+			if err != nil {
+				return fmt.Errorf("get seq of event type %s failed: %v", eventType, err)
+			}
+			if len(seqs) == 0 {
+				continue
+			}
+
+			err = s.db.WithContext(ctx).Table(s.tableName).
+				Where("event_type = ? AND seq < ?", eventType, seqs[0]).
+				Delete(&gormEvent{}).Error
+			if err != nil {
+				return fmt.Errorf("delete events of event type %s failed: %v", eventType, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close stop the background pruner, the db is not closed
+func (s *GormStore) Close() error {
+	if s.pruner != nil {
+		s.pruner.stop()
+	}
+	return nil
+}
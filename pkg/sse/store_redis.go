@@ -0,0 +1,279 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+// save the event to the stream and record the mapping of event id to stream id,
+// the keys have the same hash tag, so they are in the same slot of redis cluster
+var saveEventScript = redis.NewScript(`
+local sid = redis.call('XADD', KEYS[1], '*', 'id', ARGV[1], 'data', ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[1], sid)
+return sid
+`)
+
+// RedisStore event store based on redis streams, each event type is stored in a separate stream,
+// multiple service replicas can share the same store.
+type RedisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+
+	maxAge           time.Duration
+	maxEventsPerType int
+	pruner           *pruner
+}
+
+// NewRedisStore create a redis streams store, if retention policies are set,
+// a background pruner is started, call Close to stop it.
+func NewRedisStore(client *redis.Client, opts ...StoreOption) (*RedisStore, error) {
+	if client == nil {
+		return nil, errors.New("redis client can not be nil")
+	}
+	return newRedisStore(client, opts...), nil
+}
+
+// NewRedisClusterStore create a redis cluster streams store, the keys of each event type
+// have the same hash tag, so they are in the same slot.
+func NewRedisClusterStore(clusterClient *redis.ClusterClient, opts ...StoreOption) (*RedisStore, error) {
+	if clusterClient == nil {
+		return nil, errors.New("cluster redis client can not be nil")
+	}
+	return newRedisStore(clusterClient, opts...), nil
+}
+
+func newRedisStore(client redis.UniversalClient, opts ...StoreOption) *RedisStore {
+	o := defaultStoreOptions()
+	o.apply(opts...)
+
+	s := &RedisStore{
+		client:           client,
+		keyPrefix:        o.keyPrefix,
+		maxAge:           o.maxAge,
+		maxEventsPerType: o.maxEventsPerType,
+	}
+
+	if o.hasRetention() {
+		s.pruner = newPruner(o.pruneInterval, s.Prune, o.logger)
+		go s.pruner.run()
+	}
+
+	return s
+}
+
+// the event type is wrapped in a hash tag, the stream and index of an event type are in the same slot
+func (s *RedisStore) streamKey(eventType string) string {
+	return s.keyPrefix + "stream:{" + eventType + "}"
+}
+
+func (s *RedisStore) indexKey(eventType string) string {
+	return s.keyPrefix + "index:{" + eventType + "}"
+}
+
+func (s *RedisStore) typesKey() string {
+	return s.keyPrefix + "types"
+}
+
+// Save event to redis stream
+func (s *RedisStore) Save(ctx context.Context, e *Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error: %v", err)
+	}
+
+	keys := []string{s.streamKey(e.Event), s.indexKey(e.Event)}
+	err = saveEventScript.Run(ctx, s.client, keys, e.ID, string(data)).Err()
+	if err != nil {
+		return fmt.Errorf("save event to redis failed: %v", err)
+	}
+	// the types key is in a different slot, it is written separately
+	err = s.client.SAdd(ctx, s.typesKey(), e.Event).Err()
+	if err != nil {
+		return fmt.Errorf("save event type to redis failed: %v", err)
+	}
+	return nil
+}
+
+// ListByLastID list events after lastID by event type, if lastID is empty or does not exist
+// (e.g. it has been pruned), list from the oldest retained event.
+func (s *RedisStore) ListByLastID(ctx context.Context, eventType string, lastID string, pageSize int) ([]*Event, string, error) {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	start := "-"
+	if lastID != "" {
+		sid, err := s.client.HGet(ctx, s.indexKey(eventType), lastID).Result()
+		switch {
+		case err == nil:
+			start = "(" + sid
+		case !errors.Is(err, redis.Nil):
+			return nil, "", err
+		}
+	}
+
+	messages, err := s.client.XRangeN(ctx, s.streamKey(eventType), start, "+", int64(pageSize+1)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	hasMore := len(messages) > pageSize
+	if hasMore {
+		messages = messages[:pageSize]
+	}
+
+	events := make([]*Event, 0, len(messages))
+	for _, msg := range messages {
+		e, err := messageToEvent(eventType, msg)
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, e)
+	}
+
+	nextID := ""
+	if hasMore {
+		nextID = events[len(events)-1].ID
+	}
+
+	return events, nextID, nil
+}
+
+func messageToEvent(eventType string, msg redis.XMessage) (*Event, error) {
+	id, _ := msg.Values["id"].(string)
+	data, _ := msg.Values["data"].(string)
+	e := &Event{ID: id, Event: eventType}
+	if err := json.Unmarshal([]byte(data), &e.Data); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal error: %v, event_id=%s", err, id)
+	}
+	return e, nil
+}
+
+// Prune delete events according to the retention policies, it is called periodically by the
+// background pruner, and can also be called manually.
+func (s *RedisStore) Prune(ctx context.Context) error {
+	eventTypes, err := s.client.SMembers(ctx, s.typesKey()).Result()
+	if err != nil {
+		return fmt.Errorf("list event types failed: %v", err)
+	}
+
+	for _, eventType := range eventTypes {
+		// remove the event type whose events are all deleted, it is added again by Save
+		n, err := s.client.XLen(ctx, s.streamKey(eventType)).Result()
+		if err != nil {
+			return fmt.Errorf("get events number of event type %s failed: %v", eventType, err)
+		}
+		if n == 0 {
+			if err = s.client.SRem(ctx, s.typesKey(), eventType).Err(); err != nil {
+				return fmt.Errorf("remove event type %s failed: %v", eventType, err)
+			}
+			continue
+		}
+
+		if err = s.pruneEventType(ctx, eventType); err != nil {
+			return fmt.Errorf("prune events of event type %s failed: %v", eventType, err)
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) pruneEventType(ctx context.Context, eventType string) error {
+	streamKey := s.streamKey(eventType)
+
+	// the stream id of the newest event to delete, stream ids start with millisecond timestamps
+	var end string
+	if s.maxAge > 0 {
+		end = "(" + strconv.FormatInt(time.Now().Add(-s.maxAge).UnixMilli(), 10)
+	}
+
+	if s.maxEventsPerType > 0 {
+		total, err := s.client.XLen(ctx, streamKey).Result()
+		if err != nil {
+			return err
+		}
+		if overflow := total - int64(s.maxEventsPerType); overflow > 0 {
+			messages, err := s.client.XRangeN(ctx, streamKey, "-", "+", overflow).Result()
+			if err != nil {
+				return err
+			}
+			if len(messages) > 0 {
+				lastSID := messages[len(messages)-1].ID
+				if end == "" || compareStreamID(lastSID, strings.TrimPrefix(end, "(")) >= 0 {
+					end = lastSID
+				}
+			}
+		}
+	}
+
+	if end == "" {
+		return nil
+	}
+
+	for {
+		messages, err := s.client.XRangeN(ctx, streamKey, "-", end, 500).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		eventIDs := make([]string, 0, len(messages))
+		streamIDs := make([]string, 0, len(messages))
+		for _, msg := range messages {
+			if id, ok := msg.Values["id"].(string); ok {
+				eventIDs = append(eventIDs, id)
+			}
+			streamIDs = append(streamIDs, msg.ID)
+		}
+
+		pipe := s.client.TxPipeline()
+		if len(eventIDs) > 0 {
+			pipe.HDel(ctx, s.indexKey(eventType), eventIDs...)
+		}
+		pipe.XDel(ctx, streamKey, streamIDs...)
+		if _, err = pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// compare two stream ids in the format of <millisecondsTime>-<sequenceNumber>,
+// an id without sequence number is treated as sequence number 0.
+func compareStreamID(a, b string) int {
+	parse := func(id string) (int64, int64) {
+		ms, seq, _ := strings.Cut(id, "-")
+		msVal, _ := strconv.ParseInt(ms, 10, 64)
+		seqVal, _ := strconv.ParseInt(seq, 10, 64)
+		return msVal, seqVal
+	}
+	aMs, aSeq := parse(a)
+	bMs, bSeq := parse(b)
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+// Close stop the background pruner, the redis client is not closed
+func (s *RedisStore) Close() error {
+	if s.pruner != nil {
+		s.pruner.stop()
+	}
+	return nil
+}
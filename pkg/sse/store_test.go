@@ -0,0 +1,212 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-dev-frame/sponge/pkg/sgorm/sqlite"
+)
+
+func newTestRedisClient(t *testing.T) *redis.Client {
+	srv, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	return redis.NewClient(&redis.Options{Addr: srv.Addr()})
+}
+
+type testStore interface {
+	Store
+	Prune(ctx context.Context) error
+	Close() error
+}
+
+func testStoreListByLastID(t *testing.T, store testStore) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		err := store.Save(ctx, &Event{ID: fmt.Sprintf("e%d", i), Event: "test", Data: map[string]interface{}{"n": i}})
+		assert.NoError(t, err)
+	}
+	err := store.Save(ctx, &Event{ID: "o1", Event: "other", Data: "hello"})
+	assert.NoError(t, err)
+
+	// list from the oldest event with pagination
+	events, nextID, err := store.ListByLastID(ctx, "test", "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "e1", events[0].ID)
+	assert.Equal(t, "e2", nextID)
+
+	var ids []string
+	lastID := "e1"
+	for {
+		events, nextID, err = store.ListByLastID(ctx, "test", lastID, 2)
+		assert.NoError(t, err)
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		if nextID == "" {
+			break
+		}
+		lastID = nextID
+	}
+	assert.Equal(t, []string{"e2", "e3", "e4", "e5"}, ids)
+
+	events, nextID, err = store.ListByLastID(ctx, "other", "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "hello", events[0].Data)
+	assert.Empty(t, nextID)
+
+	// unknown last id, list from the oldest event
+	events, nextID, err = store.ListByLastID(ctx, "test", "not-exist", 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 5)
+	assert.Equal(t, "e1", events[0].ID)
+	assert.Empty(t, nextID)
+}
+
+func testStorePrune(t *testing.T, store testStore) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		err := store.Save(ctx, &Event{ID: fmt.Sprintf("e%d", i), Event: "test", Data: i})
+		assert.NoError(t, err)
+	}
+
+	err := store.Prune(ctx)
+	assert.NoError(t, err)
+
+	events, _, err := store.ListByLastID(ctx, "test", "", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "e3", events[0].ID)
+		assert.Equal(t, "e5", events[2].ID)
+	}
+
+	// resume from the oldest retained event if the last event id has been pruned
+	events, _, err = store.ListByLastID(ctx, "test", "e1", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "e3", events[0].ID)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	_, err := NewRedisStore(nil)
+	assert.Error(t, err)
+
+	store, err := NewRedisStore(newTestRedisClient(t), WithStoreKeyPrefix("test:sse:"))
+	require.NoError(t, err)
+	defer store.Close()
+	testStoreListByLastID(t, store)
+
+	store, err = NewRedisStore(newTestRedisClient(t),
+		WithStoreMaxEventsPerType(3),
+		WithStoreMaxAge(time.Hour),
+		WithStorePruneInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	defer store.Close()
+	testStorePrune(t, store)
+}
+
+func TestRedisClusterStore(t *testing.T) {
+	_, err := NewRedisClusterStore(nil)
+	assert.Error(t, err)
+
+	srv, err := miniredis.Run()
+	require.NoError(t, err)
+	defer srv.Close()
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{srv.Addr()}})
+	store, err := NewRedisClusterStore(client)
+	require.NoError(t, err)
+	defer store.Close()
+	testStoreListByLastID(t, store)
+
+	// the stream and index of an event type have the same hash tag
+	assert.Equal(t, "sse:event:stream:{test}", store.streamKey("test"))
+	assert.Equal(t, "sse:event:index:{test}", store.indexKey("test"))
+}
+
+func TestRedisStorePruneEventTypes(t *testing.T) {
+	client := newTestRedisClient(t)
+	store, err := NewRedisStore(client, WithStoreMaxAge(time.Hour))
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, &Event{ID: "e1", Event: "test", Data: 1}))
+	require.NoError(t, store.Save(ctx, &Event{ID: "o1", Event: "other", Data: 1}))
+
+	// the event type without events is removed
+	require.NoError(t, client.Del(ctx, store.streamKey("other")).Err())
+	require.NoError(t, store.Prune(ctx))
+	eventTypes, err := client.SMembers(ctx, store.typesKey()).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test"}, eventTypes)
+}
+
+func TestGormStore(t *testing.T) {
+	_, err := NewGormStore(nil)
+	assert.Error(t, err)
+
+	dbFile := filepath.Join(t.TempDir(), "sse.db")
+	db, err := sqlite.Init(dbFile)
+	if err != nil {
+		// ignore test error about not being able to connect to real sqlite
+		t.Logf("connect to sqlite failed, err=%v, dbFile=%s", err, dbFile)
+		return
+	}
+	defer sqlite.Close(db)
+
+	store, err := NewGormStore(db, WithStoreTableName("sse_event_list"))
+	require.NoError(t, err)
+	defer store.Close()
+	testStoreListByLastID(t, store)
+
+	store, err = NewGormStore(db,
+		WithStoreTableName("sse_event_prune"),
+		WithStoreMaxEventsPerType(3),
+		WithStoreMaxAge(time.Hour),
+		WithStorePruneInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	defer store.Close()
+	testStorePrune(t, store)
+}
+
+func TestStorePruner(t *testing.T) {
+	store, err := NewRedisStore(newTestRedisClient(t),
+		WithStoreMaxEventsPerType(1),
+		WithStorePruneInterval(20*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_ = store.Save(ctx, &Event{ID: "e1", Event: "test", Data: 1})
+	_ = store.Save(ctx, &Event{ID: "e2", Event: "test", Data: 2})
+	time.Sleep(100 * time.Millisecond)
+
+	events, _, err := store.ListByLastID(ctx, "test", "", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "e2", events[0].ID)
+	}
+
+	assert.NoError(t, store.Close())
+	assert.NoError(t, store.Close())
+}
+
+func TestCompareStreamID(t *testing.T) {
+	assert.Equal(t, 0, compareStreamID("1-0", "1"))
+	assert.Equal(t, -1, compareStreamID("1-0", "1-1"))
+	assert.Equal(t, 1, compareStreamID("2-0", "1-5"))
+	assert.Equal(t, -1, compareStreamID("1-9", "2"))
+}
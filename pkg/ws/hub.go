@@ -0,0 +1,630 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/krand"
+)
+
+var (
+	// ErrConnClosed the connection has been closed
+	ErrConnClosed = errors.New("websocket connection closed")
+	// ErrSendQueueFull the send queue of the connection is full, the message is dropped
+	ErrSendQueueFull = errors.New("websocket send queue is full")
+	// ErrSlowConsumer the connection is evicted because it can not keep up with the messages
+	ErrSlowConsumer = errors.New("websocket slow consumer evicted")
+	// ErrConnNotFound the connection does not exist
+	ErrConnNotFound = errors.New("websocket connection not found")
+)
+
+// HubOption is a functional option for the Hub.
+type HubOption func(*hubOptions)
+
+type hubOptions struct {
+	sendQueueSize int
+	writeTimeout  time.Duration
+	// the connection is evicted after the number of consecutive dropped messages reaches this value
+	slowConsumerThreshold int
+	zapLogger             *zap.Logger
+}
+
+func defaultHubOptions() *hubOptions {
+	return &hubOptions{
+		sendQueueSize:         256,
+		writeTimeout:          10 * time.Second,
+		slowConsumerThreshold: 1,
+	}
+}
+
+func (o *hubOptions) apply(opts ...HubOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithSendQueueSize sets the size of the send queue of each connection, default is 256.
+func WithSendQueueSize(size int) HubOption {
+	return func(o *hubOptions) {
+		if size > 0 {
+			o.sendQueueSize = size
+		}
+	}
+}
+
+// WithWriteTimeout sets the timeout for writing a message to the connection, default is 10s.
+func WithWriteTimeout(timeout time.Duration) HubOption {
+	return func(o *hubOptions) {
+		if timeout > 0 {
+			o.writeTimeout = timeout
+		}
+	}
+}
+
+// WithSlowConsumerThreshold sets the number of consecutive dropped messages caused by a full send queue,
+// after which the connection is evicted, default is 1, that is, evict as soon as the send queue is full.
+func WithSlowConsumerThreshold(n int) HubOption {
+	return func(o *hubOptions) {
+		if n > 0 {
+			o.slowConsumerThreshold = n
+		}
+	}
+}
+
+// WithHubLogger sets the logger for the hub.
+func WithHubLogger(l *zap.Logger) HubOption {
+	return func(o *hubOptions) {
+		if l != nil {
+			o.zapLogger = l
+		}
+	}
+}
+
+// ConnOption is a functional option for the connection registered to the hub.
+type ConnOption func(*HubConn)
+
+// WithConnValue sets a value of the connection, e.g. the token of the upgrade request,
+// it can be got by HubConn.Get.
+func WithConnValue(key string, val interface{}) ConnOption {
+	return func(c *HubConn) {
+		c.values.Store(key, val)
+	}
+}
+
+// --------------------------------------------------------------------------------------
+
+// Hub is a registry of live WebSocket connections, supports rooms, broadcast and targeted send.
+// Messages are written to connections by a dedicated goroutine per connection, so do not call
+// the write methods of the underlying Conn directly for connections registered to the hub.
+type Hub struct {
+	mu    sync.RWMutex
+	conns map[string]*HubConn            // connID -> conn
+	users map[string]map[string]*HubConn // userID -> connID -> conn
+	rooms map[string]map[string]*HubConn // room -> connID -> conn
+
+	sendQueueSize         int
+	writeTimeout          time.Duration
+	slowConsumerThreshold int32
+
+	Stats     *HubStats
+	zapLogger *zap.Logger
+}
+
+// NewHub creates a new WebSocket connection hub.
+func NewHub(opts ...HubOption) *Hub {
+	o := defaultHubOptions()
+	o.apply(opts...)
+	if o.zapLogger == nil {
+		o.zapLogger, _ = zap.NewProduction()
+	}
+
+	return &Hub{
+		conns: make(map[string]*HubConn),
+		users: make(map[string]map[string]*HubConn),
+		rooms: make(map[string]map[string]*HubConn),
+
+		sendQueueSize:         o.sendQueueSize,
+		writeTimeout:          o.writeTimeout,
+		slowConsumerThreshold: int32(o.slowConsumerThreshold),
+
+		Stats:     &HubStats{},
+		zapLogger: o.zapLogger,
+	}
+}
+
+// Register adds the connection to the hub and starts the write goroutine, userID can be empty.
+func (h *Hub) Register(conn *Conn, userID string, opts ...ConnOption) *HubConn {
+	c := &HubConn{
+		UserID: userID,
+		conn:   conn,
+		hub:    h,
+		send:   make(chan *outMessage, h.sendQueueSize),
+		rooms:  make(map[string]struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	h.mu.Lock()
+	for {
+		c.ID = krand.NewStringID()
+		if _, ok := h.conns[c.ID]; !ok {
+			break
+		}
+	}
+	h.conns[c.ID] = c
+	if userID != "" {
+		uc, ok := h.users[userID]
+		if !ok {
+			uc = make(map[string]*HubConn)
+			h.users[userID] = uc
+		}
+		uc[c.ID] = c
+	}
+	h.mu.Unlock()
+
+	h.Stats.incConnected()
+	go c.writeLoop()
+
+	return c
+}
+
+// Unregister removes the connection from the hub and closes it.
+func (h *Hub) Unregister(c *HubConn) {
+	if c != nil {
+		c.Close()
+	}
+}
+
+func (h *Hub) remove(c *HubConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.conns[c.ID]; !ok {
+		return
+	}
+	delete(h.conns, c.ID)
+	if uc, ok := h.users[c.UserID]; ok {
+		delete(uc, c.ID)
+		if len(uc) == 0 {
+			delete(h.users, c.UserID)
+		}
+	}
+	for room := range c.rooms {
+		h.leaveLocked(c, room)
+	}
+	h.Stats.incDisconnected()
+}
+
+// Handle returns a LoopFn that registers the connection, calls onMessage for each received
+// data message, and unregisters the connection when the client disconnects or ctx is done.
+// Example:
+//
+//	s := ws.NewServer(c.Writer, c.Request, hub.Handle(uid, onMessage))
+func (h *Hub) Handle(userID string, onMessage func(ctx context.Context, c *HubConn, messageType int, data []byte), opts ...ConnOption) LoopFn {
+	return func(ctx context.Context, conn *Conn) {
+		c := h.Register(conn, userID, opts...)
+		defer h.Unregister(c)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+				c.Close()
+			case <-c.done:
+			}
+		}()
+
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				if !IsClientClose(err) && !c.IsClosed() {
+					h.zapLogger.Warn("read message error", zap.Error(err), zap.String("conn_id", c.ID))
+				}
+				return
+			}
+			if onMessage != nil {
+				onMessage(ctx, c, messageType, data)
+			}
+		}
+	}
+}
+
+// Conn gets the connection by id.
+func (h *Hub) Conn(connID string) (*HubConn, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	c, ok := h.conns[connID]
+	return c, ok
+}
+
+// UserConns gets all connections of the user.
+func (h *Hub) UserConns(userID string) []*HubConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return connsOf(h.users[userID])
+}
+
+// RoomConns gets all connections in the room.
+func (h *Hub) RoomConns(room string) []*HubConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return connsOf(h.rooms[room])
+}
+
+// Rooms gets the names of all rooms.
+func (h *Hub) Rooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// ConnsNum gets the number of online connections.
+func (h *Hub) ConnsNum() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// UsersNum gets the number of online users.
+func (h *Hub) UsersNum() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users)
+}
+
+// JoinRoom adds the connection to the room.
+func (h *Hub) JoinRoom(connID string, room string) error {
+	c, ok := h.Conn(connID)
+	if !ok {
+		return ErrConnNotFound
+	}
+	return c.Join(room)
+}
+
+// LeaveRoom removes the connection from the room.
+func (h *Hub) LeaveRoom(connID string, room string) {
+	if c, ok := h.Conn(connID); ok {
+		c.Leave(room)
+	}
+}
+
+func (h *Hub) leaveLocked(c *HubConn, room string) {
+	delete(c.rooms, room)
+	if rc, ok := h.rooms[room]; ok {
+		delete(rc, c.ID)
+		if len(rc) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// SendToConn sends message to the connection.
+func (h *Hub) SendToConn(connID string, messageType int, data []byte) error {
+	c, ok := h.Conn(connID)
+	if !ok {
+		return ErrConnNotFound
+	}
+	return c.Send(messageType, data)
+}
+
+// SendToUser sends message to all connections of the user, returns the number of connections
+// the message is queued to.
+func (h *Hub) SendToUser(userID string, messageType int, data []byte) int {
+	return sendToConns(h.UserConns(userID), messageType, data)
+}
+
+// SendToRoom sends message to all connections in the room except the excluded connections,
+// returns the number of connections the message is queued to.
+func (h *Hub) SendToRoom(room string, messageType int, data []byte, excludeConnIDs ...string) int {
+	return sendToConns(exclude(h.RoomConns(room), excludeConnIDs), messageType, data)
+}
+
+// Broadcast sends message to all connections except the excluded connections,
+// returns the number of connections the message is queued to.
+func (h *Hub) Broadcast(messageType int, data []byte, excludeConnIDs ...string) int {
+	h.mu.RLock()
+	conns := connsOf(h.conns)
+	h.mu.RUnlock()
+	return sendToConns(exclude(conns, excludeConnIDs), messageType, data)
+}
+
+// BroadcastJSON encodes v to JSON and sends it to all connections as a text message.
+func (h *Hub) BroadcastJSON(v interface{}, excludeConnIDs ...string) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(websocket.TextMessage, data, excludeConnIDs...), nil
+}
+
+// Close closes all connections.
+func (h *Hub) Close() {
+	h.mu.RLock()
+	conns := connsOf(h.conns)
+	h.mu.RUnlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// PrintStats prints the connection stats.
+func (h *Hub) PrintStats() {
+	s := h.Stats.Snapshot()
+	h.zapLogger.Info("websocket hub stats",
+		zap.Int64("online", s.Online),
+		zap.Int64("connected", s.Connected),
+		zap.Int64("disconnected", s.Disconnected),
+		zap.Int64("sent", s.Sent),
+		zap.Int64("send_failed", s.SendFailed),
+		zap.Int64("dropped", s.Dropped),
+		zap.Int64("evicted", s.Evicted))
+}
+
+func connsOf(m map[string]*HubConn) []*HubConn {
+	conns := make([]*HubConn, 0, len(m))
+	for _, c := range m {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func exclude(conns []*HubConn, connIDs []string) []*HubConn {
+	if len(connIDs) == 0 {
+		return conns
+	}
+	result := conns[:0]
+	for _, c := range conns {
+		excluded := false
+		for _, id := range connIDs {
+			if c.ID == id {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func sendToConns(conns []*HubConn, messageType int, data []byte) int {
+	n := 0
+	for _, c := range conns {
+		if err := c.Send(messageType, data); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// --------------------------------------------------------------------------------------
+
+type outMessage struct {
+	messageType int
+	data        []byte
+}
+
+// HubConn is a WebSocket connection registered to the hub.
+type HubConn struct {
+	ID     string // unique connection id generated by the hub
+	UserID string
+
+	conn  *Conn
+	hub   *Hub
+	send  chan *outMessage
+	rooms map[string]struct{} // protected by hub.mu
+
+	values    sync.Map
+	dropped   int32 // number of consecutive dropped messages
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Set stores a value of the connection.
+func (c *HubConn) Set(key string, val interface{}) {
+	c.values.Store(key, val)
+}
+
+// Get gets a value of the connection.
+func (c *HubConn) Get(key string) (interface{}, bool) {
+	return c.values.Load(key)
+}
+
+// Hub returns the hub the connection is registered to.
+func (c *HubConn) Hub() *Hub {
+	return c.hub
+}
+
+// Conn returns the underlying connection, it can be used to read messages.
+func (c *HubConn) Conn() *Conn {
+	return c.conn
+}
+
+// Send queues the message to the connection without blocking, if the send queue is full,
+// the message is dropped, and the connection is evicted when it is a slow consumer.
+func (c *HubConn) Send(messageType int, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrConnClosed
+	default:
+	}
+
+	select {
+	case c.send <- &outMessage{messageType: messageType, data: data}:
+		atomic.StoreInt32(&c.dropped, 0)
+		return nil
+	default:
+	}
+
+	c.hub.Stats.incDropped()
+	if atomic.AddInt32(&c.dropped, 1) >= c.hub.slowConsumerThreshold {
+		c.hub.Stats.incEvicted()
+		c.hub.zapLogger.Warn("evict slow consumer", zap.String("conn_id", c.ID), zap.String("user_id", c.UserID))
+		c.Close()
+		return ErrSlowConsumer
+	}
+	return ErrSendQueueFull
+}
+
+// SendText queues a text message to the connection.
+func (c *HubConn) SendText(text string) error {
+	return c.Send(websocket.TextMessage, []byte(text))
+}
+
+// SendJSON encodes v to JSON and queues it to the connection as a text message.
+func (c *HubConn) SendJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(websocket.TextMessage, data)
+}
+
+// Join adds the connection to the room.
+func (c *HubConn) Join(room string) error {
+	if room == "" {
+		return errors.New("room name can not be empty")
+	}
+
+	h := c.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c.ID]; !ok {
+		return ErrConnClosed
+	}
+
+	c.rooms[room] = struct{}{}
+	rc, ok := h.rooms[room]
+	if !ok {
+		rc = make(map[string]*HubConn)
+		h.rooms[room] = rc
+	}
+	rc[c.ID] = c
+	return nil
+}
+
+// Leave removes the connection from the room.
+func (c *HubConn) Leave(room string) {
+	h := c.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(c, room)
+}
+
+// Rooms gets the rooms the connection has joined.
+func (c *HubConn) Rooms() []string {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Close removes the connection from the hub and closes the underlying connection,
+// the messages that have not been sent are discarded.
+func (c *HubConn) Close() {
+	c.closeOnce.Do(func() {
+		c.hub.remove(c)
+		close(c.done)
+	})
+}
+
+// IsClosed returns true if the connection has been closed.
+func (c *HubConn) IsClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *HubConn) writeLoop() {
+	defer func() {
+		if e := recover(); e != nil {
+			c.hub.zapLogger.Warn("write loop panic", zap.Any("err", e), zap.String("conn_id", c.ID))
+		}
+		c.Close()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeTimeout))
+			if err := c.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				c.hub.Stats.incSendFailed()
+				if !IsClientClose(err) {
+					c.hub.zapLogger.Warn("write message error", zap.Error(err), zap.String("conn_id", c.ID))
+				}
+				return
+			}
+			c.hub.Stats.incSent()
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------
+
+// HubStats connection and message stats of the hub.
+type HubStats struct {
+	connected    int64
+	disconnected int64
+	sent         int64
+	sendFailed   int64
+	dropped      int64
+	evicted      int64
+}
+
+// HubStatsSnapshot is a snapshot of the hub stats.
+type HubStatsSnapshot struct {
+	Online       int64 `json:"online"`       // current number of connections
+	Connected    int64 `json:"connected"`    // total number of connections registered
+	Disconnected int64 `json:"disconnected"` // total number of connections removed
+	Sent         int64 `json:"sent"`         // total number of messages written
+	SendFailed   int64 `json:"sendFailed"`   // total number of messages failed to write
+	Dropped      int64 `json:"dropped"`      // total number of messages dropped because the send queue is full
+	Evicted      int64 `json:"evicted"`      // total number of slow consumers evicted
+}
+
+func (s *HubStats) incConnected()    { atomic.AddInt64(&s.connected, 1) }
+func (s *HubStats) incDisconnected() { atomic.AddInt64(&s.disconnected, 1) }
+func (s *HubStats) incSent()         { atomic.AddInt64(&s.sent, 1) }
+func (s *HubStats) incSendFailed()   { atomic.AddInt64(&s.sendFailed, 1) }
+func (s *HubStats) incDropped()      { atomic.AddInt64(&s.dropped, 1) }
+func (s *HubStats) incEvicted()      { atomic.AddInt64(&s.evicted, 1) }
+
+// Snapshot gets the hub stats snapshot.
+func (s *HubStats) Snapshot() HubStatsSnapshot {
+	connected := atomic.LoadInt64(&s.connected)
+	disconnected := atomic.LoadInt64(&s.disconnected)
+	return HubStatsSnapshot{
+		Online:       connected - disconnected,
+		Connected:    connected,
+		Disconnected: disconnected,
+		Sent:         atomic.LoadInt64(&s.sent),
+		SendFailed:   atomic.LoadInt64(&s.sendFailed),
+		Dropped:      atomic.LoadInt64(&s.dropped),
+		Evicted:      atomic.LoadInt64(&s.evicted),
+	}
+}
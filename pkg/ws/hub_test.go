@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func runHubServer(t *testing.T, hub *Hub, onMessage func(ctx context.Context, c *HubConn, messageType int, data []byte)) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid := r.URL.Query().Get("uid")
+		s := NewServer(w, r, hub.Handle(uid, onMessage), WithServerLogger(zap.NewNop()))
+		_ = s.Run(r.Context())
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dialHub(t *testing.T, url string, uid string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?uid="+uid, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readText(t *testing.T, conn *websocket.Conn) string {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub(WithHubLogger(zap.NewNop()), WithSendQueueSize(10), WithWriteTimeout(time.Second))
+	defer hub.Close()
+
+	// join the room specified by the message, echo other messages
+	url := runHubServer(t, hub, func(ctx context.Context, c *HubConn, messageType int, data []byte) {
+		if room, ok := strings.CutPrefix(string(data), "join:"); ok {
+			_ = c.Join(room)
+			_ = c.SendText("joined " + room)
+			return
+		}
+		_ = c.Send(messageType, data)
+	})
+
+	c1 := dialHub(t, url, "u1")
+	c2 := dialHub(t, url, "u1")
+	c3 := dialHub(t, url, "u2")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 3, hub.ConnsNum())
+	assert.Equal(t, 2, hub.UsersNum())
+	assert.Len(t, hub.UserConns("u1"), 2)
+
+	// echo
+	assert.NoError(t, c1.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, "hello", readText(t, c1))
+
+	// rooms
+	assert.NoError(t, c1.WriteMessage(websocket.TextMessage, []byte("join:r1")))
+	assert.Equal(t, "joined r1", readText(t, c1))
+	assert.NoError(t, c3.WriteMessage(websocket.TextMessage, []byte("join:r1")))
+	assert.Equal(t, "joined r1", readText(t, c3))
+	assert.Equal(t, []string{"r1"}, hub.Rooms())
+	assert.Len(t, hub.RoomConns("r1"), 2)
+
+	n := hub.SendToRoom("r1", websocket.TextMessage, []byte("room message"))
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "room message", readText(t, c1))
+	assert.Equal(t, "room message", readText(t, c3))
+
+	// targeted send
+	n = hub.SendToUser("u1", websocket.TextMessage, []byte("user message"))
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "user message", readText(t, c1))
+	assert.Equal(t, "user message", readText(t, c2))
+
+	conns := hub.UserConns("u2")
+	require.Len(t, conns, 1)
+	assert.Equal(t, []string{"r1"}, conns[0].Rooms())
+	assert.NoError(t, hub.SendToConn(conns[0].ID, websocket.TextMessage, []byte("conn message")))
+	assert.Equal(t, "conn message", readText(t, c3))
+	assert.ErrorIs(t, hub.SendToConn("not-exist", websocket.TextMessage, nil), ErrConnNotFound)
+
+	// broadcast
+	n, err := hub.BroadcastJSON(map[string]string{"msg": "hi"}, conns[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `{"msg":"hi"}`, readText(t, c1))
+	assert.Equal(t, `{"msg":"hi"}`, readText(t, c2))
+
+	// leave room and disconnect
+	hub.LeaveRoom(conns[0].ID, "r1")
+	assert.Len(t, hub.RoomConns("r1"), 1)
+	_ = c1.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, hub.ConnsNum())
+	assert.Empty(t, hub.Rooms())
+	assert.ErrorIs(t, hub.JoinRoom("not-exist", "r1"), ErrConnNotFound)
+
+	hub.PrintStats()
+	s := hub.Stats.Snapshot()
+	assert.Equal(t, int64(2), s.Online)
+	assert.Equal(t, int64(3), s.Connected)
+	assert.Equal(t, int64(10), s.Sent)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(WithHubLogger(zap.NewNop()))
+	url := runHubServer(t, hub, nil)
+	conn := dialHub(t, url, "")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, hub.ConnsNum())
+	assert.Equal(t, 0, hub.UsersNum())
+
+	hub.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err)
+	assert.Equal(t, 0, hub.ConnsNum())
+}
+
+// newTestHubConn creates a connection without write loop, the send queue is never consumed
+func newTestHubConn(h *Hub, id string) *HubConn {
+	c := &HubConn{
+		ID:    id,
+		hub:   h,
+		send:  make(chan *outMessage, h.sendQueueSize),
+		rooms: make(map[string]struct{}),
+		done:  make(chan struct{}),
+	}
+	h.mu.Lock()
+	h.conns[id] = c
+	h.mu.Unlock()
+	return c
+}
+
+func TestHubSlowConsumer(t *testing.T) {
+	hub := NewHub(WithHubLogger(zap.NewNop()), WithSendQueueSize(1))
+	c := newTestHubConn(hub, "c1")
+	assert.NoError(t, c.SendText("1"))
+	assert.ErrorIs(t, c.SendText("2"), ErrSlowConsumer)
+	assert.True(t, c.IsClosed())
+	assert.ErrorIs(t, c.SendText("3"), ErrConnClosed)
+	assert.Equal(t, 0, hub.ConnsNum())
+
+	hub = NewHub(WithHubLogger(zap.NewNop()), WithSendQueueSize(1), WithSlowConsumerThreshold(2))
+	c = newTestHubConn(hub, "c2")
+	assert.NoError(t, c.Join("r1"))
+	assert.NoError(t, c.SendText("1"))
+	assert.ErrorIs(t, c.SendText("2"), ErrSendQueueFull)
+	assert.ErrorIs(t, c.SendText("3"), ErrSlowConsumer)
+	assert.Empty(t, hub.Rooms())
+	assert.Error(t, c.Join("r2"))
+
+	s := hub.Stats.Snapshot()
+	assert.Equal(t, int64(2), s.Dropped)
+	assert.Equal(t, int64(1), s.Evicted)
+}
+
+func TestExclude(t *testing.T) {
+	conns := []*HubConn{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	result := exclude(conns, []string{"b"})
+	ids := []string{}
+	for _, c := range result {
+		ids = append(ids, c.ID)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"a", "c"}, ids)
+}
@@ -0,0 +1,304 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/errcode"
+)
+
+// ErrorMessageType is the message type of the error replied when the message can not be parsed.
+const ErrorMessageType = "error"
+
+// Message is the envelope of the messages exchanged by the router, in JSON format, e.g.
+//
+//	request:  {"type":"user.get","id":"1","data":{"uid":"u001"}}
+//	response: {"type":"user.get","id":"1","data":{"name":"foo"}}
+//	error:    {"type":"user.get","id":"1","error":{"code":100001,"msg":"Invalid Parameter"}}
+type Message struct {
+	Type  string          `json:"type"`
+	ID    string          `json:"id,omitempty"` // request id, echoed in the response for correlation
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error *ErrorBody      `json:"error,omitempty"`
+}
+
+// ErrorBody is the error of the response message, code and msg are from errcode.
+type ErrorBody struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// NewErrorBody converts error to error body, the error returned by errcode.Error.Err()
+// keeps its code and message, other errors are converted to internal server error.
+func NewErrorBody(err error) *ErrorBody {
+	e := errcode.ParseError(err)
+	if e.Code() == -1 {
+		e = errcode.InternalServerError
+	}
+	return &ErrorBody{Code: e.Code(), Msg: e.Msg()}
+}
+
+// SendMessage sends a message of the type to the connection, it is usually used to push
+// messages from server side, the data is encoded to JSON.
+func SendMessage(c *HubConn, msgType string, data interface{}) error {
+	msg := &Message{Type: msgType}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		msg.Data = raw
+	}
+	return c.SendJSON(msg)
+}
+
+// --------------------------------------------------------------------------------------
+
+// Context is the context of a message handled by the router.
+type Context struct {
+	context.Context
+	Conn    *HubConn
+	Message *Message
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+// Set stores a value for this message.
+func (c *Context) Set(key string, val interface{}) {
+	c.mu.Lock()
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = val
+	c.mu.Unlock()
+}
+
+// Get gets a value for this message, if not found, get it from the connection.
+func (c *Context) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	val, ok := c.keys[key]
+	c.mu.RUnlock()
+	if ok {
+		return val, true
+	}
+	return c.Conn.Get(key)
+}
+
+// UserID returns the user id of the connection.
+func (c *Context) UserID() string {
+	return c.Conn.UserID
+}
+
+// Bind decodes the data of the message to v.
+func (c *Context) Bind(v interface{}) error {
+	if len(c.Message.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(c.Message.Data, v)
+}
+
+// HandlerFunc handles a message, the returned value is encoded to the data of the response,
+// the returned error is converted to the error of the response by NewErrorBody.
+type HandlerFunc func(c *Context) (interface{}, error)
+
+// Middleware wraps a handler.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// TypedHandler converts a typed handler to HandlerFunc, the data of the message is decoded to Req,
+// if decoding fails, errcode.InvalidParams is returned.
+func TypedHandler[Req any, Resp any](fn func(c *Context, req *Req) (*Resp, error)) HandlerFunc {
+	return func(c *Context) (interface{}, error) {
+		req := new(Req)
+		if err := c.Bind(req); err != nil {
+			return nil, errcode.InvalidParams.Err(err.Error())
+		}
+		resp, err := fn(c, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, nil
+		}
+		return resp, nil
+	}
+}
+
+// --------------------------------------------------------------------------------------
+
+// RouterOption is a functional option for the Router.
+type RouterOption func(*routerOptions)
+
+type routerOptions struct {
+	hub       *Hub
+	zapLogger *zap.Logger
+}
+
+func defaultRouterOptions() *routerOptions {
+	return &routerOptions{}
+}
+
+func (o *routerOptions) apply(opts ...RouterOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithRouterHub sets the hub the connections are registered to, default is a new hub.
+func WithRouterHub(hub *Hub) RouterOption {
+	return func(o *routerOptions) {
+		o.hub = hub
+	}
+}
+
+// WithRouterLogger sets the logger for the router.
+func WithRouterLogger(l *zap.Logger) RouterOption {
+	return func(o *routerOptions) {
+		if l != nil {
+			o.zapLogger = l
+		}
+	}
+}
+
+// Router dispatches the JSON messages to the handlers registered by message type,
+// the handler result is replied with the same type and id, messages without id are
+// treated as notifications and no response is replied.
+type Router struct {
+	mu          sync.RWMutex
+	handlers    map[string]HandlerFunc
+	middlewares []Middleware
+
+	hub       *Hub
+	zapLogger *zap.Logger
+}
+
+// NewRouter creates a new message router.
+func NewRouter(opts ...RouterOption) *Router {
+	o := defaultRouterOptions()
+	o.apply(opts...)
+	if o.zapLogger == nil {
+		o.zapLogger, _ = zap.NewProduction()
+	}
+	if o.hub == nil {
+		o.hub = NewHub(WithHubLogger(o.zapLogger))
+	}
+
+	return &Router{
+		handlers:  make(map[string]HandlerFunc),
+		hub:       o.hub,
+		zapLogger: o.zapLogger,
+	}
+}
+
+// Use adds global middlewares, they are applied to the handlers registered after calling Use.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handle registers the handler for the message type, the route middlewares are executed after
+// the global middlewares.
+func (r *Router) Handle(msgType string, handler HandlerFunc, middlewares ...Middleware) {
+	if msgType == "" || handler == nil {
+		panic("ws: message type and handler can not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[msgType]; ok {
+		panic(fmt.Sprintf("ws: handler of message type %s already exists", msgType))
+	}
+
+	all := make([]Middleware, 0, len(r.middlewares)+len(middlewares))
+	all = append(all, r.middlewares...)
+	all = append(all, middlewares...)
+	for i := len(all) - 1; i >= 0; i-- {
+		handler = all[i](handler)
+	}
+	r.handlers[msgType] = handler
+}
+
+// Hub returns the hub the connections are registered to.
+func (r *Router) Hub() *Hub {
+	return r.hub
+}
+
+// LoopFn returns a LoopFn for ws.NewServer, the connection is registered to the hub.
+// Example:
+//
+//	s := ws.NewServer(c.Writer, c.Request, router.LoopFn(uid, ws.WithConnValue("token", c.GetHeader("Authorization"))))
+func (r *Router) LoopFn(userID string, opts ...ConnOption) LoopFn {
+	return r.hub.Handle(userID, r.OnMessage, opts...)
+}
+
+// OnMessage handles a message received from the connection, it can be used as the onMessage of Hub.Handle.
+func (r *Router) OnMessage(ctx context.Context, conn *HubConn, messageType int, data []byte) {
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		return
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(data, msg); err != nil || msg.Type == "" {
+		r.reply(conn, &Message{
+			Type:  ErrorMessageType,
+			Error: NewErrorBody(errcode.InvalidParams.Err()),
+		})
+		return
+	}
+
+	r.mu.RLock()
+	handler, ok := r.handlers[msg.Type]
+	r.mu.RUnlock()
+	if !ok {
+		if msg.ID != "" {
+			r.reply(conn, &Message{
+				Type:  msg.Type,
+				ID:    msg.ID,
+				Error: NewErrorBody(errcode.NotFound.Err("unknown message type " + msg.Type)),
+			})
+		}
+		return
+	}
+
+	c := &Context{Context: ctx, Conn: conn, Message: msg}
+	resp, err := r.call(handler, c)
+	if msg.ID == "" {
+		return // notification, no response
+	}
+
+	reply := &Message{Type: msg.Type, ID: msg.ID}
+	if err != nil {
+		reply.Error = NewErrorBody(err)
+	} else if resp != nil {
+		raw, e := json.Marshal(resp)
+		if e != nil {
+			r.zapLogger.Warn("marshal response error", zap.Error(e), zap.String("type", msg.Type))
+			reply.Error = NewErrorBody(errcode.InternalServerError.Err())
+		} else {
+			reply.Data = raw
+		}
+	}
+	r.reply(conn, reply)
+}
+
+func (r *Router) call(handler HandlerFunc, c *Context) (resp interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			r.zapLogger.Error("handle message panic", zap.Any("err", e), zap.String("type", c.Message.Type))
+			resp, err = nil, errcode.InternalServerError.Err()
+		}
+	}()
+	return handler(c)
+}
+
+func (r *Router) reply(conn *HubConn, msg *Message) {
+	if err := conn.SendJSON(msg); err != nil {
+		r.zapLogger.Warn("reply message error", zap.Error(err), zap.String("type", msg.Type), zap.String("conn_id", conn.ID))
+	}
+}
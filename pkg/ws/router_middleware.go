@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/errcode"
+	"github.com/go-dev-frame/sponge/pkg/jwt"
+	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
+)
+
+// ClaimsKey is the key of jwt claims stored in the Context by the JWTAuth middleware.
+const ClaimsKey = "claims"
+
+// JWTAuthOption set the jwt auth options.
+type JWTAuthOption func(*jwtAuthOptions)
+
+type jwtAuthOptions struct {
+	signKey []byte
+	tokenFn func(c *Context) string
+}
+
+func defaultJWTAuthOptions() *jwtAuthOptions {
+	return &jwtAuthOptions{
+		tokenFn: defaultTokenFn,
+	}
+}
+
+func (o *jwtAuthOptions) apply(opts ...JWTAuthOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithJWTSignKey set jwt sign key
+func WithJWTSignKey(key []byte) JWTAuthOption {
+	return func(o *jwtAuthOptions) {
+		o.signKey = key
+	}
+}
+
+// WithJWTTokenFn set the function to get token, default is to get the value of key "token"
+// from the connection, which is set by WithConnValue, the "Bearer " prefix is optional.
+func WithJWTTokenFn(fn func(c *Context) string) JWTAuthOption {
+	return func(o *jwtAuthOptions) {
+		if fn != nil {
+			o.tokenFn = fn
+		}
+	}
+}
+
+func defaultTokenFn(c *Context) string {
+	v, ok := c.Conn.Get("token")
+	if !ok {
+		return ""
+	}
+	token, _ := v.(string)
+	return token
+}
+
+// JWTAuth authorization middleware, the token is validated by pkg/jwt for each message,
+// the claims can be got by GetClaims.
+func JWTAuth(opts ...JWTAuthOption) Middleware {
+	o := defaultJWTAuthOptions()
+	o.apply(opts...)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (interface{}, error) {
+			token := strings.TrimPrefix(o.tokenFn(c), "Bearer ")
+			if token == "" {
+				return nil, errcode.Unauthorized.Err()
+			}
+			claims, err := jwt.ValidateToken(token, jwt.WithValidateTokenSignKey(o.signKey))
+			if err != nil {
+				return nil, errcode.Unauthorized.Err()
+			}
+			c.Set(ClaimsKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// GetClaims get jwt claims from the Context.
+func GetClaims(c *Context) (*jwt.Claims, bool) {
+	v, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*jwt.Claims)
+	return claims, ok
+}
+
+// Logging middleware, print the type, id, user id, latency and error code of each message.
+func Logging(l *zap.Logger) Middleware {
+	if l == nil {
+		l, _ = zap.NewProduction()
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (interface{}, error) {
+			start := time.Now()
+			resp, err := next(c)
+
+			fields := []zap.Field{
+				zap.String("type", c.Message.Type),
+				zap.String("id", c.Message.ID),
+				zap.String("conn_id", c.Conn.ID),
+				zap.String("user_id", c.Conn.UserID),
+				zap.Int("size", len(c.Message.Data)),
+				zap.String("time", time.Since(start).String()),
+			}
+			if err != nil {
+				fields = append(fields, zap.Int("code", NewErrorBody(err).Code), zap.Error(err))
+				l.Warn("<<<< ws message", fields...)
+			} else {
+				l.Info("<<<< ws message", fields...)
+			}
+			return resp, err
+		}
+	}
+}
+
+// RateLimit middleware, if limiter is nil, an adaptive limiter of pkg/shield/ratelimit with
+// default options is used, errcode.TooManyRequests is returned when the limit is exceeded.
+func RateLimit(limiter rl.Limiter) Middleware {
+	if limiter == nil {
+		limiter = rl.NewLimiter()
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (interface{}, error) {
+			done, err := limiter.Allow()
+			if err != nil {
+				return nil, errcode.TooManyRequests.Err()
+			}
+			resp, err := next(c)
+			done(rl.DoneInfo{Err: err})
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/go-dev-frame/sponge/pkg/errcode"
+	"github.com/go-dev-frame/sponge/pkg/jwt"
+	rl "github.com/go-dev-frame/sponge/pkg/shield/ratelimit"
+)
+
+type addReq struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addResp struct {
+	Sum int `json:"sum"`
+}
+
+type rejectLimiter struct{}
+
+func (l rejectLimiter) Allow() (rl.DoneFunc, error) {
+	return nil, rl.ErrLimitExceed
+}
+
+func runRouterServer(t *testing.T, router *Router) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loopFn := router.LoopFn(r.URL.Query().Get("uid"), WithConnValue("token", r.Header.Get("Authorization")))
+		s := NewServer(w, r, loopFn, WithServerLogger(zap.NewNop()))
+		_ = s.Run(r.Context())
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func call(t *testing.T, conn *websocket.Conn, req string) *Message {
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(req)))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	msg := &Message{}
+	require.NoError(t, json.Unmarshal(data, msg))
+	return msg
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter(WithRouterLogger(zap.NewNop()), WithRouterHub(NewHub(WithHubLogger(zap.NewNop()))))
+	defer router.Hub().Close()
+	router.Use(Logging(zap.NewNop()))
+
+	notified := make(chan string, 1)
+	router.Handle("add", TypedHandler(func(c *Context, req *addReq) (*addResp, error) {
+		if req.A < 0 {
+			return nil, errcode.InvalidParams.Err()
+		}
+		return &addResp{Sum: req.A + req.B}, nil
+	}))
+	router.Handle("notify", func(c *Context) (interface{}, error) {
+		notified <- c.UserID()
+		return nil, nil
+	})
+	router.Handle("panic", func(c *Context) (interface{}, error) {
+		panic("oops")
+	})
+	router.Handle("fail", func(c *Context) (interface{}, error) {
+		return nil, errors.New("database is down")
+	})
+	router.Handle("limited", func(c *Context) (interface{}, error) {
+		return "ok", nil
+	}, RateLimit(rejectLimiter{}))
+	assert.Panics(t, func() { router.Handle("add", func(c *Context) (interface{}, error) { return nil, nil }) })
+
+	conn, _, err := websocket.DefaultDialer.Dial(runRouterServer(t, router)+"?uid=u1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	msg := call(t, conn, `{"type":"add","id":"1","data":{"a":1,"b":2}}`)
+	assert.Equal(t, "add", msg.Type)
+	assert.Equal(t, "1", msg.ID)
+	assert.Nil(t, msg.Error)
+	assert.JSONEq(t, `{"sum":3}`, string(msg.Data))
+
+	msg = call(t, conn, `{"type":"add","id":"2","data":{"a":-1}}`)
+	assert.Equal(t, "2", msg.ID)
+	assert.Equal(t, errcode.InvalidParams.Code(), msg.Error.Code)
+
+	msg = call(t, conn, `{"type":"add","id":"3","data":"not object"}`)
+	assert.Equal(t, errcode.InvalidParams.Code(), msg.Error.Code)
+
+	msg = call(t, conn, `{"type":"unknown","id":"4"}`)
+	assert.Equal(t, errcode.NotFound.Code(), msg.Error.Code)
+
+	msg = call(t, conn, `{"type":"panic","id":"5"}`)
+	assert.Equal(t, errcode.InternalServerError.Code(), msg.Error.Code)
+
+	msg = call(t, conn, `{"type":"fail","id":"6"}`)
+	assert.Equal(t, errcode.InternalServerError.Code(), msg.Error.Code)
+	assert.Equal(t, errcode.InternalServerError.Msg(), msg.Error.Msg)
+
+	msg = call(t, conn, `{"type":"limited","id":"7"}`)
+	assert.Equal(t, errcode.TooManyRequests.Code(), msg.Error.Code)
+
+	msg = call(t, conn, `not json`)
+	assert.Equal(t, ErrorMessageType, msg.Type)
+	assert.Equal(t, errcode.InvalidParams.Code(), msg.Error.Code)
+
+	// notification without id, no response
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"notify"}`)))
+	select {
+	case uid := <-notified:
+		assert.Equal(t, "u1", uid)
+	case <-time.After(time.Second):
+		t.Fatal("expected notification handled but got timeout")
+	}
+
+	// server push
+	conns := router.Hub().UserConns("u1")
+	require.Len(t, conns, 1)
+	require.NoError(t, SendMessage(conns[0], "news", map[string]string{"title": "hello"}))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"news","data":{"title":"hello"}}`, string(data))
+}
+
+func TestRouterJWTAuth(t *testing.T) {
+	signKey := []byte("test-sign-key")
+	router := NewRouter(WithRouterLogger(zap.NewNop()))
+	defer router.Hub().Close()
+	router.Use(JWTAuth(WithJWTSignKey(signKey)))
+	router.Handle("whoami", func(c *Context) (interface{}, error) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			return nil, errcode.Unauthorized.Err()
+		}
+		return claims.UID, nil
+	})
+	url := runRouterServer(t, router)
+
+	// without token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	msg := call(t, conn, `{"type":"whoami","id":"1"}`)
+	assert.Equal(t, errcode.Unauthorized.Code(), msg.Error.Code)
+
+	// with token
+	_, token, err := jwt.GenerateToken("u100", jwt.WithGenerateTokenSignKey(signKey))
+	require.NoError(t, err)
+	conn2, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer " + token}})
+	require.NoError(t, err)
+	defer conn2.Close()
+	msg = call(t, conn2, `{"type":"whoami","id":"2"}`)
+	assert.Nil(t, msg.Error)
+	assert.Equal(t, `"u100"`, string(msg.Data))
+}
+
+func TestNewErrorBody(t *testing.T) {
+	e := NewErrorBody(errcode.Forbidden.Err())
+	assert.Equal(t, errcode.Forbidden.Code(), e.Code)
+	e = NewErrorBody(errcode.Forbidden.Err("no access"))
+	assert.Equal(t, "no access", e.Msg)
+	e = NewErrorBody(errors.New("unknown"))
+	assert.Equal(t, errcode.InternalServerError.Code(), e.Code)
+}